@@ -0,0 +1,112 @@
+package icache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound 加载器返回该错误(可包装)表示数据不存在，配合 Typed.NegativeTTL 缓存未命中结果
+var ErrNotFound = errors.New("数据不存在")
+
+// negativeSuffix 未命中标记的key后缀
+const negativeSuffix = "\x00negative"
+
+// Typed 类型化缓存，值以JSON序列化存储在FreeCache中，与 FreeCache.Get/Set 的存储格式兼容
+//
+// 使用示例:
+//
+//	users := icache.NewTyped[User](icache.NewFreeCache(10<<20), "user:")
+//	users.NegativeTTL = time.Minute
+//	u, err := users.GetOrLoad("42", 10*time.Minute, func() (User, error) {
+//		return db.FindUser(42) // 不存在时返回 icache.ErrNotFound
+//	})
+type Typed[T any] struct {
+	cache  *FreeCache
+	prefix string
+
+	// NegativeTTL 加载器返回 ErrNotFound 时缓存该结果的时间，期间 GetOrLoad 直接返回 ErrNotFound，<=0不缓存
+	NegativeTTL time.Duration
+
+	group singleflight.Group
+}
+
+// NewTyped 创建类型化缓存，prefix为key前缀，用于区分同一FreeCache中的不同类型
+func NewTyped[T any](cache *FreeCache, prefix string) *Typed[T] {
+	return &Typed[T]{cache: cache, prefix: prefix}
+}
+
+// Get 获取key对应的值，未命中(含未命中标记)时返回false，值无法解析为T时返回错误
+func (c *Typed[T]) Get(key string) (T, bool, error) {
+	var value T
+	data, ok := c.cache.GetRaw(c.prefix + key)
+	if !ok {
+		return value, false, nil
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		var zero T
+		return zero, false, fmt.Errorf("缓存值解析失败: %w", err)
+	}
+	return value, true, nil
+}
+
+// Set 存入值并清除未命中标记，ttl<=0永不过期
+func (c *Typed[T]) Set(key string, value T, ttl time.Duration) error {
+	if err := c.cache.SetWithTTL(c.prefix+key, value, ttl); err != nil {
+		return err
+	}
+	c.cache.Delete(c.prefix + key + negativeSuffix)
+	return nil
+}
+
+// Delete 删除值与未命中标记
+func (c *Typed[T]) Delete(key string) {
+	c.cache.Delete(c.prefix + key)
+	c.cache.Delete(c.prefix + key + negativeSuffix)
+}
+
+// GetOrLoad 获取key对应的值，未命中时调用loader加载并以ttl缓存
+// 同一key的并发未命中只调用一次loader，其余调用共享结果；缓存值无法解析时重新加载并覆盖
+// loader返回 ErrNotFound 且设置了 NegativeTTL 时缓存未命中结果，其余错误不缓存；写入缓存失败时同时返回加载的值与错误
+func (c *Typed[T]) GetOrLoad(key string, ttl time.Duration, loader func() (T, error)) (T, error) {
+	if value, ok, err := c.Get(key); ok && err == nil {
+		return value, nil
+	}
+	if c.negative(key) {
+		var zero T
+		return zero, ErrNotFound
+	}
+
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		// 等待期间其他调用可能已完成加载
+		if value, ok, err := c.Get(key); ok && err == nil {
+			return value, nil
+		}
+		if c.negative(key) {
+			return nil, ErrNotFound
+		}
+
+		value, err := loader()
+		if err != nil {
+			if errors.Is(err, ErrNotFound) && c.NegativeTTL > 0 {
+				c.cache.SetWithTTL(c.prefix+key+negativeSuffix, true, c.NegativeTTL)
+			}
+			return nil, err
+		}
+		if err := c.Set(key, value, ttl); err != nil {
+			return value, fmt.Errorf("写入缓存失败: %w", err)
+		}
+		return value, nil
+	})
+	value, _ := v.(T)
+	return value, err
+}
+
+// negative 是否存在未命中标记
+func (c *Typed[T]) negative(key string) bool {
+	_, ok := c.cache.GetRaw(c.prefix + key + negativeSuffix)
+	return ok
+}
@@ -0,0 +1,128 @@
+package icache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTypedGet(t *testing.T) {
+	cache := NewFreeCache(1024 * 1024)
+	users := NewTyped[user](cache, "user:")
+
+	if _, ok, err := users.Get("1"); ok || err != nil {
+		t.Fatalf("未命中应返回false且无错误: %v %v", ok, err)
+	}
+	if err := users.Set("1", user{ID: 1, Name: "tom"}, 0); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	u, ok, err := users.Get("1")
+	if !ok || err != nil || u.Name != "tom" {
+		t.Fatalf("命中错误: %+v %v %v", u, ok, err)
+	}
+
+	// 与FreeCache存储格式兼容
+	var raw user
+	if !cache.Get("user:1", &raw) || raw.ID != 1 {
+		t.Fatalf("存储格式不兼容: %+v", raw)
+	}
+
+	// 解析失败不再被吞掉
+	cache.Set("user:2", "not a user")
+	if _, ok, err := users.Get("2"); ok || err == nil {
+		t.Fatalf("解析失败应返回错误: %v %v", ok, err)
+	}
+	u, err = users.GetOrLoad("2", 0, func() (user, error) { return user{ID: 2}, nil })
+	if err != nil || u.ID != 2 {
+		t.Fatalf("解析失败时应重新加载: %+v %v", u, err)
+	}
+
+	users.Delete("1")
+	if _, ok, _ := users.Get("1"); ok {
+		t.Fatal("删除后应未命中")
+	}
+}
+
+func TestTypedGetOrLoadSingleflight(t *testing.T) {
+	users := NewTyped[user](NewFreeCache(1024*1024), "")
+	var calls atomic.Int32
+	loader := func() (user, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return user{ID: 7, Name: "jim"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := users.GetOrLoad("7", time.Minute, loader)
+			if err != nil || u.Name != "jim" {
+				t.Errorf("加载结果错误: %+v %v", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("并发未命中应只加载一次: %d", calls.Load())
+	}
+	if _, err := users.GetOrLoad("7", time.Minute, loader); err != nil || calls.Load() != 1 {
+		t.Fatalf("命中后不应再加载: %v %d", err, calls.Load())
+	}
+}
+
+func TestTypedNegative(t *testing.T) {
+	users := NewTyped[user](NewFreeCache(1024*1024), "")
+	var calls atomic.Int32
+	missing := func() (user, error) {
+		calls.Add(1)
+		return user{}, ErrNotFound
+	}
+
+	// 未设置NegativeTTL时不缓存未命中结果
+	users.GetOrLoad("x", 0, missing)
+	users.GetOrLoad("x", 0, missing)
+	if calls.Load() != 2 {
+		t.Fatalf("未设置NegativeTTL不应缓存: %d", calls.Load())
+	}
+
+	users.NegativeTTL = time.Second
+	calls.Store(0)
+	for i := 0; i < 3; i++ {
+		if _, err := users.GetOrLoad("x", 0, missing); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("应返回ErrNotFound: %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("未命中结果应被缓存: %d", calls.Load())
+	}
+	if _, ok, err := users.Get("x"); ok || err != nil {
+		t.Fatalf("未命中标记对Get应表现为未命中: %v %v", ok, err)
+	}
+
+	// Set覆盖未命中标记
+	users.Set("x", user{ID: 9}, 0)
+	if u, err := users.GetOrLoad("x", 0, missing); err != nil || u.ID != 9 {
+		t.Fatalf("Set后应命中: %+v %v", u, err)
+	}
+
+	// 其余错误不缓存
+	boom := errors.New("backend down")
+	calls.Store(0)
+	failing := func() (user, error) {
+		calls.Add(1)
+		return user{}, boom
+	}
+	users.GetOrLoad("y", 0, failing)
+	if _, err := users.GetOrLoad("y", 0, failing); !errors.Is(err, boom) || calls.Load() != 2 {
+		t.Fatalf("普通错误不应缓存: %v %d", err, calls.Load())
+	}
+}
@@ -2,7 +2,6 @@ package icaptcha
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"time"
 
@@ -11,8 +10,13 @@ import (
 
 type CapSolver struct {
 	ApiKey string
+
+	cli *ihttp.Client
 }
 
+// CapSolverBaseURL CapSolver接口地址
+const CapSolverBaseURL = "https://api.capsolver.com"
+
 type CapSolution struct {
 	Object             []bool    `json:"objects,omitempty"`
 	Box                []float32 `json:"box,omitempty"`
@@ -46,9 +50,12 @@ type CapResponse struct {
 }
 
 func (c *CapSolver) request(uri string, jsonBody map[string]any) (*CapResponse, error) {
+	if c.cli == nil {
+		c.cli = &ihttp.Client{BaseURL: CapSolverBaseURL}
+	}
 	capResponse := &CapResponse{}
-	_, err := ihttp.Do(&ihttp.Opt{
-		URL:     fmt.Sprintf("%s%s", "https://api.capsolver.com", uri),
+	// CapSolver接口文档要求POST JSON
+	_, err := c.cli.Post(uri, &ihttp.Opt{
 		Json:    jsonBody,
 		RespOut: capResponse,
 	})
@@ -73,10 +80,12 @@ func (c *CapSolver) Solve(task map[string]any) (*CapResponse, error) {
 	if capRes.Status == "ready" {
 		return capRes, nil
 	}
+	// getTaskResult 的响应不包含taskId，需单独保存
+	taskId := capRes.TaskId
 	for i := 0; i < 50; i++ {
 		capRes, err = c.request("/getTaskResult", map[string]any{
 			"clientKey": c.getApiKey(),
-			"taskId":    capRes.TaskId,
+			"taskId":    taskId,
 		})
 		if err != nil {
 			return nil, err
@@ -0,0 +1,67 @@
+package icaptcha
+
+import (
+	"testing"
+
+	"github.com/Covsj/gokit/ihttp"
+	"github.com/Covsj/gokit/ihttp/ihttptest"
+)
+
+// mockSolver 返回请求Mock服务器的CapSolver
+func mockSolver(t *testing.T) (*CapSolver, *ihttptest.Server) {
+	srv := ihttptest.NewServer(t)
+	return &CapSolver{
+		ApiKey: "test-key",
+		cli:    &ihttp.Client{BaseURL: srv.URL, NotLog: true},
+	}, srv
+}
+
+// TestRequestPostMock 接口文档要求以POST发送JSON，GET请求不会匹配任何路由
+func TestRequestPostMock(t *testing.T) {
+	s, srv := mockSolver(t)
+	srv.On("POST", "/createTask").Header("Content-Type", "application/json").
+		ReplyJSON(200, map[string]any{"errorId": 0, "status": "ready", "solution": map[string]any{"token": "t"}})
+	srv.On("POST", "/getBalance").Header("Content-Type", "application/json").
+		ReplyJSON(200, map[string]any{"errorId": 0, "balance": 1})
+
+	if res, err := s.Solve(map[string]any{"type": "AntiCloudflareTask"}); err != nil || res.Solution.Token != "t" {
+		t.Fatalf("Solve失败: %+v %v", res, err)
+	}
+	if res, err := s.Balance(); err != nil || res.Balance != 1 {
+		t.Fatalf("查询余额失败: %+v %v", res, err)
+	}
+	srv.AssertExpectations()
+}
+
+func TestSolveMock(t *testing.T) {
+	s, srv := mockSolver(t)
+	srv.On("POST", "/createTask").JSONBody(map[string]any{
+		"clientKey": "test-key",
+		"task":      map[string]any{"type": "ReCaptchaV2TaskProxyLess"},
+	}).ReplyJSON(200, map[string]any{"errorId": 0, "taskId": "task-1", "status": "idle"})
+	poll := srv.On("POST", "/getTaskResult").JSONBody(map[string]any{"clientKey": "test-key", "taskId": "task-1"}).
+		Times(2).Reply(
+		ihttptest.JSON(200, map[string]any{"errorId": 0, "status": "processing"}),
+		ihttptest.JSON(200, map[string]any{"errorId": 0, "status": "ready", "solution": map[string]any{"gRecaptchaResponse": "token"}}),
+	)
+
+	res, err := s.Solve(map[string]any{"type": "ReCaptchaV2TaskProxyLess"})
+	if err != nil || res.Status != "ready" || res.Solution == nil || res.Solution.GRecaptchaResponse != "token" {
+		t.Fatalf("Solve结果错误: %+v %v", res, err)
+	}
+	if poll.Calls() != 2 {
+		t.Fatalf("每次轮询都应携带taskId: %d", poll.Calls())
+	}
+
+	// 接口错误与余额查询
+	srv.Reset()
+	srv.On("POST", "/createTask").ReplyJSON(200, map[string]any{"errorId": 1, "errorCode": "ERROR_KEY_DENIED_ACCESS", "errorDescription": "key denied"})
+	srv.On("POST", "/getBalance").JSONBody(map[string]any{"clientKey": "test-key"}).ReplyJSON(200, map[string]any{"errorId": 0, "balance": 3.5})
+	if _, err = s.Solve(map[string]any{"type": "ReCaptchaV2TaskProxyLess"}); err == nil || err.Error() != "key denied" {
+		t.Fatalf("期望返回接口错误描述, 实际: %v", err)
+	}
+	if b, err := s.Balance(); err != nil || b.Balance != 3.5 {
+		t.Fatalf("查询余额失败: %+v %v", b, err)
+	}
+	srv.AssertExpectations()
+}
@@ -0,0 +1,46 @@
+package icaptcha
+
+import (
+	"testing"
+
+	"github.com/Covsj/gokit/ihttp"
+)
+
+// replaySolver 返回从HAR回放请求的CapSolver，无需网络与真实ApiKey
+func replaySolver(t *testing.T) *CapSolver {
+	har, err := ihttp.LoadHAR("testdata/capsolver.har")
+	if err != nil {
+		t.Fatalf("加载HAR失败: %v", err)
+	}
+	return &CapSolver{
+		ApiKey: "test-key",
+		cli:    &ihttp.Client{BaseURL: CapSolverBaseURL, HAR: har, NotLog: true},
+	}
+}
+
+func TestSolveReplay(t *testing.T) {
+	s := replaySolver(t)
+	res, err := s.Solve(map[string]any{
+		"type":       "ReCaptchaV2TaskProxyLess",
+		"websiteURL": "https://example.com/login",
+		"websiteKey": "site-key",
+	})
+	if err != nil {
+		t.Fatalf("Solve失败: %v", err)
+	}
+	if res.Status != "ready" || res.Solution == nil || res.Solution.GRecaptchaResponse != "03AGdBq2-token" {
+		t.Fatalf("结果错误: %+v", res)
+	}
+
+	_, err = s.Solve(map[string]any{"type": "UnknownTask"})
+	if err == nil || err.Error() != "task type is not supported" {
+		t.Fatalf("期望返回接口错误描述, 实际: %v", err)
+	}
+}
+
+func TestBalanceReplay(t *testing.T) {
+	res, err := replaySolver(t).Balance()
+	if err != nil || res.Balance != 12.5 {
+		t.Fatalf("查询余额失败: %+v %v", res, err)
+	}
+}
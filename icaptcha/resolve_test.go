@@ -8,9 +8,18 @@ import (
 	log "github.com/Covsj/gokit/ilog"
 )
 
+// skipLive 访问真实服务的测试默认跳过，设置 GOKIT_LIVE_TEST=1 且未使用 -short 时运行
+func skipLive(t *testing.T) {
+	t.Helper()
+	if testing.Short() || os.Getenv("GOKIT_LIVE_TEST") == "" {
+		t.Skip("访问真实服务，设置 GOKIT_LIVE_TEST=1 后运行")
+	}
+}
+
 var capSolver = &CapSolver{ApiKey: ""}
 
 func TestTask(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type":       "ReCaptchaV2TaskProxyLess",
@@ -24,6 +33,7 @@ func TestTask(t *testing.T) {
 }
 
 func TestFunCaptcha(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type":             "FunCaptchaTask",
@@ -39,6 +49,7 @@ func TestFunCaptcha(t *testing.T) {
 
 }
 func TestHCaptcha(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type":       "HCaptchaTurboTask",
@@ -54,6 +65,7 @@ func TestHCaptcha(t *testing.T) {
 }
 
 func TestGeeTest(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type": "GeeTestTaskProxyLess",
@@ -65,6 +77,7 @@ func TestGeeTest(t *testing.T) {
 }
 
 func TestDataDom(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type": "DataDomeSliderTask",
@@ -76,6 +89,7 @@ func TestDataDom(t *testing.T) {
 }
 
 func TestAntiCloudflareTask(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type": "AntiCloudflareTask",
@@ -87,6 +101,7 @@ func TestAntiCloudflareTask(t *testing.T) {
 }
 
 func TestAntiKasadaTask(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type": "AntiKasadaTask",
@@ -98,6 +113,7 @@ func TestAntiKasadaTask(t *testing.T) {
 }
 
 func TestAntiAkamaiBMPTask(t *testing.T) {
+	skipLive(t)
 	s, err := capSolver.Solve(
 		map[string]any{
 			"type": "AntiAkamaiBMPTask",
@@ -109,6 +125,7 @@ func TestAntiAkamaiBMPTask(t *testing.T) {
 }
 
 func TestBalance(t *testing.T) {
+	skipLive(t)
 	b, err := capSolver.Balance()
 	if err != nil {
 		panic(err)
@@ -117,6 +134,7 @@ func TestBalance(t *testing.T) {
 }
 
 func TestRecognition(t *testing.T) {
+	skipLive(t)
 	b, err := os.ReadFile("queue-it.jpg")
 	if err != nil {
 		panic(err)
@@ -133,6 +151,7 @@ func TestRecognition(t *testing.T) {
 }
 
 func TestHCaptchaClassfication(t *testing.T) {
+	skipLive(t)
 	b, err := os.ReadFile("queue-it.jpg")
 	if err != nil {
 		panic(err)
@@ -0,0 +1,85 @@
+// Package digest 摘要算法表，icrypto.HashGenerator 与 ihttp 下载校验共用同一组算法名称
+//
+// 不依赖dongle，返回标准库 hash.Hash，可用于流式计算大文件摘要
+package digest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/md4"
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/sha3"
+)
+
+// hashes 算法名称与构造函数，名称与 icrypto.HashGenerator 一致
+var hashes = map[string]func() hash.Hash{
+	"md2":          newMD2,
+	"md4":          md4.New,
+	"md5":          md5.New,
+	"sha1":         sha1.New,
+	"sha3-224":     sha3.New224,
+	"sha3-256":     sha3.New256,
+	"sha3-384":     sha3.New384,
+	"sha3-512":     sha3.New512,
+	"sha224":       sha256.New224,
+	"sha256":       sha256.New,
+	"sha384":       sha512.New384,
+	"sha512":       sha512.New,
+	"sha512-224":   sha512.New512_224,
+	"sha512-256":   sha512.New512_256,
+	"shake128-256": func() hash.Hash { return newShake(sha3.NewShake128(), 256) },
+	"shake128-512": func() hash.Hash { return newShake(sha3.NewShake128(), 512) },
+	"shake256-384": func() hash.Hash { return newShake(sha3.NewShake256(), 384) },
+	"shake256-512": func() hash.Hash { return newShake(sha3.NewShake256(), 512) },
+	"ripemd160":    ripemd160.New,
+	"blake2b-256":  func() hash.Hash { h, _ := blake2b.New256(nil); return h },
+	"blake2b-384":  func() hash.Hash { h, _ := blake2b.New384(nil); return h },
+	"blake2b-512":  func() hash.Hash { h, _ := blake2b.New512(nil); return h },
+	"blake2s-256":  func() hash.Hash { h, _ := blake2s.New256(nil); return h },
+}
+
+// New 按名称创建摘要算法，名称不区分大小写，不支持时返回false
+func New(name string) (hash.Hash, bool) {
+	fn, ok := hashes[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, false
+	}
+	return fn(), true
+}
+
+// Names 返回支持的算法名称，按字典序排列
+func Names() []string {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shake 固定输出长度的SHAKE，bits为输出位数
+type shake struct {
+	sha3.ShakeHash
+	size int
+}
+
+func newShake(h sha3.ShakeHash, bits int) hash.Hash {
+	return &shake{ShakeHash: h, size: bits / 8}
+}
+
+func (s *shake) Size() int { return s.size }
+
+// Sum 从副本读取输出，不影响后续写入
+func (s *shake) Sum(b []byte) []byte {
+	out := make([]byte, s.size)
+	s.ShakeHash.Clone().Read(out)
+	return append(b, out...)
+}
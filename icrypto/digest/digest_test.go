@@ -0,0 +1,41 @@
+package digest
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDigestVectors(t *testing.T) {
+	cases := []struct{ name, input, want string }{
+		{"md2", "", "8350e5a3e24c153df2275c9f80692773"},
+		{"md2", "abc", "da853b0d3f88d99b30283a69e6ded6bb"},
+		{"MD2", "abcdefghijklmnopqrstuvwxyz", "4e8ddff3650292ab5a4108c3aa47940b"},
+		{"md4", "", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"md5", "abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{"shake128-256", "", "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26"},
+		{"shake256-512", "", "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762fd75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be"},
+	}
+	for _, c := range cases {
+		h, ok := New(c.name)
+		if !ok {
+			t.Fatalf("%s 未注册", c.name)
+		}
+		// 分段写入与一次写入结果一致
+		io.Copy(h, io.LimitReader(strings.NewReader(c.input), 5))
+		io.WriteString(h, c.input[min(5, len(c.input)):])
+		if got := hex.EncodeToString(h.Sum(nil)); got != c.want {
+			t.Fatalf("%s(%q) 期望%s, 实际%s", c.name, c.input, c.want, got)
+		}
+		if h.Size() != len(c.want)/2 {
+			t.Fatalf("%s 长度错误: %d", c.name, h.Size())
+		}
+	}
+	if _, ok := New("crc32"); ok {
+		t.Fatal("不支持的算法应返回false")
+	}
+	if len(Names()) != len(hashes) {
+		t.Fatalf("算法名称不完整: %v", Names())
+	}
+}
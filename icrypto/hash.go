@@ -24,6 +24,7 @@ func HexDecode(decodeStr interface{}) dongle.Decoder {
 	}
 }
 
+// HashGenerator 按名称计算摘要，支持的名称与 digest.Names 一致，新增算法需同步 digest 包
 func HashGenerator(encryptData interface{}, encryptMode string) dongle.Encrypter {
 	// 定义一个映射表，将加密模式与加密操作关联
 	encryptMode = strings.ToLower(encryptMode)
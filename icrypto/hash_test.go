@@ -1,8 +1,11 @@
 package icrypto
 
 import (
+	"encoding/hex"
 	"fmt"
 	"testing"
+
+	"github.com/Covsj/gokit/icrypto/digest"
 )
 
 func TestHash(t *testing.T) {
@@ -32,3 +35,15 @@ func TestHmac(t *testing.T) {
 	fmt.Println(hmac.ToHexString())
 
 }
+
+// TestHashGeneratorMatchesDigest HashGenerator 与 digest 算法表的名称和结果保持一致
+func TestHashGeneratorMatchesDigest(t *testing.T) {
+	data := []byte("hello world")
+	for _, name := range digest.Names() {
+		h, _ := digest.New(name)
+		h.Write(data)
+		if got, want := HashGenerator(data, name).ToHexString(), hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("%s 与digest不一致: %s != %s", name, got, want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package iemail
 
 import (
+	"sync"
+
 	"github.com/Covsj/gokit/ihttp"
 	"github.com/Covsj/gokit/ilog"
 )
@@ -14,6 +16,9 @@ type ETempMailCli struct {
 	Email      string
 	EmailId    string
 	RecoverKey string
+
+	cli     *ihttp.Client
+	cliOnce sync.Once
 }
 
 // CliName implements IEmail
@@ -25,24 +30,30 @@ func (t *ETempMailCli) Disconnect() error {
 	return nil
 }
 
-func (t *ETempMailCli) dohttp(reqUrl, method string,
-	rawBody map[string]any, out any) (*ihttp.Response, error) {
-	headers := map[string]string{
-		"user-agent":       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36",
-		"x-requested-with": "XMLHttpRequest",
-	}
-	var err error
-	var resp *ihttp.Response
+// client 返回会话客户端，共享Cookie与默认请求头
+// 首次调用时按BaseUrl创建，之后不再修改，可并发使用
+func (t *ETempMailCli) client() *ihttp.Client {
+	t.cliOnce.Do(func() {
+		t.cli = &ihttp.Client{
+			BaseURL: t.BaseUrl,
+			Headers: map[string]string{
+				"x-requested-with": "XMLHttpRequest",
+			},
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36",
+			Cookies:   &t.CookieMap,
+		}
+	})
+	return t.cli
+}
 
-	resp, err = ihttp.Do(&ihttp.Opt{
-		URL:     reqUrl,
+func (t *ETempMailCli) dohttp(path, method string,
+	rawBody map[string]any, out any) (*ihttp.Response, error) {
+	return t.client().Do(&ihttp.Opt{
+		URL:     path,
 		Method:  method,
 		Json:    rawBody,
-		Cookies: &t.CookieMap,
-		Headers: headers,
 		RespOut: out,
 	})
-	return resp, err
 }
 
 // NewEmailCli implements IEmail
@@ -58,14 +69,14 @@ func (t *ETempMailCli) NewEmailCli(opt map[string]any) (IEmail, error) {
 		}
 	}
 
-	_, err := t.dohttp(joinURL(t.BaseUrl, "/zh"), "GET", nil, nil)
+	_, err := t.dohttp("/zh", "GET", nil, nil)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
 			"逻辑接口", "主页", "Error", err.Error())
 		return nil, err
 	}
 	out := map[string]string{}
-	_, err = t.dohttp(joinURL(t.BaseUrl, "/getEmailAddress"), "POST", map[string]any{}, &out)
+	_, err = t.dohttp("/getEmailAddress", "POST", map[string]any{}, &out)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
 			"逻辑接口", "getEmailAddress", "Error", err.Error())
@@ -95,7 +106,7 @@ func (t *ETempMailCli) GetEmailMsgs() (msgs []Msg, err error) {
 		Body    string `json:"body"`
 	}
 	list := []eTempMailMsg{}
-	_, err = t.dohttp(joinURL(t.BaseUrl, "/getInbox"), "POST", map[string]any{}, &list)
+	_, err = t.dohttp("/getInbox", "POST", map[string]any{}, &list)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
 			"逻辑接口", "getInbox", "Error", err.Error())
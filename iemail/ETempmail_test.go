@@ -9,6 +9,7 @@ import (
 )
 
 func TestEduMailSu(t *testing.T) {
+	skipLive(t)
 	ilog.Log.SetLevel(logrus.TraceLevel)
 	// Use random account generation for testing
 	cli := &ETempMailCli{}
@@ -1,8 +1,6 @@
 package iemail
 
 import (
-	"strings"
-
 	"github.com/Covsj/gokit/ihttp"
 )
 
@@ -44,7 +42,3 @@ type IEmail interface {
 	Data() map[string]any
 	Disconnect() error
 }
-
-func joinURL(base, path string) string {
-	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
-}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -19,6 +20,9 @@ type FakeCli struct {
 
 	Email     string
 	EmailName string
+
+	cli     *ihttp.Client
+	cliOnce sync.Once
 }
 
 // CliName implements IEmail
@@ -30,39 +34,41 @@ func (t *FakeCli) Disconnect() error {
 	return nil
 }
 
-func (t *FakeCli) dohttp(reqUrl, method string, rawBody map[string]any,
+// client 返回会话客户端，出错或响应为空时间隔2秒重试，最多3次
+// 首次调用时按BaseUrl创建，之后不再修改，可并发使用
+func (t *FakeCli) client() *ihttp.Client {
+	t.cliOnce.Do(func() {
+		t.cli = &ihttp.Client{
+			BaseURL:   t.BaseUrl,
+			Headers:   map[string]string{},
+			UserAgent: iutil.Random(),
+			Cookies:   &t.CookieMap,
+			Retry: &ihttp.RetryPolicy{
+				MaxAttempts: 3,
+				BaseDelay:   2 * time.Second,
+				Multiplier:  1,
+				RetryIf: func(resp *ihttp.Response, err error) bool {
+					return err != nil || resp == nil || resp.Text == ""
+				},
+			},
+		}
+	})
+	return t.cli
+}
+
+func (t *FakeCli) dohttp(path, method string, rawBody map[string]any,
 	out any) (*ihttp.Response, error) {
-	headers := map[string]string{
-		"user-agent": iutil.Random(),
-	}
+	var headers map[string]string
 	if method != "GET" {
-		headers["x-requested-with"] = "XMLHttpRequest"
-	}
-
-	var err error
-	var resp *ihttp.Response
-
-	for i := 0; i < 3; i++ {
-		resp, err = ihttp.Do(&ihttp.Opt{
-			URL:     reqUrl,
-			Method:  method,
-			Data:    rawBody,
-			Cookies: &t.CookieMap,
-			Headers: headers,
-			RespOut: out,
-		})
-		if err != nil {
-			time.Sleep(2 * time.Second)
-			continue
-		}
-		resBody := resp.Text
-		if resBody == "" {
-			time.Sleep(2 * time.Second)
-			continue
-		}
-		return resp, err
+		headers = map[string]string{"x-requested-with": "XMLHttpRequest"}
 	}
-	return resp, err
+	return t.client().Do(&ihttp.Opt{
+		URL:     path,
+		Method:  method,
+		Data:    rawBody,
+		Headers: headers,
+		RespOut: out,
+	})
 }
 
 func (t *FakeCli) NewEmailCli(opt map[string]any) (IEmail, error) {
@@ -75,7 +81,7 @@ func (t *FakeCli) NewEmailCli(opt map[string]any) (IEmail, error) {
 			t.BaseUrl = baseUrl
 		}
 	}
-	_, err := t.dohttp(joinURL(t.BaseUrl, ""), "GET", nil, nil)
+	_, err := t.dohttp("/", "GET", nil, nil)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
 			"逻辑接口", "首页", "Error", err.Error())
@@ -87,7 +93,7 @@ func (t *FakeCli) NewEmailCli(opt map[string]any) (IEmail, error) {
 	// 默认域名；如需扩展，可从 GetDomains() 中随机选择
 	t.Email = name + "@fontfee.com"
 	_, err = t.dohttp(
-		joinURL(t.BaseUrl, "/index/new-email/"),
+		"/index/new-email/",
 		"POST", map[string]any{
 			"emailInput": t.EmailName,
 			"format":     "json",
@@ -119,7 +125,7 @@ func (t *FakeCli) GetEmailMsgs() (msgs []Msg, err error) {
 	out := []fakeMsg{}
 
 	resp, err := t.dohttp(
-		joinURL(t.BaseUrl, "/index/refresh"), "GET", nil, nil)
+		"/index/refresh", "GET", nil, nil)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
 			"逻辑接口", "refresh", "Error", err.Error())
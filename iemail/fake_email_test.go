@@ -1,14 +1,17 @@
 package iemail
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Covsj/gokit/ihttp/ihttptest"
 	"github.com/Covsj/gokit/ilog"
 	"github.com/sirupsen/logrus"
 )
 
 func TestFakeEmail(t *testing.T) {
+	skipLive(t)
 	ilog.Log.SetLevel(logrus.TraceLevel)
 	// Use random account generation for testing
 	cli := &FakeCli{}
@@ -29,3 +32,37 @@ func TestFakeEmail(t *testing.T) {
 		time.Sleep(3 * time.Second)
 	}
 }
+
+// TestFakeEmailMock 使用Mock服务器离线验证创建邮箱与收信流程
+func TestFakeEmailMock(t *testing.T) {
+	srv := ihttptest.NewServer(t)
+	srv.On("GET", "/").Reply(ihttptest.Text(200, "<html></html>").WithHeader("Set-Cookie", "PHPSESSID=s1"))
+	srv.On("POST", "/index/new-email/").Header("X-Requested-With", "XMLHttpRequest").
+		Match(func(req *ihttptest.Request) bool {
+			return strings.Contains(string(req.Body), "format=json") && strings.Contains(req.Header.Get("Cookie"), "PHPSESSID=s1")
+		}).ReplyJSON(200, map[string]any{"status": "ok"})
+	srv.On("GET", "/index/refresh").Reply(
+		ihttptest.JSON(200, []any{}),
+		ihttptest.JSON(200, []map[string]string{{"od": "noreply@gokit.dev", "predmet": "验证码", "predmetZkraceny": "123456"}}),
+	)
+
+	iCli, err := (&FakeCli{}).NewEmailCli(map[string]any{"baseUrl": srv.URL})
+	if err != nil {
+		t.Fatalf("创建邮箱失败: %v", err)
+	}
+	cli := iCli.(*FakeCli)
+	cli.client().NotLog = true
+	if !strings.HasSuffix(cli.Email, "@fontfee.com") || cli.CookieMap["PHPSESSID"] != "s1" {
+		t.Fatalf("邮箱初始化错误: %+v", cli)
+	}
+
+	msgs, err := cli.GetEmailMsgs()
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("首次收信应为空: %v %v", msgs, err)
+	}
+	msgs, err = cli.GetEmailMsgs()
+	if err != nil || len(msgs) != 1 || msgs[0].From != "noreply@gokit.dev" || msgs[0].Body != "123456" || msgs[0].To != cli.Email {
+		t.Fatalf("收信错误: %+v %v", msgs, err)
+	}
+	srv.AssertExpectations()
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func TestDragonsmailImapClient_Creation(t *testing.T) {
-
+	skipLive(t)
 	ilog.Log.SetLevel(logrus.TraceLevel)
 	// Use random account generation for testing
 	cli := &ImapCli{}
@@ -0,0 +1,41 @@
+package iemail
+
+import (
+	"testing"
+
+	"github.com/Covsj/gokit/ihttp"
+)
+
+// TestTmpCliReplay 从HAR回放Mail.tm接口，离线验证注册、登录与收信流程
+func TestTmpCliReplay(t *testing.T) {
+	har, err := ihttp.LoadHAR("testdata/mailtm.har")
+	if err != nil {
+		t.Fatalf("加载HAR失败: %v", err)
+	}
+	ihttp.SetHAR(har)
+	defer ihttp.SetHAR(nil)
+
+	cli := &TmpCli{BaseUrl: TMP_MAIL_TM_API, Email: "gokit@example.dev", Password: "secret123"}
+	domains, err := cli.GetDomains()
+	if err != nil || len(domains) != 1 || domains[0] != "example.dev" {
+		t.Fatalf("获取域名失败: %v %v", domains, err)
+	}
+	if err := cli.accounts(); err != nil || cli.EmailId != "acc-1" {
+		t.Fatalf("注册失败: %v", err)
+	}
+	if err := cli.token(); err != nil || cli.EmailToken != "jwt-token" {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	msgs, err := cli.GetEmailMsgs()
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("首次收信应为空: %v %v", msgs, err)
+	}
+	msgs, err = cli.GetEmailMsgs()
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("收信失败: %v %v", msgs, err)
+	}
+	if msgs[0].From != "noreply@gokit.dev" || msgs[0].To != cli.Email || msgs[0].Body != "您的验证码是 123456" {
+		t.Fatalf("邮件内容错误: %+v", msgs[0])
+	}
+}
@@ -3,6 +3,7 @@ package iemail
 import (
 	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Covsj/gokit/ihttp"
@@ -20,6 +21,9 @@ type TmpCli struct {
 
 	EmailId    string
 	EmailToken string
+
+	cli     *ihttp.Client
+	cliOnce sync.Once
 }
 
 func (t *TmpCli) CliName() string {
@@ -30,25 +34,30 @@ func (t *TmpCli) Disconnect() error {
 	return nil
 }
 
-func (t *TmpCli) dohttp(reqUrl, method string, rawBody map[string]any, out any) (*ihttp.Response, error) {
-	headers := map[string]string{
-		"user-agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36",
-	}
-	if t.EmailToken != "" {
-		headers["Authorization"] = "Bearer " + t.EmailToken
-	}
-	var err error
-	var resp *ihttp.Response
-	resp, err = ihttp.Do(&ihttp.Opt{
-		URL:     reqUrl,
+// client 返回会话客户端，共享Cookie与认证请求头
+// 首次调用时按BaseUrl创建，之后不再修改，可并发使用
+func (t *TmpCli) client() *ihttp.Client {
+	t.cliOnce.Do(func() {
+		t.cli = &ihttp.Client{
+			BaseURL:   t.BaseUrl,
+			Headers:   map[string]string{},
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36",
+			Cookies:   &t.CookieMap,
+		}
+		if t.EmailToken != "" {
+			t.cli.SetHeader("Authorization", "Bearer "+t.EmailToken)
+		}
+	})
+	return t.cli
+}
+
+func (t *TmpCli) dohttp(path, method string, rawBody map[string]any, out any) (*ihttp.Response, error) {
+	return t.client().Do(&ihttp.Opt{
+		URL:     path,
 		Method:  method,
 		Json:    rawBody,
-		Cookies: &t.CookieMap,
-		Headers: headers,
 		RespOut: out,
 	})
-
-	return resp, err
 }
 
 func (t *TmpCli) GetDomains() ([]string, error) {
@@ -68,7 +77,7 @@ func (t *TmpCli) GetDomains() ([]string, error) {
 	}
 
 	tmp, res := domainResp{}, []string{}
-	_, err := t.dohttp(joinURL(t.BaseUrl, "/domains"), "GET", nil, &tmp)
+	_, err := t.dohttp("/domains", "GET", nil, &tmp)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
 			"逻辑接口", "domains", "Error", err.Error())
@@ -104,15 +113,7 @@ func (t *TmpCli) messageById(id string) (Msg, error) {
 	}
 
 	var tmpMsg tmpMessage
-	messageURL := joinURL(t.BaseUrl, "/messages/"+id)
-	_, err := ihttp.Do(&ihttp.Opt{
-		URL:     messageURL,
-		Method:  "GET",
-		RespOut: &tmpMsg,
-		Headers: map[string]string{
-			"Authorization": "Bearer " + t.EmailToken,
-		},
-	})
+	_, err := t.client().Get("/messages/"+id, &ihttp.Opt{RespOut: &tmpMsg})
 	if err != nil {
 		ilog.Error("TM/GW 邮箱 获取邮件内容失败", "Error", err.Error(), "id", id)
 		return Msg{}, err
@@ -133,7 +134,7 @@ func (t *TmpCli) messageById(id string) (Msg, error) {
 func (t *TmpCli) accounts() error {
 	out := map[string]any{}
 
-	_, err := t.dohttp(joinURL(t.BaseUrl, "/accounts"), "POST", map[string]any{
+	_, err := t.dohttp("/accounts", "POST", map[string]any{
 		"address":  t.Email,
 		"password": t.Password,
 	}, &out)
@@ -153,7 +154,7 @@ func (t *TmpCli) accounts() error {
 
 func (t *TmpCli) token() error {
 	out := map[string]string{}
-	_, err := t.dohttp(joinURL(t.BaseUrl, "/token"), "POST", map[string]any{
+	_, err := t.dohttp("/token", "POST", map[string]any{
 		"address":  t.Email,
 		"password": t.Password,
 	}, &out)
@@ -174,6 +175,7 @@ func (t *TmpCli) token() error {
 
 	t.EmailId = id
 	t.EmailToken = token
+	t.client().SetHeader("Authorization", "Bearer "+token)
 
 	return nil
 }
@@ -242,7 +244,7 @@ func (t *TmpCli) GetEmailMsgs() (msgs []Msg, err error) {
 
 	var out messageResp
 
-	_, err = t.dohttp(joinURL(t.BaseUrl, "/messages"),
+	_, err = t.dohttp("/messages",
 		"GET", map[string]any{}, &out)
 	if err != nil {
 		ilog.Error("邮箱内部逻辑失败", "客户端类型", t.CliName(),
@@ -1,6 +1,7 @@
 package iemail
 
 import (
+	"os"
 	"testing"
 	"time"
 
@@ -8,9 +9,18 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// skipLive 访问真实服务的测试默认跳过，设置 GOKIT_LIVE_TEST=1 且未使用 -short 时运行
+func skipLive(t *testing.T) {
+	t.Helper()
+	if testing.Short() || os.Getenv("GOKIT_LIVE_TEST") == "" {
+		t.Skip("访问真实服务，设置 GOKIT_LIVE_TEST=1 后运行")
+	}
+}
+
 // TestTmIntegration performs an integration test for the Mail.tm client.
 // It creates a new random account and fetches the initial message list.
 func TestTmIntegration(t *testing.T) {
+	skipLive(t)
 	ilog.Log.SetLevel(logrus.TraceLevel)
 	// Use random account generation for testing
 	cli := &TmpCli{}
@@ -0,0 +1,311 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Covsj/gokit/ilog"
+)
+
+// defaultBatchConcurrency 未设置 BatchOptions.Concurrency 时的并发数
+const defaultBatchConcurrency = 10
+
+// BatchOptions 批量请求选项
+type BatchOptions struct {
+	Concurrency int // 工作协程数即最大并发数，默认10
+	PerHost     int // 单个主机的最大并发数，<=0不限
+
+	// FailFast 首个失败后取消进行中与未开始的请求，默认执行全部请求并收集结果
+	FailFast bool
+	// FailOnStatus 非2xx响应视为失败，错误为 *HTTPError
+	FailOnStatus bool
+
+	// OnResult 每个请求完成时调用，可用于进度展示，可能并发调用
+	OnResult func(result *BatchResult)
+}
+
+// BatchResult 单个请求的结果
+type BatchResult struct {
+	Index    int // 在输入中的下标
+	Opt      *Opt
+	Response *Response
+	Err      error
+	Elapsed  time.Duration // 请求耗时，含重试，不含排队等待
+	Started  bool          // 是否已开始执行，FailFast取消的请求为false；nil配置项视为已执行并失败
+}
+
+// BatchReport 批量请求结果与汇总耗时
+type BatchReport struct {
+	Results []*BatchResult // 与输入顺序一致
+
+	Succeeded int
+	Failed    int // 已执行但失败的请求数
+	Canceled  int // 因FailFast或ctx取消未执行的请求数
+
+	Total time.Duration // 整批耗时
+	Min   time.Duration // 以下统计仅包含已执行的请求
+	Max   time.Duration
+	Avg   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+}
+
+// Err 合并所有失败请求的错误，全部成功时为nil
+func (r *BatchReport) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Err != nil && res.Started {
+			errs = append(errs, fmt.Errorf("第%d个请求: %w", res.Index, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Batch 并发执行多个请求，结果顺序与opts一致
+// FailFast模式下返回首个失败的错误，否则仅在ctx取消时返回错误，各请求的错误见 BatchReport.Results 与 BatchReport.Err
+//
+// 使用示例:
+//
+//	report, err := ihttp.Batch(ctx, opts, &ihttp.BatchOptions{Concurrency: 20, PerHost: 5})
+//	for _, r := range report.Results { ... }
+func Batch(ctx context.Context, opts []*Opt, options *BatchOptions) (*BatchReport, error) {
+	return runBatch(ctx, opts, options)
+}
+
+// Batch 使用会话配置并发执行多个请求，Opt.URL可为相对路径，BatchResult.Opt为合并会话配置后的副本
+func (c *Client) Batch(ctx context.Context, opts []*Opt, options *BatchOptions) (*BatchReport, error) {
+	prepared := make([]*Opt, len(opts))
+	for i, opt := range opts {
+		if opt != nil {
+			prepared[i] = c.prepare(opt)
+		}
+	}
+	return runBatch(ctx, prepared, options)
+}
+
+func runBatch(ctx context.Context, opts []*Opt, options *BatchOptions) (*BatchReport, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var o BatchOptions
+	if options != nil {
+		o = *options
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultBatchConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	report := &BatchReport{Results: make([]*BatchResult, len(opts))}
+	queue := newBatchQueue(ctx, opts, o.PerHost)
+	stop := context.AfterFunc(ctx, queue.close)
+	defer stop()
+
+	var (
+		wg       sync.WaitGroup
+		firstMu  sync.Mutex
+		firstErr error
+	)
+	run := func(i int) {
+		opt := opts[i]
+		result := report.Results[i]
+		result.Started = true
+		if opt == nil {
+			result.Err = errors.New("空配置项")
+		} else {
+			begin := time.Now()
+			result.Response, result.Err = DoContext(ctx, opt)
+			result.Elapsed = time.Since(begin)
+			if result.Err == nil && o.FailOnStatus && !result.Response.IsSuccess() {
+				result.Err = newHTTPError(opt, result.Response)
+			}
+		}
+		if o.OnResult != nil {
+			o.OnResult(result)
+		}
+		if result.Err != nil && o.FailFast {
+			firstMu.Lock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("第%d个请求失败: %w", i, result.Err)
+				cancel()
+			}
+			firstMu.Unlock()
+		}
+	}
+
+	for i, opt := range opts {
+		report.Results[i] = &BatchResult{Index: i, Opt: opt}
+	}
+	// 固定数量的工作协程按顺序领取请求
+	for w := 0; w < min(o.Concurrency, len(opts)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, host, ok := queue.next()
+				if !ok {
+					return
+				}
+				run(i)
+				queue.done(host)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, result := range report.Results {
+		if !result.Started {
+			result.Err = ctx.Err()
+		}
+	}
+
+	report.Total = time.Since(start)
+	report.summarize()
+	if !batchNotLog(opts) {
+		ilog.Debug("批量请求", "请求数", len(opts), "成功", report.Succeeded, "失败", report.Failed,
+			"取消", report.Canceled, "总耗时", report.Total.String(), "平均耗时", report.Avg.String(),
+			"P95耗时", report.P95.String())
+	}
+
+	if firstErr != nil {
+		return report, firstErr
+	}
+	return report, ctx.Err()
+}
+
+// summarize 统计成功、失败数与耗时分布
+func (r *BatchReport) summarize() {
+	var elapsed []time.Duration
+	var sum time.Duration
+	for _, res := range r.Results {
+		switch {
+		case !res.Started:
+			r.Canceled++
+			continue
+		case res.Err != nil:
+			r.Failed++
+		default:
+			r.Succeeded++
+		}
+		if res.Opt != nil {
+			elapsed = append(elapsed, res.Elapsed)
+			sum += res.Elapsed
+		}
+	}
+	if len(elapsed) == 0 {
+		return
+	}
+	sort.Slice(elapsed, func(i, j int) bool { return elapsed[i] < elapsed[j] })
+	r.Min, r.Max = elapsed[0], elapsed[len(elapsed)-1]
+	r.Avg = sum / time.Duration(len(elapsed))
+	r.P50 = percentile(elapsed, 0.5)
+	r.P95 = percentile(elapsed, 0.95)
+}
+
+// percentile 返回已排序耗时的分位数(最近秩法)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.999999) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// batchNotLog 所有请求都关闭日志时不输出汇总日志
+func batchNotLog(opts []*Opt) bool {
+	for _, opt := range opts {
+		if opt != nil && !opt.NotLog {
+			return false
+		}
+	}
+	return true
+}
+
+// batchHost 返回用于按主机限流的主机名，无法解析时返回空
+func batchHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// batchQueue 按输入顺序分发请求下标，跳过已达到主机并发上限的请求，避免工作协程阻塞在繁忙主机上
+type batchQueue struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	cond    *sync.Cond
+	perHost int
+	queues  map[string][]int // 各主机待执行的下标，按输入顺序排列
+	running map[string]int   // 各主机执行中的请求数
+	closed  bool             // ctx已取消，唤醒等待中的工作协程
+}
+
+func newBatchQueue(ctx context.Context, opts []*Opt, perHost int) *batchQueue {
+	q := &batchQueue{ctx: ctx, perHost: perHost, queues: map[string][]int{}, running: map[string]int{}}
+	q.cond = sync.NewCond(&q.mu)
+	for i, opt := range opts {
+		host := ""
+		if opt != nil {
+			host = batchHost(opt.URL)
+		}
+		q.queues[host] = append(q.queues[host], i)
+	}
+	return q
+}
+
+// next 返回下一个可执行的请求下标及其主机，全部分发完毕或已取消时返回false
+func (q *batchQueue) next() (int, string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && q.ctx.Err() == nil && len(q.queues) > 0 {
+		index, host := -1, ""
+		for h, queue := range q.queues {
+			if q.perHost > 0 && q.running[h] >= q.perHost {
+				continue
+			}
+			if index < 0 || queue[0] < index {
+				index, host = queue[0], h
+			}
+		}
+		if index < 0 {
+			// 所有剩余请求的主机都已满，等待名额释放
+			q.cond.Wait()
+			continue
+		}
+		if rest := q.queues[host][1:]; len(rest) > 0 {
+			q.queues[host] = rest
+		} else {
+			delete(q.queues, host)
+		}
+		q.running[host]++
+		return index, host, true
+	}
+	return 0, "", false
+}
+
+// done 归还主机名额
+func (q *batchQueue) done(host string) {
+	q.mu.Lock()
+	q.running[host]--
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// close 停止分发，唤醒等待中的工作协程
+func (q *batchQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
@@ -0,0 +1,199 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyServer 统计同时处理中的请求数峰值
+type concurrencyServer struct {
+	running atomic.Int32
+	peak    atomic.Int32
+	delay   time.Duration
+}
+
+func (s *concurrencyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := s.running.Add(1)
+	defer s.running.Add(-1)
+	for {
+		p := s.peak.Load()
+		if n <= p || s.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(s.delay):
+	case <-r.Context().Done():
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/fail") {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write([]byte(r.URL.Path))
+}
+
+func TestBatchOrderAndConcurrency(t *testing.T) {
+	s := &concurrencyServer{delay: 20 * time.Millisecond}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	opts := make([]*Opt, 20)
+	for i := range opts {
+		opts[i] = &Opt{URL: fmt.Sprintf("%s/item/%d", srv.URL, i), NotLog: true}
+	}
+	var done atomic.Int32
+	report, err := Batch(context.Background(), opts, &BatchOptions{
+		Concurrency: 4,
+		OnResult:    func(*BatchResult) { done.Add(1) },
+	})
+	if err != nil || report.Err() != nil {
+		t.Fatalf("批量请求失败: %v %v", err, report.Err())
+	}
+	for i, res := range report.Results {
+		if res.Index != i || res.Response.Text != fmt.Sprintf("/item/%d", i) || res.Opt != opts[i] {
+			t.Fatalf("第%d个结果顺序错误: %+v", i, res)
+		}
+	}
+	if s.peak.Load() > 4 || s.peak.Load() < 2 {
+		t.Fatalf("并发数未受限: %d", s.peak.Load())
+	}
+	if report.Succeeded != 20 || done.Load() != 20 {
+		t.Fatalf("成功数错误: %d %d", report.Succeeded, done.Load())
+	}
+	if report.Min < s.delay || report.Max < report.P95 || report.P95 < report.P50 || report.Avg < report.Min ||
+		report.Total < 5*s.delay {
+		t.Fatalf("耗时统计错误: %+v", report)
+	}
+}
+
+func TestBatchPerHost(t *testing.T) {
+	a := &concurrencyServer{delay: 20 * time.Millisecond}
+	b := &concurrencyServer{delay: 20 * time.Millisecond}
+	srvA, srvB := httptest.NewServer(a), httptest.NewServer(b)
+	defer srvA.Close()
+	defer srvB.Close()
+
+	// 会话相对路径与绝对地址混合
+	cli := NewClient(srvA.URL)
+	cli.NotLog = true
+	var opts []*Opt
+	for i := 0; i < 10; i++ {
+		opts = append(opts, &Opt{URL: "/a"}, &Opt{URL: srvB.URL + "/b"})
+	}
+	report, err := cli.Batch(context.Background(), opts, &BatchOptions{Concurrency: 10, PerHost: 2})
+	if err != nil || report.Succeeded != 20 {
+		t.Fatalf("批量请求失败: %v", err)
+	}
+	if a.peak.Load() > 2 || b.peak.Load() > 2 {
+		t.Fatalf("单主机并发未受限: %d %d", a.peak.Load(), b.peak.Load())
+	}
+	if b.peak.Load() < 2 {
+		t.Fatalf("主机之间不应互相阻塞: %d", b.peak.Load())
+	}
+}
+
+func TestBatchFailModes(t *testing.T) {
+	s := &concurrencyServer{delay: 10 * time.Millisecond}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	build := func() []*Opt {
+		opts := []*Opt{{URL: srv.URL + "/fail"}, nil}
+		for i := 0; i < 10; i++ {
+			opts = append(opts, &Opt{URL: fmt.Sprintf("%s/ok/%d", srv.URL, i)})
+		}
+		for _, o := range opts {
+			if o != nil {
+				o.NotLog = true
+			}
+		}
+		return opts
+	}
+
+	// 收集全部结果
+	report, err := Batch(context.Background(), build(), &BatchOptions{Concurrency: 2, FailOnStatus: true})
+	var httpErr *HTTPError
+	if err != nil || report.Failed != 2 || report.Succeeded != 10 || report.Canceled != 0 {
+		t.Fatalf("收集模式统计错误: %v %+v", err, report)
+	}
+	if !errors.As(report.Results[0].Err, &httpErr) || httpErr.StatusCode != 500 || report.Results[1].Err == nil {
+		t.Fatalf("失败结果错误: %v %v", report.Results[0].Err, report.Results[1].Err)
+	}
+	if !errors.As(report.Err(), &httpErr) {
+		t.Fatalf("合并错误应可展开: %v", report.Err())
+	}
+
+	// 首个失败后取消剩余请求
+	report, err = Batch(context.Background(), build(), &BatchOptions{Concurrency: 1, FailFast: true, FailOnStatus: true})
+	if err == nil || report.Canceled == 0 || report.Succeeded == 10 {
+		t.Fatalf("FailFast未生效: %v %+v", err, report)
+	}
+	for _, res := range report.Results {
+		if !res.Started && !errors.Is(res.Err, context.Canceled) {
+			t.Fatalf("未执行的请求应返回取消错误: %+v", res)
+		}
+	}
+
+	// 外部ctx取消
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	report, err = Batch(ctx, build(), &BatchOptions{Concurrency: 1, OnResult: func(*BatchResult) { once.Do(cancel) }})
+	if !errors.Is(err, context.Canceled) || report.Canceled == 0 {
+		t.Fatalf("ctx取消未生效: %v %+v", err, report)
+	}
+}
+
+func TestBatchNilOptAndWorkers(t *testing.T) {
+	s := &concurrencyServer{delay: 5 * time.Millisecond}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// nil配置项与普通失败一致：回调通知并触发FailFast
+	var notified atomic.Int32
+	opts := []*Opt{nil}
+	for i := 0; i < 5; i++ {
+		opts = append(opts, &Opt{URL: srv.URL + "/ok", NotLog: true})
+	}
+	report, err := Batch(context.Background(), opts, &BatchOptions{
+		Concurrency: 1,
+		FailFast:    true,
+		OnResult:    func(*BatchResult) { notified.Add(1) },
+	})
+	if err == nil || !report.Results[0].Started || report.Results[0].Err == nil || notified.Load() != 1 {
+		t.Fatalf("nil配置项应触发FailFast: %v %d", err, notified.Load())
+	}
+	if report.Failed != 1 || report.Canceled != 5 {
+		t.Fatalf("统计错误: %+v", report)
+	}
+
+	// 协程数量由Concurrency决定，与请求数无关
+	opts = make([]*Opt, 300)
+	for i := range opts {
+		opts[i] = &Opt{URL: srv.URL + "/ok", NotLog: true}
+	}
+	base := runtime.NumGoroutine()
+	var peak atomic.Int32
+	report, err = Batch(context.Background(), opts, &BatchOptions{
+		Concurrency: 4,
+		OnResult: func(*BatchResult) {
+			if n := int32(runtime.NumGoroutine() - base); n > peak.Load() {
+				peak.Store(n)
+			}
+		},
+	})
+	if err != nil || report.Succeeded != 300 {
+		t.Fatalf("批量请求失败: %v", err)
+	}
+	if peak.Load() > 50 {
+		t.Fatalf("协程数量过多: %d", peak.Load())
+	}
+}
@@ -0,0 +1,475 @@
+package ihttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Covsj/gokit/icache"
+)
+
+// CacheStatus 响应的缓存状态
+type CacheStatus string
+
+const (
+	CacheHit         CacheStatus = "HIT"         // 缓存新鲜，未发送请求
+	CacheRevalidated CacheStatus = "REVALIDATED" // 条件请求返回304，使用缓存内容
+	CacheMiss        CacheStatus = "MISS"        // 未命中或缓存不可用，响应来自服务端
+	CacheBypass      CacheStatus = "BYPASS"      // 本次请求跳过缓存
+)
+
+// cacheKeyPrefix 缓存键前缀，避免与共用FreeCache的其他数据冲突
+const cacheKeyPrefix = "ihttp:"
+
+// heuristicStatus 可按启发式规则缓存的状态码(RFC 7231 6.1)
+var heuristicStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// HTTPCache HTTP响应缓存，遵循RFC 7234(私有缓存语义)，条目存放在 icache.FreeCache 中
+// 仅缓存GET请求；支持Cache-Control、Expires、ETag/If-None-Match、Last-Modified/If-Modified-Since与Vary
+// 不安全方法(POST/PUT/DELETE等)成功后会使同URL的缓存失效；Stream模式的响应只有完整读取后才会写入缓存
+// 携带Authorization或Cookie的请求按凭证分别缓存，不同会话共用缓存时互不可见
+// 新鲜的缓存在限流之前返回，不占用限流名额
+//
+// 使用示例:
+//
+//	cache := ihttp.NewHTTPCache(icache.NewFreeCache(32 * 1024 * 1024))
+//	cache.TTL = 10 * time.Minute // 可选，忽略响应头的缓存指令
+//	resp, _ := ihttp.Get(url, &ihttp.Opt{Cache: cache})
+//	resp.CacheStatus // HIT / REVALIDATED / MISS
+type HTTPCache struct {
+	// TTL 显式缓存时间，>0时忽略响应中的缓存指令，可被 Opt.CacheTTL 覆盖
+	TTL time.Duration
+
+	store *icache.FreeCache
+}
+
+// NewHTTPCache 创建使用store存储的HTTP缓存，store可与其他业务共用
+func NewHTTPCache(store *icache.FreeCache) *HTTPCache {
+	return &HTTPCache{store: store}
+}
+
+// Delete 删除URL对应的缓存，仅作用于不带凭证的请求，带凭证的缓存可通过同凭证的不安全方法请求失效
+func (c *HTTPCache) Delete(rawURL string) {
+	c.store.Delete(cacheKeyPrefix + http.MethodGet + " " + rawURL)
+}
+
+// cacheKey 返回请求的缓存键，携带凭证时追加凭证摘要，避免不同会话共享响应
+func cacheKey(req *http.Request) string {
+	key := cacheKeyPrefix + http.MethodGet + " " + req.URL.String()
+	auth, cookie := req.Header.Values("Authorization"), req.Header.Values("Cookie")
+	if len(auth) == 0 && len(cookie) == 0 {
+		return key
+	}
+	h := sha256.New()
+	for _, v := range auth {
+		io.WriteString(h, "A:"+v+"\n")
+	}
+	for _, v := range cookie {
+		io.WriteString(h, "C:"+v+"\n")
+	}
+	return key + " " + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// cacheEntry 缓存条目，以JSON保存在FreeCache中
+type cacheEntry struct {
+	StatusCode int
+	Proto      string
+	Header     http.Header
+	Body       []byte
+	Vary       map[string]string // Vary中列出的请求头取值
+
+	Stored   time.Time     // 写入或重新验证的时间
+	InitAge  time.Duration // 写入时已有的年龄
+	Lifetime time.Duration // 新鲜期
+}
+
+func (c *HTTPCache) load(key string) (*cacheEntry, bool) {
+	var e cacheEntry
+	if !c.store.Get(key, &e) {
+		return nil, false
+	}
+	return &e, true
+}
+
+// save 写入缓存，有验证器的条目过期后仍保留用于重新验证，由FreeCache按容量淘汰
+func (c *HTTPCache) save(key string, e *cacheEntry) {
+	ttl := e.Lifetime
+	if e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != "" {
+		ttl = 0
+	}
+	// 超过FreeCache单条上限时写入失败，按未缓存处理
+	c.store.SetWithTTL(key, e, ttl)
+}
+
+// age 返回条目当前年龄
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	return e.InitAge + now.Sub(e.Stored)
+}
+
+// fresh 判断条目是否新鲜，maxAge为请求Cache-Control中的max-age，<0表示未设置
+func (e *cacheEntry) fresh(now time.Time, maxAge time.Duration) bool {
+	age := e.age(now)
+	if maxAge >= 0 && age > maxAge {
+		return false
+	}
+	return age < e.Lifetime
+}
+
+// varyMatch 判断请求是否与Vary记录一致
+func (e *cacheEntry) varyMatch(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// response 由缓存条目生成响应
+func (e *cacheEntry) response(req *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+	major, minor, ok := http.ParseHTTPVersion(e.Proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         e.Proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// hit 在限流与签名之前查找新鲜缓存，命中时返回缓存响应
+// jar为客户端的Cookie容器，其Cookie由http.Client在发送时才加入请求，需计入缓存键以免跨会话命中
+// 重定向响应仍交给客户端处理，以便后续跳转正常限流
+func (c *HTTPCache) hit(req *http.Request, opt *Opt, jar http.CookieJar) *http.Response {
+	if c == nil || opt.NoCache || req.Method != http.MethodGet || bypassCache(req) {
+		return nil
+	}
+	keyReq := req
+	if jar != nil {
+		if cookies := jar.Cookies(req.URL); len(cookies) > 0 {
+			keyReq = req.Clone(req.Context())
+			for _, ck := range cookies {
+				keyReq.AddCookie(ck)
+			}
+		}
+	}
+	now := time.Now()
+	entry, ok := c.lookup(keyReq, cacheKey(keyReq), now)
+	if !ok || !entry.fresh(now, requestMaxAge(req)) || noCacheRequested(req) {
+		return nil
+	}
+	if entry.StatusCode >= 300 && entry.StatusCode < 400 {
+		return nil
+	}
+	return entry.response(req, now)
+}
+
+// lookup 读取与请求Vary一致的缓存条目
+func (c *HTTPCache) lookup(req *http.Request, key string, now time.Time) (*cacheEntry, bool) {
+	entry, ok := c.load(key)
+	if !ok || !entry.varyMatch(req) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// bypassCache 请求要求不使用缓存，或调用方自行设置了条件请求/Range
+func bypassCache(req *http.Request) bool {
+	_, noStore := parseCacheControl(req.Header)["no-store"]
+	return noStore || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+}
+
+// noCacheRequested 请求要求向服务端重新验证
+func noCacheRequested(req *http.Request) bool {
+	_, noCache := parseCacheControl(req.Header)["no-cache"]
+	return noCache || strings.Contains(strings.ToLower(req.Header.Get("Pragma")), "no-cache")
+}
+
+// requestMaxAge 返回请求Cache-Control中的max-age，未设置时返回-1
+func requestMaxAge(req *http.Request) time.Duration {
+	if v, ok := parseCacheControl(req.Header)["max-age"]; ok {
+		if sec, err := strconv.Atoi(v); err == nil {
+			return time.Duration(sec) * time.Second
+		}
+	}
+	return -1
+}
+
+// wrap 返回经过缓存的客户端副本，本次请求的缓存状态写入status
+func (c *HTTPCache) wrap(client *http.Client, opt *Opt, status *CacheStatus) *http.Client {
+	if c == nil {
+		return client
+	}
+	if opt.NoCache {
+		*status = CacheBypass
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ttl := c.TTL
+	if opt.CacheTTL > 0 {
+		ttl = opt.CacheTTL
+	}
+	cl := *client
+	cl.Transport = &cacheTransport{cache: c, base: base, ttl: ttl, status: status}
+	return &cl
+}
+
+var (
+	httpCacheMu sync.RWMutex
+	httpCache   *HTTPCache
+)
+
+// SetHTTPCache 设置全局HTTP缓存，Opt.Cache 未设置时使用，传nil关闭
+func SetHTTPCache(c *HTTPCache) {
+	httpCacheMu.Lock()
+	defer httpCacheMu.Unlock()
+	httpCache = c
+}
+
+// resolveHTTPCache 返回本次请求使用的缓存
+func resolveHTTPCache(opt *Opt) *HTTPCache {
+	if opt.Cache != nil {
+		return opt.Cache
+	}
+	httpCacheMu.RLock()
+	defer httpCacheMu.RUnlock()
+	return httpCache
+}
+
+// cacheTransport 在Transport层读写缓存，重定向的每一跳分别缓存
+type cacheTransport struct {
+	cache  *HTTPCache
+	base   http.RoundTripper
+	ttl    time.Duration
+	status *CacheStatus
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < 400 {
+			t.cache.Delete(req.URL.String())
+			t.cache.store.Delete(cacheKey(req))
+		}
+		return resp, err
+	}
+
+	if bypassCache(req) {
+		*t.status = CacheBypass
+		return t.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	now := time.Now()
+	entry, ok := t.cache.lookup(req, key, now)
+	if ok && !noCacheRequested(req) && entry.fresh(now, requestMaxAge(req)) {
+		*t.status = CacheHit
+		return entry.response(req, now), nil
+	}
+
+	// 过期条目带验证器时发送条件请求
+	validating := false
+	sent := req
+	if ok {
+		etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			sent = req.Clone(req.Context())
+			if etag != "" {
+				sent.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				sent.Header.Set("If-Modified-Since", lastModified)
+			}
+			validating = true
+		}
+	}
+
+	resp, err := t.base.RoundTrip(sent)
+	if err != nil {
+		return nil, err
+	}
+	respTime := time.Now()
+
+	if validating && resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		entry.revalidate(resp.Header, now, respTime, t.ttl)
+		t.cache.save(key, entry)
+		*t.status = CacheRevalidated
+		return entry.response(req, respTime), nil
+	}
+
+	*t.status = CacheMiss
+	lifetime, storable := cacheLifetime(resp, respTime, t.ttl)
+	if !storable {
+		if ok {
+			t.cache.store.Delete(key)
+		}
+		return resp, nil
+	}
+
+	// 缓存解压后的内容，完整读取后写入
+	initAge := currentAge(resp.Header, now, respTime)
+	body := &lockedBuffer{}
+	resp.Body = &teeBody{ReadCloser: decodeBody(resp), buf: body, done: func(eof bool) {
+		if !eof {
+			return
+		}
+		header := resp.Header.Clone()
+		header.Del("Set-Cookie")
+		t.cache.save(key, &cacheEntry{
+			StatusCode: resp.StatusCode,
+			Proto:      resp.Proto,
+			Header:     header,
+			Body:       body.bytes(),
+			Vary:       varyValues(req, resp.Header),
+			Stored:     respTime,
+			InitAge:    initAge,
+			Lifetime:   lifetime,
+		})
+	}}
+	return resp, nil
+}
+
+// revalidate 使用304响应更新条目
+func (e *cacheEntry) revalidate(header http.Header, reqTime, respTime time.Time, ttl time.Duration) {
+	for k, v := range header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Set-Cookie":
+			continue
+		}
+		e.Header[k] = v
+	}
+	e.Stored = respTime
+	e.InitAge = currentAge(header, reqTime, respTime)
+	if ttl > 0 {
+		e.Lifetime = ttl
+		return
+	}
+	e.Lifetime = freshnessLifetime(e.Header, parseCacheControl(e.Header), respTime)
+}
+
+// cacheLifetime 计算响应的新鲜期，storable为false表示不可缓存
+// ttl>0时忽略响应中的缓存指令
+func cacheLifetime(resp *http.Response, respTime time.Time, ttl time.Duration) (time.Duration, bool) {
+	if !heuristicStatus[resp.StatusCode] || resp.Header.Get("Vary") == "*" {
+		return 0, false
+	}
+	if ttl > 0 {
+		return ttl, true
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return 0, false
+	}
+	lifetime := freshnessLifetime(resp.Header, cc, respTime)
+	hasValidator := resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+	return lifetime, lifetime > 0 || hasValidator
+}
+
+// freshnessLifetime 按 max-age > Expires > Last-Modified启发式 的顺序计算新鲜期
+func freshnessLifetime(header http.Header, cc map[string]string, respTime time.Time) time.Duration {
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := cc["max-age"]; ok {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+
+	date := respTime
+	if d, err := http.ParseTime(header.Get("Date")); err == nil {
+		date = d
+	}
+	if v := header.Get("Expires"); v != "" {
+		// 无法解析的Expires视为已过期
+		exp, err := http.ParseTime(v)
+		if err != nil || !exp.After(date) {
+			return 0
+		}
+		return exp.Sub(date)
+	}
+	if lm, err := http.ParseTime(header.Get("Last-Modified")); err == nil && date.After(lm) {
+		return date.Sub(lm) / 10
+	}
+	return 0
+}
+
+// currentAge 计算响应到达时的年龄(RFC 7234 4.2.3)
+func currentAge(header http.Header, reqTime, respTime time.Time) time.Duration {
+	var apparent time.Duration
+	if d, err := http.ParseTime(header.Get("Date")); err == nil && respTime.After(d) {
+		apparent = respTime.Sub(d)
+	}
+	if sec, err := strconv.Atoi(header.Get("Age")); err == nil && sec > 0 {
+		corrected := time.Duration(sec)*time.Second + respTime.Sub(reqTime)
+		if corrected > apparent {
+			return corrected
+		}
+	}
+	return apparent
+}
+
+// parseCacheControl 解析Cache-Control，指令名转为小写
+func parseCacheControl(header http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+// varyValues 记录Vary中列出的请求头取值
+func varyValues(req *http.Request, header http.Header) map[string]string {
+	values := map[string]string{}
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				values[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+			}
+		}
+	}
+	return values
+}
+
+// isSafeMethod 判断是否为安全方法
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package ihttp
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Covsj/gokit/icache"
+)
+
+func newCacheServer(hits *int32) *httptest.Server {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "sid=1")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/gzip":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			fmt.Fprintf(zw, "gzip-%d", n)
+			zw.Close()
+			return
+		}
+		fmt.Fprintf(w, "%s-%d", r.URL.Path, n)
+	}))
+}
+
+func TestHTTPCache(t *testing.T) {
+	var hits int32
+	srv := newCacheServer(&hits)
+	defer srv.Close()
+	cache := NewHTTPCache(icache.NewFreeCache(1024 * 1024))
+
+	get := func(path string, opt *Opt) *Response {
+		t.Helper()
+		if opt == nil {
+			opt = &Opt{}
+		}
+		opt.Cache, opt.NotLog = cache, true
+		resp, err := Get(srv.URL+path, opt)
+		if err != nil {
+			t.Fatalf("%s 请求失败: %v", path, err)
+		}
+		return resp
+	}
+	expect := func(resp *Response, status CacheStatus, text string) {
+		t.Helper()
+		if resp.CacheStatus != status || resp.Text != text {
+			t.Fatalf("期望 %s %q, 实际 %s %q", status, text, resp.CacheStatus, resp.Text)
+		}
+	}
+
+	expect(get("/max-age", nil), CacheMiss, "/max-age-1")
+	resp := get("/max-age", nil)
+	expect(resp, CacheHit, "/max-age-1")
+	if !resp.FromCache() || http.Header(resp.Headers).Get("Age") == "" || http.Header(resp.Headers).Get("Set-Cookie") != "" {
+		t.Fatalf("缓存响应头错误: %v", resp.Headers)
+	}
+	// 请求要求max-age=0时重新获取
+	expect(get("/max-age", &Opt{Headers: map[string]string{"Cache-Control": "max-age=0"}}), CacheMiss, "/max-age-2")
+	// 跳过缓存
+	expect(get("/max-age", &Opt{NoCache: true}), CacheBypass, "/max-age-3")
+	expect(get("/max-age", nil), CacheHit, "/max-age-2")
+
+	expect(get("/etag", nil), CacheMiss, "/etag-4")
+	expect(get("/etag", nil), CacheRevalidated, "/etag-4")
+	expect(get("/last-modified", nil), CacheMiss, "/last-modified-6")
+	expect(get("/last-modified", nil), CacheRevalidated, "/last-modified-6")
+
+	expect(get("/no-store", nil), CacheMiss, "/no-store-8")
+	expect(get("/no-store", nil), CacheMiss, "/no-store-9")
+
+	// Vary请求头不同视为未命中
+	en := &Opt{Headers: map[string]string{"Accept-Language": "en"}}
+	expect(get("/vary", en), CacheMiss, "/vary-10")
+	expect(get("/vary", &Opt{Headers: map[string]string{"Accept-Language": "zh"}}), CacheMiss, "/vary-11")
+	expect(get("/vary", &Opt{Headers: map[string]string{"Accept-Language": "zh"}}), CacheHit, "/vary-11")
+
+	// 缓存解压后的内容
+	gz := func() *Opt { return &Opt{Headers: map[string]string{"Accept-Encoding": "gzip"}} }
+	expect(get("/gzip", gz()), CacheMiss, "gzip-12")
+	expect(get("/gzip", gz()), CacheHit, "gzip-12")
+
+	if hits != 12 {
+		t.Fatalf("服务端请求次数错误: %d", hits)
+	}
+}
+
+func TestHTTPCacheTTLAndInvalidate(t *testing.T) {
+	var hits int32
+	srv := newCacheServer(&hits)
+	defer srv.Close()
+	cli := NewClient(srv.URL)
+	cli.NotLog = true
+	cli.Cache = NewHTTPCache(icache.NewFreeCache(1024 * 1024))
+
+	// 响应无缓存指令时按显式TTL缓存
+	resp, _ := cli.Get("/plain", &Opt{CacheTTL: time.Minute})
+	if resp.CacheStatus != CacheMiss {
+		t.Fatalf("首次请求应未命中: %s", resp.CacheStatus)
+	}
+	resp, _ = cli.Get("/plain", nil)
+	if resp.CacheStatus != CacheHit || resp.Text != "/plain-1" {
+		t.Fatalf("显式TTL未生效: %s %s", resp.CacheStatus, resp.Text)
+	}
+
+	// 不安全方法成功后缓存失效
+	if _, err := cli.Post("/plain", &Opt{Json: map[string]int{"a": 1}}); err != nil {
+		t.Fatalf("POST失败: %v", err)
+	}
+	resp, _ = cli.Get("/plain", nil)
+	if resp.CacheStatus != CacheMiss || resp.Text != "/plain-3" {
+		t.Fatalf("POST后缓存应失效: %s %s", resp.CacheStatus, resp.Text)
+	}
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	now := time.Now()
+	date := now.UTC().Format(http.TimeFormat)
+	cases := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=120"}}, 2 * time.Minute},
+		{http.Header{"Cache-Control": {"no-cache, max-age=120"}}, 0},
+		{http.Header{"Date": {date}, "Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)}}, time.Hour},
+		{http.Header{"Expires": {"0"}}, 0},
+		{http.Header{"Date": {date}, "Last-Modified": {now.Add(-10 * time.Hour).UTC().Format(http.TimeFormat)}}, time.Hour},
+	}
+	for i, c := range cases {
+		got := freshnessLifetime(c.header, parseCacheControl(c.header), now)
+		if got.Round(time.Second) != c.want {
+			t.Errorf("用例%d: 期望 %v, 实际 %v", i, c.want, got)
+		}
+	}
+}
+
+func TestHTTPCacheCredentialIsolation(t *testing.T) {
+	var hits int32
+	srv := newCacheServer(&hits)
+	defer srv.Close()
+	cache := NewHTTPCache(icache.NewFreeCache(1024 * 1024))
+
+	session := func(token string) *Client {
+		cli := NewClient(srv.URL)
+		cli.NotLog = true
+		cli.Cache = cache
+		cli.Jar = nil // 响应的Set-Cookie会改变会话凭证，此处仅比较Authorization
+		cli.SetHeader("Authorization", "Bearer "+token)
+		return cli
+	}
+	alice, bob := session("alice"), session("bob")
+
+	resp, _ := alice.Get("/max-age", nil)
+	if resp.CacheStatus != CacheMiss || resp.Text != "/max-age-1" {
+		t.Fatalf("首次请求应未命中: %s %s", resp.CacheStatus, resp.Text)
+	}
+	resp, _ = bob.Get("/max-age", nil)
+	if resp.CacheStatus != CacheMiss || resp.Text != "/max-age-2" {
+		t.Fatalf("不同凭证不应共享缓存: %s %s", resp.CacheStatus, resp.Text)
+	}
+	resp, _ = alice.Get("/max-age", nil)
+	if resp.CacheStatus != CacheHit || resp.Text != "/max-age-1" {
+		t.Fatalf("同一凭证应命中自己的缓存: %s %s", resp.CacheStatus, resp.Text)
+	}
+	resp, _ = Get(srv.URL+"/max-age", &Opt{Cache: cache, NotLog: true, Headers: map[string]string{"Cookie": "sid=1"}})
+	if resp.CacheStatus != CacheMiss || resp.Text != "/max-age-3" {
+		t.Fatalf("携带Cookie的请求不应命中其他会话的缓存: %s %s", resp.CacheStatus, resp.Text)
+	}
+}
+
+func TestHTTPCacheJarIsolation(t *testing.T) {
+	var hits int32
+	srv := newCacheServer(&hits)
+	defer srv.Close()
+	cache := NewHTTPCache(icache.NewFreeCache(1024 * 1024))
+
+	resp, _ := Get(srv.URL+"/vary", &Opt{Cache: cache, NotLog: true})
+	if resp.CacheStatus != CacheMiss || resp.Text != "/vary-1" {
+		t.Fatalf("首次请求应未命中: %s %s", resp.CacheStatus, resp.Text)
+	}
+
+	// Cookie容器中的Cookie在发送时才加入请求，匿名缓存不应返回给携带会话的请求
+	jar := NewCookieJar()
+	u, _ := url.Parse(srv.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "sid", Value: "alice"}})
+	opt := &Opt{Cache: cache, Jar: jar, NotLog: true}
+	resp, _ = Get(srv.URL+"/vary", opt)
+	if resp.CacheStatus != CacheMiss || resp.Text != "/vary-2" {
+		t.Fatalf("携带Cookie容器的请求不应命中匿名缓存: %s %s", resp.CacheStatus, resp.Text)
+	}
+	resp, _ = Get(srv.URL+"/vary", opt)
+	if resp.CacheStatus != CacheHit || resp.Text != "/vary-2" {
+		t.Fatalf("同一会话应命中自己的缓存: %s %s", resp.CacheStatus, resp.Text)
+	}
+	resp, _ = Get(srv.URL+"/vary", &Opt{Cache: cache, NotLog: true})
+	if resp.CacheStatus != CacheHit || resp.Text != "/vary-1" {
+		t.Fatalf("匿名请求应命中匿名缓存: %s %s", resp.CacheStatus, resp.Text)
+	}
+}
+
+func TestHTTPCacheHitSkipsRateLimiter(t *testing.T) {
+	var hits int32
+	srv := newCacheServer(&hits)
+	defer srv.Close()
+	limiter := NewRateLimiter(Limit{Rate: 0.001})
+	limiter.FailFast = true
+	cache := NewHTTPCache(icache.NewFreeCache(1024 * 1024))
+	get := func() (*Response, error) {
+		return Get(srv.URL+"/max-age", &Opt{Cache: cache, RateLimiter: limiter, NotLog: true})
+	}
+
+	if _, err := get(); err != nil {
+		t.Fatalf("首次请求失败: %v", err)
+	}
+	// 令牌已用完，命中缓存时不应再经过限流
+	for i := 0; i < 3; i++ {
+		resp, err := get()
+		if err != nil || resp.CacheStatus != CacheHit {
+			t.Fatalf("缓存命中不应消耗限流令牌: %v %v", err, resp)
+		}
+	}
+	if _, err := Get(srv.URL+"/plain", &Opt{Cache: cache, RateLimiter: limiter, NotLog: true}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("未命中缓存的请求应被限流: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package ihttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Client 会话客户端，保存基础地址与默认配置，多个请求共享Cookie、代理、重试等设置
+//
+// 使用示例:
+//
+//	cli := ihttp.NewClient("https://api.example.com")
+//	cli.Headers["Authorization"] = "Bearer xxx"
+//	resp, err := cli.Get("/users", &ihttp.Opt{RespOut: &users})
+type Client struct {
+	BaseURL string
+
+	Headers   map[string]string  // 默认请求头，Opt.Headers中同名项优先
+	UserAgent string             // 默认User-Agent，Headers中已设置时不生效
+	Cookies   *map[string]string // 简单Cookie，Opt未设置时使用
+	Jar       *CookieJar         // Cookie容器，Opt未设置时使用
+
+	TimeOut   int          // 默认超时秒数，Opt未设置时使用
+	Retry     *RetryPolicy // 默认重试策略
+	Proxy     string       // 默认代理
+	ProxyPool *ProxyPool   // 默认代理池
+
+	RateLimiter *RateLimiter // 会话限流器，Opt未设置时使用
+	Impersonate *Profile     // 浏览器指纹伪装配置，Opt未设置时使用
+	Protocol    Protocol     // 强制使用的HTTP协议，Opt未设置时使用
+	HAR         *HAR         // HAR录制或回放，Opt未设置时使用
+	Cache       *HTTPCache   // 响应缓存，Opt未设置时使用
+	Signer      Signer       // 请求签名器，Opt未设置时使用
+	Metrics     *Metrics     // 指标收集器，Opt未设置时使用
+	Tracer      Tracer       // 链路追踪钩子，Opt未设置时使用
+	Redactor    *Redactor    // 日志脱敏规则，Opt未设置时使用
+
+	NoRedirect   bool // 不跟随重定向
+	MaxRedirects int  // 最多跟随的重定向次数，Opt未设置时使用
+
+	HttpCLi     *http.Client
+	Middlewares []Middleware // 会话中间件，位于Opt中间件外层
+	NotLog      bool
+
+	mu sync.RWMutex
+}
+
+// NewClient 创建会话客户端，默认启用Cookie容器
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		Headers: map[string]string{},
+		Jar:     NewCookieJar(),
+	}
+}
+
+// SetHeader 并发安全地设置默认请求头
+func (c *Client) SetHeader(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Headers == nil {
+		c.Headers = map[string]string{}
+	}
+	c.Headers[key] = value
+}
+
+// DelHeader 并发安全地删除默认请求头
+func (c *Client) DelHeader(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.Headers, key)
+}
+
+// ResolveURL 将相对路径拼接到BaseURL，绝对地址原样返回
+func (c *Client) ResolveURL(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, "ws://") || strings.HasPrefix(path, "wss://") {
+		return path
+	}
+	if c.BaseURL == "" {
+		return path
+	}
+	if path == "" {
+		return c.BaseURL
+	}
+	if strings.HasPrefix(path, "?") {
+		return c.BaseURL + path
+	}
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// prepare 合并会话默认配置，返回新的Opt，不修改调用方传入的Opt
+func (c *Client) prepare(opt *Opt) *Opt {
+	o := Opt{}
+	if opt != nil {
+		o = *opt
+	}
+	o.URL = c.ResolveURL(o.URL)
+
+	c.mu.RLock()
+	headers := make(map[string]string, len(c.Headers)+len(o.Headers)+1)
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+	c.mu.RUnlock()
+	if c.UserAgent != "" && !hasHeader(headers, "User-Agent") && !hasHeader(o.Headers, "User-Agent") {
+		headers["User-Agent"] = c.UserAgent
+	}
+	for k, v := range o.Headers {
+		// 忽略大小写覆盖同名默认请求头
+		for dk := range headers {
+			if strings.EqualFold(dk, k) {
+				delete(headers, dk)
+			}
+		}
+		headers[k] = v
+	}
+	o.Headers = headers
+
+	if o.Cookies == nil {
+		o.Cookies = c.Cookies
+	}
+	if o.Jar == nil {
+		o.Jar = c.Jar
+	}
+	if o.TimeOut == 0 {
+		o.TimeOut = c.TimeOut
+	}
+	if o.Retry == nil {
+		o.Retry = c.Retry
+	}
+	if o.Proxy == "" && o.ProxyPool == nil {
+		o.Proxy = c.Proxy
+		o.ProxyPool = c.ProxyPool
+	}
+	if o.HttpCLi == nil {
+		o.HttpCLi = c.HttpCLi
+	}
+	if o.RateLimiter == nil {
+		o.RateLimiter = c.RateLimiter
+	}
+	if o.Impersonate == nil {
+		o.Impersonate = c.Impersonate
+	}
+	if o.Protocol == ProtocolAuto && o.Impersonate == nil {
+		o.Protocol = c.Protocol
+	}
+	if o.HAR == nil {
+		o.HAR = c.HAR
+	}
+	if o.Cache == nil {
+		o.Cache = c.Cache
+	}
+	if o.Signer == nil {
+		o.Signer = c.Signer
+	}
+	if o.Metrics == nil {
+		o.Metrics = c.Metrics
+	}
+	if o.Tracer == nil {
+		o.Tracer = c.Tracer
+	}
+	if o.Redactor == nil {
+		o.Redactor = c.Redactor
+	}
+	if o.MaxRedirects <= 0 {
+		o.MaxRedirects = c.MaxRedirects
+	}
+	o.NoRedirect = o.NoRedirect || c.NoRedirect
+	if len(c.Middlewares) > 0 {
+		o.Middlewares = append(append([]Middleware{}, c.Middlewares...), o.Middlewares...)
+	}
+	o.NotLog = o.NotLog || c.NotLog
+	return &o
+}
+
+// Do 使用会话配置执行请求，Opt.URL可为相对路径
+func (c *Client) Do(opt *Opt) (*Response, error) {
+	return c.DoContext(context.Background(), opt)
+}
+
+// DoContext 带ctx使用会话配置执行请求
+func (c *Client) DoContext(ctx context.Context, opt *Opt) (*Response, error) {
+	return DoContext(ctx, c.prepare(opt))
+}
+
+func (c *Client) doMethod(ctx context.Context, method, path string, opt *Opt) (*Response, error) {
+	o := c.prepare(opt)
+	o.URL = c.ResolveURL(path)
+	o.Method = method
+	return DoContext(ctx, o)
+}
+
+// Get 执行GET请求
+func (c *Client) Get(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "GET", path, opt)
+}
+
+// Post 执行POST请求
+func (c *Client) Post(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "POST", path, opt)
+}
+
+// Put 执行PUT请求
+func (c *Client) Put(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "PUT", path, opt)
+}
+
+// Patch 执行PATCH请求
+func (c *Client) Patch(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "PATCH", path, opt)
+}
+
+// Delete 执行DELETE请求
+func (c *Client) Delete(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "DELETE", path, opt)
+}
+
+// Head 执行HEAD请求
+func (c *Client) Head(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "HEAD", path, opt)
+}
+
+// Options 执行OPTIONS请求
+func (c *Client) Options(path string, opt *Opt) (*Response, error) {
+	return c.doMethod(context.Background(), "OPTIONS", path, opt)
+}
+
+// GetContext 执行带ctx的GET请求
+func (c *Client) GetContext(ctx context.Context, path string, opt *Opt) (*Response, error) {
+	return c.doMethod(ctx, "GET", path, opt)
+}
+
+// PostContext 执行带ctx的POST请求
+func (c *Client) PostContext(ctx context.Context, path string, opt *Opt) (*Response, error) {
+	return c.doMethod(ctx, "POST", path, opt)
+}
+
+// PutContext 执行带ctx的PUT请求
+func (c *Client) PutContext(ctx context.Context, path string, opt *Opt) (*Response, error) {
+	return c.doMethod(ctx, "PUT", path, opt)
+}
+
+// PatchContext 执行带ctx的PATCH请求
+func (c *Client) PatchContext(ctx context.Context, path string, opt *Opt) (*Response, error) {
+	return c.doMethod(ctx, "PATCH", path, opt)
+}
+
+// DeleteContext 执行带ctx的DELETE请求
+func (c *Client) DeleteContext(ctx context.Context, path string, opt *Opt) (*Response, error) {
+	return c.doMethod(ctx, "DELETE", path, opt)
+}
+
+// Download 使用会话配置下载文件
+func (c *Client) Download(path, filePath string, opt *Opt) (*Response, error) {
+	o := c.prepare(opt)
+	return Download(c.ResolveURL(path), filePath, o)
+}
+
+// hasHeader 忽略大小写检查请求头是否存在
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
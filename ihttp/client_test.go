@@ -0,0 +1,89 @@
+package ihttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientResolveURL(t *testing.T) {
+	c := &Client{BaseURL: "https://api.example.com/v1/"}
+	cases := map[string]string{
+		"/users":             "https://api.example.com/v1/users",
+		"users":              "https://api.example.com/v1/users",
+		"?a=1":               "https://api.example.com/v1/?a=1",
+		"":                   "https://api.example.com/v1/",
+		"http://other.com/x": "http://other.com/x",
+	}
+	for path, want := range cases {
+		if got := c.ResolveURL(path); got != want {
+			t.Errorf("ResolveURL(%q) = %q, 期望 %q", path, got, want)
+		}
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/"})
+		case "/me":
+			c, _ := r.Cookie("sid")
+			if c == nil || c.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(r.Method + "|" + r.Header.Get("User-Agent") + "|" + r.Header.Get("X-App") + "|" + r.Header.Get("X-Extra")))
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewClient(srv.URL)
+	cli.UserAgent = "gokit-test"
+	cli.NotLog = true
+	cli.SetHeader("X-App", "default")
+
+	if _, err := cli.Post("/login", nil); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	opt := &Opt{Headers: map[string]string{"x-app": "override", "X-Extra": "1"}}
+	resp, err := cli.Get("me", opt)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.Text != "GET|gokit-test|override|1" {
+		t.Fatalf("会话配置未生效: %s", resp.Text)
+	}
+	if opt.URL != "" || opt.Method != "" || len(opt.Headers) != 2 {
+		t.Fatalf("调用方Opt被修改: %+v", opt)
+	}
+	if cli.Headers["X-App"] != "default" {
+		t.Fatalf("会话默认请求头被修改: %v", cli.Headers)
+	}
+}
+
+func TestClientMiddlewareOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				req.Raw.Header.Add("X-Trace", name)
+				return next(req)
+			}
+		}
+	}
+
+	cli := &Client{BaseURL: srv.URL, NotLog: true, Middlewares: []Middleware{tag("client")}}
+	resp, err := cli.Get("/", &Opt{Middlewares: []Middleware{tag("opt")}})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.Text != "client" {
+		t.Fatalf("会话中间件应位于外层: %s", resp.Text)
+	}
+}
@@ -0,0 +1,130 @@
+package ihttp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding 支持自动解压的编码，可用于设置 Accept-Encoding 请求头
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// decodeBody 按Content-Encoding返回解压后的响应体，并移除已失效的编码与长度响应头
+// 解压器在首次读取时才创建，空响应体(HEAD、204等)不会报错
+func decodeBody(resp *http.Response) io.ReadCloser {
+	var encodings []string
+	for _, v := range resp.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			enc = strings.ToLower(strings.TrimSpace(enc))
+			if enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return resp.Body
+	}
+	for _, enc := range encodings {
+		switch enc {
+		case "gzip", "x-gzip", "deflate", "br", "zstd":
+		default:
+			// 存在不支持的编码时保持原样
+			return resp.Body
+		}
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return &decodeReader{body: resp.Body, encodings: encodings}
+}
+
+// decodeReader 延迟创建解压器，多重编码按逆序解码
+type decodeReader struct {
+	body      io.ReadCloser
+	encodings []string
+
+	r       io.Reader
+	closers []io.Closer
+	err     error
+}
+
+func (d *decodeReader) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.err = d.init()
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+func (d *decodeReader) init() error {
+	br := bufio.NewReader(d.body)
+	if _, err := br.Peek(1); err == io.EOF {
+		d.r = br
+		return nil
+	}
+
+	var r io.Reader = br
+	for i := len(d.encodings) - 1; i >= 0; i-- {
+		enc := d.encodings[i]
+		switch enc {
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return fmt.Errorf("解压响应失败(%s): %w", enc, err)
+			}
+			d.closers = append(d.closers, zr)
+			r = zr
+		case "deflate":
+			// 规范要求zlib封装，部分服务端直接返回原始deflate数据
+			buffered := bufio.NewReader(r)
+			if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+				zr, err := zlib.NewReader(buffered)
+				if err != nil {
+					return fmt.Errorf("解压响应失败(%s): %w", enc, err)
+				}
+				d.closers = append(d.closers, zr)
+				r = zr
+			} else {
+				fr := flate.NewReader(buffered)
+				d.closers = append(d.closers, fr)
+				r = fr
+			}
+		case "br":
+			r = brotli.NewReader(r)
+		case "zstd":
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return fmt.Errorf("解压响应失败(%s): %w", enc, err)
+			}
+			rc := zr.IOReadCloser()
+			d.closers = append(d.closers, rc)
+			r = rc
+		}
+	}
+	d.r = r
+	return nil
+}
+
+func (d *decodeReader) Close() error {
+	for _, c := range d.closers {
+		c.Close()
+	}
+	return d.body.Close()
+}
+
+// isZlibHeader 判断是否为zlib头(CMF/FLG)
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
@@ -0,0 +1,483 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar 按RFC 6265实现的Cookie容器
+// 保存完整的Cookie属性，按域名/路径匹配，自动清理过期Cookie，支持JSON持久化
+type CookieJar struct {
+	mu      sync.Mutex
+	entries map[string]map[string]*jarEntry // 域名 -> (name;path) -> Cookie
+	seq     uint64                          // 创建顺序，用于同路径长度排序
+}
+
+// jarEntry Cookie存储条目
+type jarEntry struct {
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	Domain     string    `json:"domain"`
+	Path       string    `json:"path"`
+	Expires    time.Time `json:"expires,omitempty"`
+	Persistent bool      `json:"persistent"`
+	HostOnly   bool      `json:"hostOnly"`
+	Secure     bool      `json:"secure"`
+	HttpOnly   bool      `json:"httpOnly"`
+	SameSite   string    `json:"sameSite,omitempty"`
+	Creation   time.Time `json:"creation"`
+	LastAccess time.Time `json:"lastAccess"`
+
+	seq uint64
+}
+
+// NewCookieJar 创建空的Cookie容器
+func NewCookieJar() *CookieJar {
+	return &CookieJar{entries: map[string]map[string]*jarEntry{}}
+}
+
+// LoadCookieJar 从JSON文件加载Cookie容器，文件不存在时返回空容器
+func LoadCookieJar(path string) (*CookieJar, error) {
+	jar := NewCookieJar()
+	if err := jar.Load(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return jar, nil
+}
+
+// SetCookies 实现 http.CookieJar，保存响应中的Cookie
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entries == nil {
+		j.entries = map[string]map[string]*jarEntry{}
+	}
+
+	now := time.Now()
+	for _, c := range cookies {
+		e, remove, ok := j.newEntry(c, u, host, now)
+		if !ok {
+			continue
+		}
+		id := e.id()
+		submap := j.entries[e.Domain]
+		if remove {
+			if submap != nil {
+				delete(submap, id)
+				if len(submap) == 0 {
+					delete(j.entries, e.Domain)
+				}
+			}
+			continue
+		}
+		if submap == nil {
+			submap = map[string]*jarEntry{}
+			j.entries[e.Domain] = submap
+		}
+		// 同名同路径Cookie保留原创建时间
+		if old, ok := submap[id]; ok {
+			e.Creation = old.Creation
+			e.seq = old.seq
+		} else {
+			j.seq++
+			e.seq = j.seq
+		}
+		submap[id] = e
+	}
+}
+
+// Cookies 实现 http.CookieJar，返回应发送到u的Cookie
+// 按路径长度降序、创建时间升序排列
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if u == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return nil
+	}
+	https := u.Scheme == "https"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	selected := []*jarEntry{}
+	for domain, submap := range j.entries {
+		if !domainMatch(host, domain) {
+			continue
+		}
+		for id, e := range submap {
+			if e.expired(now) {
+				delete(submap, id)
+				continue
+			}
+			if e.HostOnly && host != e.Domain {
+				continue
+			}
+			if e.Secure && !https {
+				continue
+			}
+			if !pathMatch(path, e.Path) {
+				continue
+			}
+			e.LastAccess = now
+			selected = append(selected, e)
+		}
+		if len(submap) == 0 {
+			delete(j.entries, domain)
+		}
+	}
+
+	sort.Slice(selected, func(a, b int) bool {
+		if len(selected[a].Path) != len(selected[b].Path) {
+			return len(selected[a].Path) > len(selected[b].Path)
+		}
+		return selected[a].seq < selected[b].seq
+	})
+
+	res := make([]*http.Cookie, 0, len(selected))
+	for _, e := range selected {
+		res = append(res, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+	return res
+}
+
+// All 返回容器中全部未过期的Cookie，包含完整属性
+func (j *CookieJar) All() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.removeExpiredLocked(time.Now())
+	entries := j.sortedLocked()
+	res := make([]*http.Cookie, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.cookie())
+	}
+	return res
+}
+
+// Len 返回未过期Cookie数量
+func (j *CookieJar) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.removeExpiredLocked(time.Now())
+	n := 0
+	for _, submap := range j.entries {
+		n += len(submap)
+	}
+	return n
+}
+
+// Clear 清空全部Cookie
+func (j *CookieJar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = map[string]map[string]*jarEntry{}
+}
+
+// RemoveExpired 清理过期Cookie
+func (j *CookieJar) RemoveExpired() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.removeExpiredLocked(time.Now())
+}
+
+// MarshalJSON 序列化全部未过期Cookie
+// 会话Cookie同样保存，以便长时间运行的会话在重启后恢复
+func (j *CookieJar) MarshalJSON() ([]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.removeExpiredLocked(time.Now())
+	return json.Marshal(j.sortedLocked())
+}
+
+// UnmarshalJSON 反序列化Cookie并合并到容器中
+func (j *CookieJar) UnmarshalJSON(data []byte) error {
+	var list []*jarEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entries == nil {
+		j.entries = map[string]map[string]*jarEntry{}
+	}
+	now := time.Now()
+	for _, e := range list {
+		if e == nil || e.Name == "" || e.Domain == "" || e.expired(now) {
+			continue
+		}
+		if e.Path == "" {
+			e.Path = "/"
+		}
+		j.seq++
+		e.seq = j.seq
+		submap := j.entries[e.Domain]
+		if submap == nil {
+			submap = map[string]*jarEntry{}
+			j.entries[e.Domain] = submap
+		}
+		submap[e.id()] = e
+	}
+	return nil
+}
+
+// Save 将Cookie保存到JSON文件
+func (j *CookieJar) Save(path string) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cookie序列化失败: %v", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建目录失败: %v", err)
+		}
+	}
+	// 先写临时文件再重命名，避免写入中断损坏原文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入Cookie文件失败: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load 从JSON文件加载Cookie并合并到容器中
+func (j *CookieJar) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return fmt.Errorf("Cookie文件解析失败: %v", err)
+	}
+	return nil
+}
+
+// newEntry 根据Set-Cookie构建存储条目
+// remove为true表示该Cookie用于删除已有条目
+func (j *CookieJar) newEntry(c *http.Cookie, u *url.URL, host string,
+	now time.Time) (e *jarEntry, remove bool, ok bool) {
+	if c == nil || c.Name == "" {
+		return nil, false, false
+	}
+
+	e = &jarEntry{
+		Name:       c.Name,
+		Value:      c.Value,
+		Secure:     c.Secure,
+		HttpOnly:   c.HttpOnly,
+		SameSite:   sameSiteString(c.SameSite),
+		Creation:   now,
+		LastAccess: now,
+	}
+
+	// 路径
+	if c.Path == "" || c.Path[0] != '/' {
+		e.Path = defaultPath(u.Path)
+	} else {
+		e.Path = c.Path
+	}
+
+	// 域名
+	domain, hostOnly, err := cookieDomain(host, c.Domain)
+	if err != nil {
+		return nil, false, false
+	}
+	e.Domain, e.HostOnly = domain, hostOnly
+
+	// Secure Cookie只能由https设置
+	if e.Secure && u.Scheme != "https" {
+		return nil, false, false
+	}
+
+	// 过期时间，Max-Age优先于Expires
+	if c.MaxAge < 0 {
+		return e, true, true
+	} else if c.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		e.Persistent = true
+	} else if !c.Expires.IsZero() {
+		if !c.Expires.After(now) {
+			return e, true, true
+		}
+		e.Expires = c.Expires
+		e.Persistent = true
+	}
+	return e, false, true
+}
+
+func (j *CookieJar) removeExpiredLocked(now time.Time) {
+	for domain, submap := range j.entries {
+		for id, e := range submap {
+			if e.expired(now) {
+				delete(submap, id)
+			}
+		}
+		if len(submap) == 0 {
+			delete(j.entries, domain)
+		}
+	}
+}
+
+func (j *CookieJar) sortedLocked() []*jarEntry {
+	list := []*jarEntry{}
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].seq < list[b].seq })
+	return list
+}
+
+func (e *jarEntry) id() string {
+	return e.Name + ";" + e.Path
+}
+
+func (e *jarEntry) expired(now time.Time) bool {
+	return e.Persistent && !e.Expires.After(now)
+}
+
+func (e *jarEntry) cookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+	}
+	if !e.HostOnly {
+		c.Domain = e.Domain
+	}
+	if e.Persistent {
+		c.Expires = e.Expires
+	}
+	switch strings.ToLower(e.SameSite) {
+	case "lax":
+		c.SameSite = http.SameSiteLaxMode
+	case "strict":
+		c.SameSite = http.SameSiteStrictMode
+	case "none":
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
+
+func sameSiteString(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+// canonicalHost 去掉端口并转为小写
+func canonicalHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	host = strings.Trim(host, "[]")
+	if host == "" {
+		return "", errors.New("空主机名")
+	}
+	return host, nil
+}
+
+// cookieDomain 按RFC 6265 5.3计算Cookie所属域名
+func cookieDomain(host, domain string) (string, bool, error) {
+	if domain == "" {
+		return host, true, nil
+	}
+
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" {
+		return host, true, nil
+	}
+
+	// IP地址只允许精确匹配
+	if net.ParseIP(host) != nil {
+		if domain == host {
+			return host, true, nil
+		}
+		return "", false, errors.New("IP主机不允许设置Domain")
+	}
+
+	// 不允许为公共后缀设置Cookie
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		if host == domain {
+			return host, true, nil
+		}
+		return "", false, errors.New("不允许为公共后缀设置Cookie")
+	}
+
+	if !domainMatch(host, domain) {
+		return "", false, errors.New("Domain与请求主机不匹配")
+	}
+	return domain, false, nil
+}
+
+// domainMatch 按RFC 6265 5.1.3判断域名匹配
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// pathMatch 按RFC 6265 5.1.4判断路径匹配
+func pathMatch(reqPath, cookiePath string) bool {
+	if reqPath == cookiePath {
+		return true
+	}
+	if strings.HasPrefix(reqPath, cookiePath) {
+		return strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/'
+	}
+	return false
+}
+
+// defaultPath 按RFC 6265 5.1.4计算默认路径
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
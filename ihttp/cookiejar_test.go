@@ -0,0 +1,141 @@
+package ihttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustURL(s string) *url.URL {
+	u, _ := url.Parse(s)
+	return u
+}
+
+func cookieNames(cookies []*http.Cookie) []string {
+	res := []string{}
+	for _, c := range cookies {
+		res = append(res, c.Name)
+	}
+	return res
+}
+
+func TestCookieJarDomainAndPath(t *testing.T) {
+	jar := NewCookieJar()
+	jar.SetCookies(mustURL("https://www.example.com/account/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "path", Value: "3", Path: "/account"},
+		{Name: "secure", Value: "4", Secure: true},
+		{Name: "suffix", Value: "5", Domain: "com"},
+		{Name: "other", Value: "6", Domain: "other.com"},
+	})
+
+	cases := map[string][]string{
+		"https://www.example.com/account/x": {"host", "path", "secure", "domain"},
+		"http://www.example.com/account/x":  {"host", "path", "domain"},
+		"https://api.example.com/":          {"domain"},
+		"https://www.example.com/":          {"domain"},
+		"https://other.com/":                {},
+		"https://example.org/":              {},
+	}
+	for u, want := range cases {
+		got := cookieNames(jar.Cookies(mustURL(u)))
+		if len(got) != len(want) {
+			t.Fatalf("%s 期望%v, 实际%v", u, want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s 期望%v, 实际%v", u, want, got)
+			}
+		}
+	}
+}
+
+func TestCookieJarExpiry(t *testing.T) {
+	jar := NewCookieJar()
+	u := mustURL("http://example.com/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "short", Value: "1", MaxAge: 1},
+		{Name: "past", Value: "2", Expires: time.Now().Add(-time.Hour)},
+		{Name: "keep", Value: "3"},
+	})
+	if got := cookieNames(jar.Cookies(u)); len(got) != 2 {
+		t.Fatalf("过期Cookie不应保存: %v", got)
+	}
+
+	// Max-Age<0 删除已有Cookie
+	jar.SetCookies(u, []*http.Cookie{{Name: "keep", MaxAge: -1}})
+	time.Sleep(1100 * time.Millisecond)
+	if jar.Len() != 0 {
+		t.Fatalf("Cookie应全部失效: %v", cookieNames(jar.All()))
+	}
+}
+
+func TestCookieJarSaveLoad(t *testing.T) {
+	jar := NewCookieJar()
+	u := mustURL("https://example.com/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "abc", HttpOnly: true, Secure: true},
+		{Name: "pref", Value: "x", Domain: "example.com", Path: "/", MaxAge: 3600, SameSite: http.SameSiteLaxMode},
+	})
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := jar.Save(path); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	loaded, err := LoadCookieJar(path)
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if loaded.Len() != 2 {
+		t.Fatalf("加载后数量错误: %d", loaded.Len())
+	}
+	for _, c := range loaded.All() {
+		if c.Name == "sid" && (!c.HttpOnly || !c.Secure || c.Path != "/a" || c.Domain != "") {
+			t.Fatalf("属性丢失: %+v", c)
+		}
+		if c.Name == "pref" && (c.Domain != "example.com" || c.Expires.IsZero() || c.SameSite != http.SameSiteLaxMode) {
+			t.Fatalf("属性丢失: %+v", c)
+		}
+	}
+	if got := cookieNames(loaded.Cookies(mustURL("https://sub.example.com/"))); len(got) != 1 || got[0] != "pref" {
+		t.Fatalf("加载后匹配错误: %v", got)
+	}
+}
+
+func TestDoWithJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "t1", Path: "/"})
+			return
+		}
+		c, err := r.Cookie("token")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(c.Value))
+	}))
+	defer srv.Close()
+
+	jar := NewCookieJar()
+	resp, err := Get(srv.URL+"/login", &Opt{Jar: jar, NotLog: true})
+	if err != nil || len(resp.CookieList) != 1 {
+		t.Fatalf("登录失败: %v", err)
+	}
+	resp, err = Get(srv.URL+"/me", &Opt{Jar: jar, NotLog: true})
+	if err != nil || resp.Text != "t1" {
+		t.Fatalf("Cookie未携带: %v %s", err, resp.Text)
+	}
+}
+
+func TestParseCookieStringAttributes(t *testing.T) {
+	c := ParseCookieString("sid=1; Domain=.example.com; Path=/app; Secure; HttpOnly; Max-Age=60")
+	if c == nil || strings.TrimPrefix(c.Domain, ".") != "example.com" || c.Path != "/app" || !c.Secure || !c.HttpOnly || c.MaxAge != 60 {
+		t.Fatalf("属性解析错误: %+v", c)
+	}
+}
@@ -0,0 +1,614 @@
+package ihttp
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// curlIgnored 不影响请求内容的curl参数，解析时忽略
+var curlIgnored = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-L": true, "--location": true, "-f": true, "--fail": true,
+	"-g": true, "--globoff": true, "--http2": true,
+	"--tr-encoding": true, "-#": true, "--progress-bar": true, "-N": true, "--no-buffer": true,
+}
+
+// curlIgnoredValue 带参数值但不影响请求内容的curl参数
+var curlIgnoredValue = map[string]bool{
+	"-o": true, "--output": true, "-w": true, "--write-out": true, "--connect-timeout": true,
+	"--retry": true, "-c": true, "--cookie-jar": true, "--resolve": true, "--cacert": true,
+}
+
+// ParseCurl 解析浏览器开发者工具复制的cURL(bash)命令为请求配置
+// 支持 -X -H -d --data-raw --data-binary --data-urlencode --json -F -b -u -x -A -e -G -I -k -m --compressed --max-redirs
+// --http1.1 --http2-prior-knowledge --http3
+// JSON请求体解析为Json，可无损解析的表单解析为Data，其余按原样保存到Body
+func ParseCurl(cmd string) (*Opt, error) {
+	args, err := splitShell(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || !(args[0] == "curl" || strings.HasSuffix(args[0], "/curl") || args[0] == "curl.exe") {
+		return nil, errors.New("不是curl命令")
+	}
+
+	opt := &Opt{Headers: map[string]string{}}
+	var (
+		data      []string
+		hasData   bool
+		jsonBody  bool
+		getData   bool
+		head      bool
+		cookies   = map[string]string{}
+		formData  = map[string]any{}
+		formFiles = map[string]File{}
+	)
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if opt.URL != "" {
+				return nil, fmt.Errorf("curl命令包含多个URL: %s", arg)
+			}
+			opt.URL = arg
+			continue
+		}
+
+		name, value, hasValue := arg, "", false
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			// 短参数可合并，如 -sSL、-XPOST
+			if flagTakesValue(arg[:2]) {
+				name, value, hasValue = arg[:2], arg[2:], true
+			} else {
+				for _, c := range arg[1:] {
+					if f := "-" + string(c); f == "-k" {
+						opt.HttpCLi = insecureClient()
+					} else if f == "-I" {
+						head = true
+					} else if f == "-G" {
+						getData = true
+					} else if !curlIgnored[f] {
+						return nil, fmt.Errorf("不支持的curl参数: %s", f)
+					}
+				}
+				continue
+			}
+		}
+		if flagTakesValue(name) && !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("curl参数缺少值: %s", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--url":
+			opt.URL = value
+		case "-X", "--request":
+			opt.Method = strings.ToUpper(value)
+		case "-H", "--header":
+			k, v, ok := strings.Cut(value, ":")
+			if !ok {
+				// "Name;" 表示空值请求头
+				if k, ok = strings.CutSuffix(value, ";"); !ok {
+					return nil, fmt.Errorf("请求头格式错误: %s", value)
+				}
+			}
+			k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+			if strings.EqualFold(k, "Cookie") {
+				parseCookieHeader(v, cookies)
+				continue
+			}
+			opt.Headers[k] = v
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw":
+			if (name != "--data-raw") && strings.HasPrefix(value, "@") {
+				content, err := os.ReadFile(value[1:])
+				if err != nil {
+					return nil, fmt.Errorf("读取请求体文件失败: %v", err)
+				}
+				value = string(content)
+				if name != "--data-binary" {
+					value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+				}
+			}
+			data, hasData = append(data, value), true
+		case "--data-urlencode":
+			encoded, err := curlURLEncode(value)
+			if err != nil {
+				return nil, err
+			}
+			data, hasData = append(data, encoded), true
+		case "--json":
+			data, hasData, jsonBody = append(data, value), true, true
+		case "-F", "--form", "--form-string":
+			if err := parseCurlForm(value, name == "--form-string", formData, formFiles); err != nil {
+				return nil, err
+			}
+		case "-b", "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("不支持从文件读取Cookie: %s", value)
+			}
+			parseCookieHeader(value, cookies)
+		case "-u", "--user":
+			opt.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(value))
+		case "-x", "--proxy":
+			opt.Proxy = value
+		case "-A", "--user-agent":
+			opt.Headers["User-Agent"] = value
+		case "-e", "--referer":
+			opt.Headers["Referer"] = value
+		case "-m", "--max-time":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("超时时间格式错误: %s", value)
+			}
+			opt.TimeOut = int(math.Ceil(seconds))
+		case "--max-redirs":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("重定向次数格式错误: %s", value)
+			}
+			opt.MaxRedirects = n
+		case "--compressed":
+			if !hasHeader(opt.Headers, "Accept-Encoding") {
+				opt.Headers["Accept-Encoding"] = AcceptEncoding
+			}
+		case "-k", "--insecure":
+			opt.HttpCLi = insecureClient()
+		case "-I", "--head":
+			head = true
+		case "-G", "--get":
+			getData = true
+		case "--http1.1":
+			opt.Protocol = ProtocolHTTP1
+		case "--http2-prior-knowledge":
+			opt.Protocol = ProtocolH2C
+		case "--http3", "--http3-only":
+			opt.Protocol = ProtocolHTTP3
+		default:
+			if !curlIgnored[name] && !curlIgnoredValue[name] {
+				return nil, fmt.Errorf("不支持的curl参数: %s", name)
+			}
+		}
+	}
+
+	if opt.URL == "" {
+		return nil, errors.New("curl命令缺少URL")
+	}
+	if len(cookies) > 0 {
+		opt.Cookies = &cookies
+	}
+
+	body := strings.Join(data, "&")
+	switch {
+	case head:
+		opt.Method = "HEAD"
+	case getData && hasData:
+		// -G 将数据作为查询参数
+		sep := "?"
+		if strings.Contains(opt.URL, "?") {
+			sep = "&"
+		}
+		opt.URL += sep + body
+		hasData = false
+	case len(formFiles) > 0 || len(formData) > 0:
+		if len(formFiles) > 0 {
+			opt.Files = formFiles
+		}
+		if len(formData) > 0 {
+			opt.Data = formData
+		}
+		removeHeader(opt.Headers, "Content-Type") // multipart边界由ihttp生成
+	case hasData:
+		contentType := strings.ToLower(headerValue(opt.Headers, "Content-Type"))
+		if jsonBody {
+			if contentType == "" {
+				opt.Headers["Content-Type"] = "application/json"
+			}
+			if !hasHeader(opt.Headers, "Accept") {
+				opt.Headers["Accept"] = "application/json"
+			}
+		}
+		switch {
+		case (jsonBody || strings.Contains(contentType, "json")) && json.Valid([]byte(body)):
+			opt.Json = json.RawMessage(body)
+		case contentType == "" || strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+			if form, ok := parseFormLossless(body); ok {
+				opt.Data = form
+				break
+			}
+			if contentType == "" {
+				opt.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+			}
+			opt.Body = []byte(body)
+		default:
+			opt.Body = []byte(body)
+		}
+	}
+
+	if opt.Method == "" {
+		opt.Method = "GET"
+		if hasData || opt.Files != nil || opt.Data != nil {
+			opt.Method = "POST"
+		}
+	}
+	return opt, nil
+}
+
+// flagTakesValue 判断curl参数是否需要参数值
+func flagTakesValue(name string) bool {
+	switch name {
+	case "-X", "--request", "-H", "--header", "-d", "--data", "--data-ascii", "--data-binary", "--data-raw",
+		"--data-urlencode", "--json", "-F", "--form", "--form-string", "-b", "--cookie", "-u", "--user",
+		"-x", "--proxy", "-A", "--user-agent", "-e", "--referer", "-m", "--max-time", "--max-redirs", "--url":
+		return true
+	}
+	return curlIgnoredValue[name]
+}
+
+// curlURLEncode 按 --data-urlencode 规则编码
+func curlURLEncode(value string) (string, error) {
+	name, content := "", value
+	if i := strings.IndexAny(value, "=@"); i >= 0 {
+		name, content = value[:i], value[i+1:]
+		if value[i] == '@' {
+			data, err := os.ReadFile(content)
+			if err != nil {
+				return "", fmt.Errorf("读取请求体文件失败: %v", err)
+			}
+			content = string(data)
+		}
+	}
+	encoded := url.QueryEscape(content)
+	if name == "" {
+		return encoded, nil
+	}
+	return name + "=" + encoded, nil
+}
+
+// parseCurlForm 解析 -F 参数，name=@path 为文件，name=<path 为从文件读取的字段值
+func parseCurlForm(value string, literal bool, data map[string]any, files map[string]File) error {
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("表单参数格式错误: %s", value)
+	}
+	if literal || !(strings.HasPrefix(content, "@") || strings.HasPrefix(content, "<")) {
+		data[name] = content
+		return nil
+	}
+
+	parts := strings.Split(content[1:], ";")
+	path := parts[0]
+	if content[0] == '<' {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取表单字段文件失败: %v", err)
+		}
+		data[name] = string(b)
+		return nil
+	}
+	file := File{Path: path}
+	for _, attr := range parts[1:] {
+		k, v, _ := strings.Cut(attr, "=")
+		switch strings.TrimSpace(k) {
+		case "type":
+			file.ContentType = v
+		case "filename":
+			file.FileName = strings.Trim(v, `"`)
+		}
+	}
+	files[name] = file
+	return nil
+}
+
+// parseFormLossless 解析表单请求体，存在重复字段或无法还原的内容时返回false
+func parseFormLossless(body string) (map[string]any, bool) {
+	if body == "" {
+		return nil, false
+	}
+	form := map[string]any{}
+	for _, pair := range strings.Split(body, "&") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, false
+		}
+		key, err1 := url.QueryUnescape(k)
+		value, err2 := url.QueryUnescape(v)
+		if err1 != nil || err2 != nil || !utf8.ValidString(value) {
+			return nil, false
+		}
+		if _, dup := form[key]; dup {
+			return nil, false
+		}
+		form[key] = value
+	}
+	return form, true
+}
+
+// parseCookieHeader 解析 "a=1; b=2" 格式的Cookie
+func parseCookieHeader(header string, cookies map[string]string) {
+	for _, part := range strings.Split(header, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && k != "" {
+			cookies[k] = v
+		}
+	}
+}
+
+// headerValue 忽略大小写获取请求头
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// removeHeader 忽略大小写删除请求头
+func removeHeader(headers map[string]string, name string) {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			delete(headers, k)
+		}
+	}
+}
+
+func insecureClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return &http.Client{Transport: t}
+}
+
+// ToCurl 生成等价的curl命令，用于复现请求；输出包含敏感信息，写入日志前可用 Redact 处理
+// 通过Reader上传的文件无法复现，以文件名代替
+func (o *Opt) ToCurl() string {
+	args := []string{"curl"}
+	method := strings.ToUpper(o.Method)
+	if method == "" {
+		method = "GET"
+	}
+	hasBody := o.Json != nil || len(o.Data) > 0 || len(o.Files) > 0 || o.Body != nil
+	switch {
+	case method == "HEAD":
+		args = append(args, "-I")
+	case method == "GET" && !hasBody, method == "POST" && hasBody:
+	default:
+		args = append(args, "-X", method)
+	}
+	args = append(args, shellQuote(o.URL))
+
+	headers := make([]string, 0, len(o.Headers))
+	for k, v := range o.Headers {
+		headers = append(headers, k+": "+v)
+	}
+	if o.Json != nil && !hasHeader(o.Headers, "Content-Type") {
+		headers = append(headers, "Content-Type: application/json")
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		args = append(args, "-H", shellQuote(h))
+	}
+
+	if o.Cookies != nil && len(*o.Cookies) > 0 {
+		pairs := make([]string, 0, len(*o.Cookies))
+		for k, v := range *o.Cookies {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		args = append(args, "-b", shellQuote(strings.Join(pairs, "; ")))
+	}
+
+	switch {
+	case len(o.Files) > 0:
+		for _, k := range sortedKeys(o.Data) {
+			args = append(args, "--form-string", shellQuote(fmt.Sprintf("%s=%v", k, o.Data[k])))
+		}
+		for _, k := range sortedKeys(o.Files) {
+			f := o.Files[k]
+			path := f.Path
+			if path == "" {
+				path = f.FileName
+			}
+			field := k + "=@" + path
+			if f.ContentType != "" {
+				field += ";type=" + f.ContentType
+			}
+			if f.FileName != "" && f.FileName != filepath.Base(path) {
+				field += ";filename=" + f.FileName
+			}
+			args = append(args, "-F", shellQuote(field))
+		}
+	case len(o.Data) > 0:
+		form := url.Values{}
+		for k, v := range o.Data {
+			form.Set(k, fmt.Sprintf("%v", v))
+		}
+		args = append(args, "--data-raw", shellQuote(form.Encode()))
+	case o.Json != nil:
+		data, err := json.Marshal(o.Json)
+		if err == nil {
+			args = append(args, "--data-raw", shellQuote(string(data)))
+		}
+	case o.Body != nil:
+		args = append(args, "--data-raw", shellQuote(string(o.Body)))
+	}
+
+	if o.Proxy != "" {
+		args = append(args, "-x", shellQuote(o.Proxy))
+	}
+	if o.TimeOut > 0 {
+		args = append(args, "-m", strconv.Itoa(o.TimeOut))
+	}
+	switch o.Protocol {
+	case ProtocolHTTP1:
+		args = append(args, "--http1.1")
+	case ProtocolHTTP2:
+		args = append(args, "--http2")
+	case ProtocolH2C:
+		args = append(args, "--http2-prior-knowledge")
+	case ProtocolHTTP3:
+		args = append(args, "--http3-only")
+	}
+	if !o.NoRedirect {
+		// ihttp默认跟随重定向
+		args = append(args, "-L")
+		if o.MaxRedirects > 0 {
+			args = append(args, "--max-redirs", strconv.Itoa(o.MaxRedirects))
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote 使用单引号转义shell参数
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:@%+,=", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitShell 按bash规则拆分命令行，支持单引号、双引号、$'...'与反斜杠续行
+func splitShell(cmd string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inToken bool
+	)
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inToken {
+				args = append(args, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		case c == '\\':
+			if i+1 < len(cmd) {
+				i++
+				if cmd[i] == '\n' || cmd[i] == '\r' {
+					// 续行
+					if cmd[i] == '\r' && i+1 < len(cmd) && cmd[i+1] == '\n' {
+						i++
+					}
+					continue
+				}
+				cur.WriteByte(cmd[i])
+				inToken = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("单引号未闭合")
+			}
+			cur.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+			inToken = true
+		case c == '$' && i+1 < len(cmd) && cmd[i+1] == '\'':
+			n, err := ansiCQuote(cmd[i+2:], &cur)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+			inToken = true
+		case c == '"':
+			j := i + 1
+			for ; j < len(cmd) && cmd[j] != '"'; j++ {
+				if cmd[j] == '\\' && j+1 < len(cmd) && strings.IndexByte("\"\\$`\n", cmd[j+1]) >= 0 {
+					j++
+					if cmd[j] == '\n' {
+						continue
+					}
+				}
+				cur.WriteByte(cmd[j])
+			}
+			if j >= len(cmd) {
+				return nil, errors.New("双引号未闭合")
+			}
+			i = j
+			inToken = true
+		default:
+			cur.WriteByte(c)
+			inToken = true
+		}
+	}
+	if inToken {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// ansiCQuote 解析 $'...' 中的内容写入out，返回消耗的字节数(含结尾单引号)
+func ansiCQuote(s string, out *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'a', 'b', 'e', 'E', 'f', 'v':
+			out.WriteByte(map[byte]byte{'a': 7, 'b': 8, 'e': 27, 'E': 27, 'f': 12, 'v': 11}[e])
+		case 'x', 'u', 'U':
+			max := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+			j := i + 1
+			for j < len(s) && j-i-1 < max && isHexDigit(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				out.WriteByte('\\')
+				out.WriteByte(e)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+			if e == 'x' {
+				out.WriteByte(byte(v))
+			} else {
+				out.WriteRune(rune(v))
+			}
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j-i < 3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i:j], 8, 8)
+			out.WriteByte(byte(v))
+			i = j - 1
+		default:
+			// \\ \' \" \? 及未知转义
+			out.WriteByte(e)
+		}
+	}
+	return 0, errors.New("$'引号未闭合")
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,155 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCurl(t *testing.T) {
+	// Chrome "Copy as cURL (bash)" 格式
+	cmd := `curl 'https://api.example.com/v1/items?page=1' \
+  -H 'accept: application/json' \
+  -H 'content-type: application/json' \
+  -H 'cookie: sid=abc; lang=zh-CN' \
+  -H $'x-note: it\'s ok' \
+  --data-raw '{"name":"tom","tags":["a","b"]}' \
+  --compressed`
+	opt, err := ParseCurl(cmd)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if opt.Method != "POST" || opt.URL != "https://api.example.com/v1/items?page=1" {
+		t.Fatalf("方法或URL错误: %s %s", opt.Method, opt.URL)
+	}
+	if opt.Headers["accept"] != "application/json" || opt.Headers["x-note"] != "it's ok" ||
+		opt.Headers["Accept-Encoding"] != AcceptEncoding || hasHeader(opt.Headers, "cookie") {
+		t.Fatalf("请求头错误: %v", opt.Headers)
+	}
+	if (*opt.Cookies)["sid"] != "abc" || (*opt.Cookies)["lang"] != "zh-CN" {
+		t.Fatalf("Cookie错误: %v", *opt.Cookies)
+	}
+	if raw, ok := opt.Json.(json.RawMessage); !ok || string(raw) != `{"name":"tom","tags":["a","b"]}` {
+		t.Fatalf("Json错误: %#v", opt.Json)
+	}
+
+	tests := []struct {
+		cmd   string
+		check func(o *Opt) bool
+	}{
+		{`curl -X put "https://x.com/a" -d "a=1" -d 'b=hello%20world'`, func(o *Opt) bool {
+			return o.Method == "PUT" && reflect.DeepEqual(o.Data, map[string]any{"a": "1", "b": "hello world"})
+		}},
+		{`curl https://x.com/a --data-urlencode 'q=a b&c' --data-urlencode '=x/y'`, func(o *Opt) bool {
+			return o.Method == "POST" && string(o.Body) == "q=a+b%26c&x%2Fy" &&
+				o.Headers["Content-Type"] == "application/x-www-form-urlencoded"
+		}},
+		{`curl -G https://x.com/s?a=1 -d q=go -sSL`, func(o *Opt) bool {
+			return o.Method == "GET" && o.URL == "https://x.com/s?a=1&q=go" && o.Body == nil && o.Data == nil
+		}},
+		{`curl -XDELETE -u user:pass -x socks5://127.0.0.1:1080 -A ua -e https://ref -m 2.5 --max-redirs 3 https://x.com`, func(o *Opt) bool {
+			return o.Method == "DELETE" && o.Headers["Authorization"] == "Basic dXNlcjpwYXNz" && o.Proxy == "socks5://127.0.0.1:1080" &&
+				o.Headers["User-Agent"] == "ua" && o.Headers["Referer"] == "https://ref" && o.TimeOut == 3 && o.MaxRedirects == 3
+		}},
+		{`curl -F 'file=@/tmp/a.png;type=image/png;filename=b.png' -F name=tom --form-string 'at=@me' https://x.com/up`, func(o *Opt) bool {
+			f := o.Files["file"]
+			return o.Method == "POST" && f.Path == "/tmp/a.png" && f.ContentType == "image/png" && f.FileName == "b.png" &&
+				o.Data["name"] == "tom" && o.Data["at"] == "@me"
+		}},
+		{`curl -H 'Content-Type: text/plain' -d 'hello' -I -k https://x.com`, func(o *Opt) bool {
+			return o.Method == "HEAD" && o.HttpCLi != nil
+		}},
+		{`curl -H 'Content-Type: text/plain' --data-binary $'line1\nline2' https://x.com`, func(o *Opt) bool {
+			return string(o.Body) == "line1\nline2" && o.Json == nil && o.Data == nil
+		}},
+		{`curl --http2-prior-knowledge http://localhost:8080 --http2`, func(o *Opt) bool {
+			return o.Protocol == ProtocolH2C && (&Opt{URL: o.URL, Protocol: ProtocolHTTP3, NoRedirect: true}).ToCurl() == "curl http://localhost:8080 --http3-only"
+		}},
+		{`curl --json '{"a":1}' https://x.com`, func(o *Opt) bool {
+			return o.Method == "POST" && o.Headers["Content-Type"] == "application/json" && o.Headers["Accept"] == "application/json"
+		}},
+	}
+	for _, tt := range tests {
+		o, err := ParseCurl(tt.cmd)
+		if err != nil || !tt.check(o) {
+			t.Fatalf("解析 %s 错误: %v %+v", tt.cmd, err, o)
+		}
+	}
+
+	for _, bad := range []string{`wget https://x.com`, `curl -H 'a: b`, `curl --unknown x https://x.com`, `curl -H x`, `curl -d a=1`} {
+		if _, err := ParseCurl(bad); err == nil {
+			t.Fatalf("应解析失败: %s", bad)
+		}
+	}
+}
+
+func TestCurlRoundTrip(t *testing.T) {
+	var gotBody, gotType, gotCookie string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotType, gotCookie = string(b), r.Header.Get("Content-Type"), r.Header.Get("Cookie")
+	}))
+	defer srv.Close()
+
+	cookies := map[string]string{"sid": "a'b"}
+	orig := &Opt{
+		Method:       "PATCH",
+		URL:          srv.URL + "/x?y=1",
+		Headers:      map[string]string{"X-Token": "t 1"},
+		Cookies:      &cookies,
+		Json:         map[string]any{"msg": "it's \"quoted\"\n"},
+		TimeOut:      5,
+		MaxRedirects: 2,
+	}
+	cmd := orig.ToCurl()
+	want := `curl -X PATCH '` + srv.URL + `/x?y=1' -H 'Content-Type: application/json' -H 'X-Token: t 1' -b 'sid=a'\''b' ` +
+		`--data-raw '{"msg":"it'\''s \"quoted\"\n"}' -m 5 -L --max-redirs 2`
+	if cmd != want {
+		t.Fatalf("ToCurl错误:\n%s\n%s", cmd, want)
+	}
+
+	parsed, err := ParseCurl(cmd)
+	if err != nil {
+		t.Fatalf("解析ToCurl输出失败: %v", err)
+	}
+	parsed.NotLog = true
+	if _, err := Do(parsed); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if gotBody != `{"msg":"it's \"quoted\"\n"}` || gotType != "application/json" || gotCookie != "sid=a'b" {
+		t.Fatalf("复现请求不一致: %s %s %s", gotBody, gotType, gotCookie)
+	}
+
+	// multipart
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("file content"), 0644)
+	upload := &Opt{Method: "POST", URL: srv.URL, NoRedirect: true,
+		Data: map[string]any{"name": "tom"}, Files: map[string]File{"f": {Path: path, ContentType: "text/plain"}}}
+	cmd = upload.ToCurl()
+	if !strings.Contains(cmd, "--form-string name=tom") || !strings.Contains(cmd, "-F 'f=@"+path+";type=text/plain'") ||
+		strings.Contains(cmd, "-L") {
+		t.Fatalf("multipart ToCurl错误: %s", cmd)
+	}
+	parsed, _ = ParseCurl(cmd)
+	parsed.NotLog = true
+	Do(parsed)
+	if !strings.HasPrefix(gotType, "multipart/form-data") || !strings.Contains(gotBody, "file content") || !strings.Contains(gotBody, "tom") {
+		t.Fatalf("multipart复现错误: %s %s", gotType, gotBody)
+	}
+
+	// 原始请求体
+	raw := &Opt{URL: srv.URL, Method: "POST", Body: []byte("a=1&a=2"), Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}}
+	parsed, _ = ParseCurl(raw.ToCurl())
+	parsed.NotLog = true
+	Do(parsed)
+	if gotBody != "a=1&a=2" || parsed.Data != nil {
+		t.Fatalf("重复字段应保留原始请求体: %s %v", gotBody, parsed.Data)
+	}
+}
@@ -0,0 +1,280 @@
+package ihttp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Covsj/gokit/icrypto/digest"
+)
+
+// ErrChecksumMismatch 下载文件校验失败
+var ErrChecksumMismatch = errors.New("文件校验失败")
+
+// Download 下载文件到path，支持断点续传、进度回调与校验
+// 下载过程中数据写入 path+".part"，完成并校验通过后重命名为path
+func Download(url, path string, opt *Opt) (*Response, error) {
+	return DownloadContext(context.Background(), url, path, opt)
+}
+
+// DownloadContext 带ctx的Download
+// opt.Retry 在下载层面生效，每次重试都会从已下载的位置续传
+// opt.TimeOut 为等待响应头及读取数据的空闲超时，不限制整个下载时长，总时长通过ctx控制
+func DownloadContext(ctx context.Context, url, path string, opt *Opt) (*Response, error) {
+	if path == "" {
+		return nil, errors.New("空文件路径")
+	}
+	if opt == nil {
+		opt = &Opt{}
+	}
+
+	var hasher hash.Hash
+	var expected string
+	if opt.Checksum != "" {
+		var err error
+		hasher, expected, err = parseChecksum(opt.Checksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("创建目录失败: %v", err)
+		}
+	}
+	partPath := path + ".part"
+
+	attempts := opt.Retry.attempts()
+	for attempt := 1; ; attempt++ {
+		resp, err := downloadOnce(ctx, url, partPath, opt)
+		if err == nil {
+			if hasher != nil {
+				if err = verifyFile(partPath, hasher, expected); err != nil {
+					// 校验失败说明已下载内容不可信，删除后重新下载
+					os.Remove(partPath)
+					return resp, err
+				}
+			}
+			if err = os.Rename(partPath, path); err != nil {
+				return resp, fmt.Errorf("重命名文件失败: %v", err)
+			}
+			return resp, nil
+		}
+
+		// 状态码错误按状态码判断是否重试
+		retryErr := err
+		if resp != nil && !resp.IsSuccess() {
+			retryErr = nil
+		}
+		if attempt >= attempts || !opt.Retry.shouldRetry(ctx, resp, retryErr) {
+			return resp, err
+		}
+		timer := time.NewTimer(opt.Retry.backoff(attempt, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// downloadOnce 执行一次下载，已有部分文件时使用Range续传
+func downloadOnce(ctx context.Context, url, partPath string, opt *Opt) (*Response, error) {
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	// 复制配置，避免修改调用方的Opt
+	o := *opt
+	o.URL = url
+	o.Method = "GET"
+	o.Stream = true
+	o.Retry = nil
+	o.RespOut = nil
+	o.Headers = make(map[string]string, len(opt.Headers)+2)
+	for k, v := range opt.Headers {
+		o.Headers[k] = v
+	}
+	// 禁止传输压缩，保证Range偏移与文件字节一致
+	o.Headers["Accept-Encoding"] = "identity"
+	if offset > 0 {
+		o.Headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	// TimeOut 不限制整个下载过程，只作为等待响应头与两次读取之间的空闲超时
+	o.TimeOut = 0
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var idle *idleTimer
+	if opt.TimeOut > 0 {
+		timeout := time.Duration(opt.TimeOut) * time.Second
+		idle = &idleTimer{timeout: timeout, timer: time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("下载超时: %v内未收到数据", timeout))
+		})}
+		defer idle.timer.Stop()
+	}
+
+	resp, err := DoContext(ctx, &o)
+	if err != nil {
+		return resp, downloadErr(ctx, err)
+	}
+	defer resp.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size := parseContentRange(http.Header(resp.Headers).Get("Content-Range"))
+		if start != offset {
+			// 服务端返回的区间与本地不一致，删除后从头下载
+			os.Remove(partPath)
+			return nil, fmt.Errorf("续传区间不匹配: 期望%d, 实际%d", offset, start)
+		}
+		flag |= os.O_APPEND
+		total = size
+	case http.StatusOK:
+		flag |= os.O_TRUNC
+		offset = 0
+		if n, err := strconv.ParseInt(http.Header(resp.Headers).Get("Content-Length"), 10, 64); err == nil {
+			total = n
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 本地部分文件已完整
+		_, size := parseContentRange(http.Header(resp.Headers).Get("Content-Range"))
+		if offset > 0 && size == offset {
+			if opt.DownloadProgress != nil {
+				opt.DownloadProgress(offset, size)
+			}
+			return resp, nil
+		}
+		os.Remove(partPath)
+		return resp, fmt.Errorf("下载失败, 状态码: %d", resp.StatusCode)
+	default:
+		return resp, fmt.Errorf("下载失败, 状态码: %d", resp.StatusCode)
+	}
+
+	file, err := os.OpenFile(partPath, flag, 0o644)
+	if err != nil {
+		return resp, fmt.Errorf("打开文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var dst io.Writer = file
+	if opt.DownloadProgress != nil {
+		dst = &progressWriter{w: file, current: offset, total: total, fn: opt.DownloadProgress}
+	}
+	var src io.Reader = resp.Reader
+	if idle != nil {
+		idle.timer.Reset(idle.timeout)
+		src = &idleReader{r: resp.Reader, idle: idle}
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return nil, fmt.Errorf("写入文件失败: %w", downloadErr(ctx, err))
+	}
+	return resp, nil
+}
+
+// idleTimer 空闲超时计时器，到期时取消下载
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// idleReader 每次读到数据时重置空闲计时
+type idleReader struct {
+	r    io.Reader
+	idle *idleTimer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.idle.timer.Reset(r.idle.timeout)
+	}
+	return n, err
+}
+
+// downloadErr 空闲超时导致的取消返回超时原因，便于重试与排查
+func downloadErr(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil && errors.Is(err, context.Canceled) && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return err
+}
+
+// parseContentRange 解析 "bytes start-end/size"，未知部分返回-1
+func parseContentRange(value string) (start, size int64) {
+	start, size = -1, -1
+	value = strings.TrimSpace(strings.TrimPrefix(value, "bytes"))
+	rangePart, sizePart, ok := strings.Cut(value, "/")
+	if !ok {
+		return
+	}
+	if n, err := strconv.ParseInt(strings.TrimSpace(sizePart), 10, 64); err == nil {
+		size = n
+	}
+	if s, _, ok := strings.Cut(strings.TrimSpace(rangePart), "-"); ok {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			start = n
+		}
+	}
+	return
+}
+
+// parseChecksum 解析 "算法:摘要" 格式的校验值
+func parseChecksum(checksum string) (hash.Hash, string, error) {
+	algo, sum, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("校验值格式错误: %s", checksum)
+	}
+	h, ok := digest.New(algo)
+	if !ok {
+		return nil, "", fmt.Errorf("不支持的校验算法: %s", algo)
+	}
+	return h, strings.ToLower(strings.TrimSpace(sum)), nil
+}
+
+// verifyFile 计算文件摘要并与期望值比较
+func verifyFile(path string, h hash.Hash, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %v", err)
+	}
+	defer file.Close()
+
+	h.Reset()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return fmt.Errorf("%w: 期望%s, 实际%s", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
+
+// progressWriter 写入时回调进度
+type progressWriter struct {
+	w       io.Writer
+	current int64
+	total   int64
+	fn      ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.current += int64(n)
+	p.fn(p.current, p.total)
+	return n, err
+}
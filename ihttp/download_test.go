@@ -0,0 +1,183 @@
+package ihttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Covsj/gokit/icrypto/digest"
+)
+
+func TestStreamEachLine(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "{\"n\":%d}\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := Get(srv.URL, &Opt{Stream: true, NotLog: true, TimeOut: 5})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.Reader == nil || resp.Body != nil {
+		t.Fatal("Stream模式应返回Reader且不读取Body")
+	}
+	lines := []string{}
+	if err := resp.EachLine(func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if len(lines) != 3 || lines[2] != `{"n":2}` {
+		t.Fatalf("行读取错误: %v", lines)
+	}
+}
+
+func TestStreamEachEvent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": comment\nevent: msg\nid: 1\ndata: hello\ndata: world\n\n")
+		fmt.Fprint(w, "data: second\nretry: 3000\n\n")
+		fmt.Fprint(w, "data: third\n")
+	}))
+	defer srv.Close()
+
+	resp, err := Get(srv.URL, &Opt{Stream: true, NotLog: true})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	events := []Event{}
+	resp.EachEvent(func(ev *Event) error {
+		events = append(events, *ev)
+		return nil
+	})
+	if len(events) != 3 {
+		t.Fatalf("事件数量错误: %+v", events)
+	}
+	if events[0].Event != "msg" || events[0].ID != "1" || events[0].Data != "hello\nworld" {
+		t.Fatalf("事件解析错误: %+v", events[0])
+	}
+	if events[1].Retry != 3000 || events[2].Data != "third" {
+		t.Fatalf("事件解析错误: %+v", events[1:])
+	}
+}
+
+func TestDownloadResumeAndChecksum(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(content)
+	modTime := time.Now()
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file.bin", modTime, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "file.bin")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	// 模拟上次中断留下的部分文件
+	os.WriteFile(path+".part", content[:4000], 0o644)
+
+	var last, total int64
+	_, err := Download(srv.URL, path, &Opt{
+		NotLog:   true,
+		Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+		DownloadProgress: func(current, t int64) {
+			last, total = current, t
+		},
+	})
+	if err != nil {
+		t.Fatalf("下载失败: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=4000-" {
+		t.Fatalf("未使用Range续传: %v", ranges)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, content) {
+		t.Fatal("文件内容不一致")
+	}
+	if last != int64(len(content)) || total != int64(len(content)) {
+		t.Fatalf("进度回调错误: %d/%d", last, total)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Fatal("临时文件未清理")
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "f")
+	_, err := Download(srv.URL, path, &Opt{NotLog: true, Checksum: "md5:" + strings.Repeat("0", 32)})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("期望校验失败: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("校验失败不应生成目标文件")
+	}
+
+	// 算法与 icrypto.HashGenerator 共用 digest 算法表
+	for _, algo := range []string{"md2", "SHAKE256-384"} {
+		h, _ := digest.New(algo)
+		h.Write([]byte("data"))
+		if _, err := Download(srv.URL, path, &Opt{NotLog: true, Checksum: algo + ":" + hex.EncodeToString(h.Sum(nil))}); err != nil {
+			t.Fatalf("%s 校验失败: %v", algo, err)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestDownloadIdleTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 4; i++ {
+			if r.URL.Path == "/stall" && i == 2 {
+				select {
+				case <-time.After(3 * time.Second):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(400 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	// 总耗时超过TimeOut，但持续有数据时不应中断
+	start := time.Now()
+	path := filepath.Join(dir, "slow.bin")
+	if _, err := Download(srv.URL+"/slow", path, &Opt{TimeOut: 1, NotLog: true}); err != nil {
+		t.Fatalf("慢速下载被中断: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "xxxx" || time.Since(start) < time.Second {
+		t.Fatalf("下载内容或耗时异常: %s %v", data, time.Since(start))
+	}
+
+	// 读取停滞超过TimeOut时中断
+	start = time.Now()
+	_, err := Download(srv.URL+"/stall", filepath.Join(dir, "stall.bin"), &Opt{TimeOut: 1, NotLog: true})
+	if err == nil || !strings.Contains(err.Error(), "下载超时") || time.Since(start) > 2500*time.Millisecond {
+		t.Fatalf("空闲超时未生效: %v %v", err, time.Since(start))
+	}
+}
@@ -0,0 +1,270 @@
+// Package graphql 基于ihttp的GraphQL客户端，支持变量、类型化解码、errors数组、持久化查询与批量请求
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Covsj/gokit/ihttp"
+)
+
+// Client GraphQL客户端
+//
+// 使用示例:
+//
+//	cli := graphql.NewClient("https://api.example.com/graphql")
+//	cli.HTTP.SetHeader("Authorization", "Bearer xxx")
+//	user, err := graphql.Query[struct{ User User }](ctx, cli,
+//		`query($id: ID!) { user(id: $id) { id name } }`, map[string]any{"id": 1})
+type Client struct {
+	// Endpoint 请求地址，相对路径时基于 HTTP.BaseURL 解析
+	Endpoint string
+
+	// HTTP 底层会话客户端，可设置请求头、代理、重试、中间件等，nil时自动创建，可在多个GraphQL客户端间共用
+	HTTP *ihttp.Client
+
+	// PersistedQueries 启用自动持久化查询(APQ)：先只发送查询哈希，服务端未缓存时再附带完整查询重发
+	PersistedQueries bool
+
+	mu          sync.Mutex
+	apqDisabled bool // 服务端不支持持久化查询
+}
+
+// NewClient 创建GraphQL客户端
+func NewClient(endpoint string) *Client {
+	return &Client{Endpoint: endpoint, HTTP: ihttp.NewClient("")}
+}
+
+// Request GraphQL操作
+type Request struct {
+	Query         string         `json:"query,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+}
+
+// Result 单个操作的响应
+type Result struct {
+	Data       json.RawMessage `json:"data,omitempty"`
+	Errors     Errors          `json:"errors,omitempty"`
+	Extensions map[string]any  `json:"extensions,omitempty"`
+}
+
+// Decode 将data解码到v，存在GraphQL错误时在解码后返回 Errors
+func (r *Result) Decode(v any) error {
+	if len(r.Data) > 0 && string(r.Data) != "null" && v != nil {
+		if err := json.Unmarshal(r.Data, v); err != nil {
+			return fmt.Errorf("GraphQL data解析失败: %w", err)
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
+
+// Location 错误在查询文本中的位置
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error errors数组中的单个错误
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return "GraphQL错误: " + e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("GraphQL错误: %s (路径: %s)", e.Message, strings.Join(path, "."))
+}
+
+// Code 返回extensions中的错误码，未设置为空
+func (e *Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// Errors 响应中的errors数组，可通过 errors.As 获取，也可直接 errors.As 到 *Error 取第一个错误
+type Errors []*Error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Message
+	}
+	return fmt.Sprintf("GraphQL错误(%d个): %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// has 判断是否包含指定错误码或消息
+func (e Errors) has(code, message string) bool {
+	for _, err := range e {
+		if err.Code() == code || err.Message == message {
+			return true
+		}
+	}
+	return false
+}
+
+// Exec 执行单个操作，返回的error为请求错误或GraphQL错误(Errors)，存在部分数据时Result仍非nil
+func (c *Client) Exec(ctx context.Context, req *Request) (*Result, error) {
+	if req == nil || req.Query == "" {
+		return nil, errors.New("空GraphQL查询")
+	}
+	if !c.PersistedQueries || c.persistedDisabled() {
+		return c.finish(c.post(ctx, req))
+	}
+
+	// 先只发送哈希，服务端未缓存该查询时再附带完整查询
+	short := *req
+	short.Query = ""
+	short.Extensions = withPersisted(req.Extensions, queryHash(req.Query))
+	result, err := c.post(ctx, &short)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case result.Errors.has("PERSISTED_QUERY_NOT_SUPPORTED", "PersistedQueryNotSupported"):
+		c.disablePersisted()
+		return c.finish(c.post(ctx, req))
+	case result.Errors.has("PERSISTED_QUERY_NOT_FOUND", "PersistedQueryNotFound"):
+		full := short
+		full.Query = req.Query
+		return c.finish(c.post(ctx, &full))
+	}
+	return c.finish(result, nil)
+}
+
+// Batch 在一次HTTP请求中发送多个操作，结果与reqs顺序一致，各操作的GraphQL错误在对应Result.Errors中
+// 批量请求始终发送完整查询，不使用持久化查询
+func (c *Client) Batch(ctx context.Context, reqs ...*Request) ([]*Result, error) {
+	if len(reqs) == 0 {
+		return nil, errors.New("空GraphQL批量请求")
+	}
+	results, _, err := ihttp.ClientDoJSON[[]*Result](ctx, c.http(), &ihttp.Opt{URL: c.Endpoint, Method: "POST", Json: reqs})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(reqs) {
+		return results, fmt.Errorf("GraphQL批量响应数量不匹配: 请求%d个, 响应%d个", len(reqs), len(results))
+	}
+	return results, nil
+}
+
+// post 发送单个操作，非2xx但响应体为GraphQL结果时按GraphQL错误返回
+func (c *Client) post(ctx context.Context, req *Request) (*Result, error) {
+	var errBody Result
+	result, _, err := ihttp.ClientDoJSON[*Result](ctx, c.http(), &ihttp.Opt{
+		URL:    c.Endpoint,
+		Method: "POST",
+		Json:   req,
+		ErrOut: &errBody,
+	})
+	if err != nil {
+		var httpErr *ihttp.HTTPError
+		if errors.As(err, &httpErr) && len(errBody.Errors) > 0 {
+			return &errBody, nil
+		}
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New("GraphQL响应为空")
+	}
+	return result, nil
+}
+
+// finish 将GraphQL错误转为error返回
+func (c *Client) finish(result *Result, err error) (*Result, error) {
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		return result, result.Errors
+	}
+	return result, nil
+}
+
+// http 返回会话客户端，请求地址由Opt.URL指定，不修改客户端配置
+func (c *Client) http() *ihttp.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.HTTP == nil {
+		c.HTTP = ihttp.NewClient("")
+	}
+	return c.HTTP
+}
+
+func (c *Client) persistedDisabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apqDisabled
+}
+
+func (c *Client) disablePersisted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apqDisabled = true
+}
+
+// queryHash 返回查询文本的SHA-256十六进制摘要
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// withPersisted 返回添加了persistedQuery扩展的副本
+func withPersisted(ext map[string]any, hash string) map[string]any {
+	out := make(map[string]any, len(ext)+1)
+	for k, v := range ext {
+		out[k] = v
+	}
+	out["persistedQuery"] = map[string]any{"version": 1, "sha256Hash": hash}
+	return out
+}
+
+// Do 执行操作并将data解码为T，存在GraphQL错误时返回已解码的部分数据与 Errors
+func Do[T any](ctx context.Context, c *Client, req *Request) (T, error) {
+	var out T
+	result, err := c.Exec(ctx, req)
+	if result == nil {
+		return out, err
+	}
+	err = result.Decode(&out)
+	return out, err
+}
+
+// Query 执行查询并将data解码为T
+func Query[T any](ctx context.Context, c *Client, query string, variables map[string]any) (T, error) {
+	return Do[T](ctx, c, &Request{Query: query, Variables: variables})
+}
+
+// Mutate 执行变更并将data解码为T
+func Mutate[T any](ctx context.Context, c *Client, mutation string, variables map[string]any) (T, error) {
+	return Do[T](ctx, c, &Request{Query: mutation, Variables: variables})
+}
@@ -0,0 +1,253 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Covsj/gokit/ihttp"
+)
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// fakeServer 简易GraphQL服务端，按查询文本中的关键字返回结果
+type fakeServer struct {
+	mu        sync.Mutex
+	requests  []string // 每次HTTP请求的原始请求体
+	persisted map[string]string
+	noAPQ     bool
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, string(body))
+	f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer t1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if strings.HasPrefix(string(body), "[") {
+		var reqs []*Request
+		json.Unmarshal(body, &reqs)
+		results := make([]map[string]any, len(reqs))
+		for i, req := range reqs {
+			results[i], _ = f.execute(req)
+		}
+		json.NewEncoder(w).Encode(results)
+		return
+	}
+
+	var req Request
+	json.Unmarshal(body, &req)
+	result, status := f.execute(&req)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeServer) execute(req *Request) (map[string]any, int) {
+	if pq, ok := req.Extensions["persistedQuery"].(map[string]any); ok {
+		if f.noAPQ {
+			return map[string]any{"errors": []map[string]any{{"message": "PersistedQueryNotSupported"}}}, 400
+		}
+		hash := pq["sha256Hash"].(string)
+		f.mu.Lock()
+		if req.Query != "" {
+			if queryHash(req.Query) != hash {
+				f.mu.Unlock()
+				return map[string]any{"errors": []map[string]any{{"message": "hash不匹配"}}}, 400
+			}
+			f.persisted[hash] = req.Query
+		}
+		query, found := f.persisted[hash]
+		f.mu.Unlock()
+		if !found {
+			return map[string]any{"errors": []map[string]any{{"message": "PersistedQueryNotFound",
+				"extensions": map[string]any{"code": "PERSISTED_QUERY_NOT_FOUND"}}}}, 200
+		}
+		req.Query = query
+	}
+
+	switch {
+	case strings.Contains(req.Query, "createUser"):
+		return map[string]any{"data": map[string]any{"createUser": map[string]any{"id": "2", "name": req.Variables["name"]}}}, 200
+	case strings.Contains(req.Query, "missing"):
+		return map[string]any{
+			"data": map[string]any{"user": map[string]any{"id": "1", "name": "tom"}, "missing": nil},
+			"errors": []map[string]any{{"message": "not found", "path": []any{"missing", 0},
+				"locations": []map[string]any{{"line": 1, "column": 30}}, "extensions": map[string]any{"code": "NOT_FOUND"}}},
+		}, 200
+	case strings.Contains(req.Query, "syntax"):
+		return map[string]any{"errors": []map[string]any{{"message": "Syntax Error"}}}, 400
+	case strings.Contains(req.Query, "user"):
+		return map[string]any{"data": map[string]any{"user": map[string]any{"id": req.Variables["id"], "name": "tom"}}}, 200
+	}
+	return map[string]any{"errors": []map[string]any{{"message": "unknown"}}}, 200
+}
+
+func newTestClient(t *testing.T, f *fakeServer) *Client {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cli := NewClient(srv.URL + "/graphql")
+	cli.HTTP.NotLog = true
+	cli.HTTP.SetHeader("Authorization", "Bearer t1")
+	return cli
+}
+
+func TestQueryAndMutate(t *testing.T) {
+	cli := newTestClient(t, &fakeServer{})
+	ctx := context.Background()
+
+	out, err := Query[struct{ User user }](ctx, cli, `query($id: ID!) { user(id: $id) { id name } }`, map[string]any{"id": "1"})
+	if err != nil || out.User.ID != "1" || out.User.Name != "tom" {
+		t.Fatalf("查询失败: %+v %v", out, err)
+	}
+
+	created, err := Mutate[struct {
+		CreateUser user `json:"createUser"`
+	}](ctx, cli, `mutation($name: String!) { createUser(name: $name) { id name } }`, map[string]any{"name": "amy"})
+	if err != nil || created.CreateUser.ID != "2" || created.CreateUser.Name != "amy" {
+		t.Fatalf("变更失败: %+v %v", created, err)
+	}
+}
+
+func TestSharedHTTPClient(t *testing.T) {
+	a, b := newTestClient(t, &fakeServer{}), newTestClient(t, &fakeServer{})
+	shared := a.HTTP
+	shared.BaseURL = "http://unused.invalid"
+	b.HTTP = shared
+
+	// 共用会话客户端时并发请求互不影响，且不修改其BaseURL
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		for _, cli := range []*Client{a, b} {
+			wg.Add(1)
+			go func(cli *Client) {
+				defer wg.Done()
+				_, err := Query[struct{ User user }](context.Background(), cli, `query { user { id } }`, nil)
+				errs <- err
+			}(cli)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("共用客户端请求失败: %v", err)
+		}
+	}
+	if shared.BaseURL != "http://unused.invalid" {
+		t.Fatalf("不应修改会话客户端BaseURL: %s", shared.BaseURL)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cli := newTestClient(t, &fakeServer{})
+	ctx := context.Background()
+
+	// 部分数据与errors数组同时返回
+	out, err := Query[struct{ User user }](ctx, cli, `{ user { id name } missing }`, nil)
+	var gqlErrs Errors
+	if !errors.As(err, &gqlErrs) || len(gqlErrs) != 1 {
+		t.Fatalf("期望Errors, 实际: %v", err)
+	}
+	e := gqlErrs[0]
+	if e.Code() != "NOT_FOUND" || e.Locations[0].Line != 1 || e.Error() != "GraphQL错误: not found (路径: missing.0)" {
+		t.Fatalf("错误内容错误: %+v %s", e, e.Error())
+	}
+	if out.User.Name != "tom" {
+		t.Fatalf("应返回部分数据: %+v", out)
+	}
+	var single *Error
+	if !errors.As(err, &single) || single.Message != "not found" {
+		t.Fatalf("应可直接获取*Error: %v", err)
+	}
+
+	// 非2xx响应中的errors数组
+	_, err = Query[map[string]any](ctx, cli, `{ syntax`, nil)
+	if !errors.As(err, &gqlErrs) || gqlErrs[0].Message != "Syntax Error" {
+		t.Fatalf("400响应应转为Errors: %v", err)
+	}
+
+	// 非GraphQL响应体返回HTTPError
+	cli.HTTP.DelHeader("Authorization")
+	_, err = Query[map[string]any](ctx, cli, `{ user { id } }`, nil)
+	var httpErr *ihttp.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("期望HTTPError, 实际: %v", err)
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	f := &fakeServer{persisted: map[string]string{}}
+	cli := newTestClient(t, f)
+	cli.PersistedQueries = true
+	ctx := context.Background()
+	query := `query($id: ID!) { user(id: $id) { id name } }`
+
+	for i := 0; i < 2; i++ {
+		out, err := Query[struct{ User user }](ctx, cli, query, map[string]any{"id": "7"})
+		if err != nil || out.User.ID != "7" {
+			t.Fatalf("第%d次查询失败: %+v %v", i+1, out, err)
+		}
+	}
+	// 首次: 仅哈希 -> 未找到 -> 哈希+查询；第二次: 仅哈希
+	if len(f.requests) != 3 {
+		t.Fatalf("请求次数错误: %d", len(f.requests))
+	}
+	if strings.Contains(f.requests[0], `"query"`) || !strings.Contains(f.requests[1], `"query"`) ||
+		strings.Contains(f.requests[2], `"query"`) {
+		t.Fatalf("持久化查询流程错误: %v", f.requests)
+	}
+
+	// 服务端不支持时退回完整查询
+	f2 := &fakeServer{noAPQ: true}
+	cli2 := newTestClient(t, f2)
+	cli2.PersistedQueries = true
+	for i := 0; i < 2; i++ {
+		if _, err := Query[struct{ User user }](ctx, cli2, query, map[string]any{"id": "7"}); err != nil {
+			t.Fatalf("退回完整查询失败: %v", err)
+		}
+	}
+	if len(f2.requests) != 3 || strings.Contains(f2.requests[2], "persistedQuery") {
+		t.Fatalf("不支持时应停用持久化查询: %v", f2.requests)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	f := &fakeServer{}
+	cli := newTestClient(t, f)
+
+	results, err := cli.Batch(context.Background(),
+		&Request{Query: `query($id: ID!) { user(id: $id) { id } }`, Variables: map[string]any{"id": "1"}},
+		&Request{Query: `{ unknown }`},
+		&Request{Query: `mutation { createUser(name: $name) { id } }`, Variables: map[string]any{"name": "bob"}},
+	)
+	if err != nil || len(results) != 3 || len(f.requests) != 1 {
+		t.Fatalf("批量请求失败: %v %d", err, len(f.requests))
+	}
+	var u struct{ User user }
+	if err := results[0].Decode(&u); err != nil || u.User.ID != "1" {
+		t.Fatalf("第一个结果错误: %+v %v", u, err)
+	}
+	if err := results[1].Decode(nil); err == nil || results[1].Errors[0].Message != "unknown" {
+		t.Fatalf("第二个结果应为错误: %v", err)
+	}
+	var c struct {
+		CreateUser user `json:"createUser"`
+	}
+	if err := results[2].Decode(&c); err != nil || c.CreateUser.Name != "bob" {
+		t.Fatalf("第三个结果错误: %+v %v", c, err)
+	}
+}
@@ -0,0 +1,676 @@
+package ihttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HARMode HAR工作模式
+type HARMode int
+
+const (
+	HARRecord HARMode = iota + 1 // 录制：正常发送请求，并记录请求与解压后的响应
+	HARReplay                    // 回放：不发送请求，从HAR中返回匹配的响应
+)
+
+// ErrHARNotFound 回放模式下HAR中没有与请求匹配的记录
+var ErrHARNotFound = errors.New("HAR中未找到匹配的请求")
+
+// harTimeFormat HAR使用的ISO 8601时间格式
+const harTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// HAR 按HAR 1.2格式录制或回放HTTP流量，可用于离线、可重复的调试与测试
+// 录制发生在Transport层，重定向的每一跳都会单独记录；Stream模式的响应在读取完毕或关闭时才会记录
+// 录制内容默认按全局脱敏规则处理，回放时请求按相同规则脱敏后再匹配
+//
+// 使用示例:
+//
+//	rec := ihttp.NewHARRecorder("signup.har")
+//	ihttp.SetHAR(rec) // 或 Opt.HAR / Client.HAR
+//	...
+//	rec.Close() // 写入文件
+//	har, _ := ihttp.LoadHAR("signup.har")
+//	ihttp.SetHAR(har) // 之后的请求从文件回放
+type HAR struct {
+	Mode HARMode
+
+	// Path 录制模式下调用 Flush 或 Close 时写入该文件，为空时仅保存在内存，可调用Save写入其他文件
+	Path string
+
+	// Match 自定义回放匹配规则，nil时按方法、URL(忽略查询参数顺序)与请求体(JSON/表单按语义比较)匹配
+	Match func(entry *HAREntry, req *http.Request, body []byte) bool
+
+	// Redactor 录制内容的脱敏规则，作用于URL查询参数、请求头、Cookie与文本请求/响应体
+	// nil时使用 SetRedactor 设置的全局规则，全局规则为nil时仍使用 DefaultRedactor
+	// Cookie与Set-Cookie仅替换值，保留名称与属性
+	Redactor *Redactor
+	// NoRedact 录制原始内容，包含凭证与完整请求体，仅应在可信环境中使用
+	NoRedact bool
+
+	mu    sync.Mutex
+	log   HARLog
+	used  []bool // 回放时各记录是否已使用
+	dirty bool   // 是否有尚未写入Path的记录
+}
+
+// HARFile HAR文件
+type HARFile struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog HAR日志
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator 生成HAR的工具
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry 一次请求与响应
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // 总耗时毫秒
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest 请求记录
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse 响应记录
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue 请求头、查询参数等键值对
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie Cookie记录
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// HARPostData 请求体记录，非UTF-8内容以base64保存并标记_encoding
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+// HARContent 响应体记录，非UTF-8内容以base64保存
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings 耗时记录(毫秒)，无法获取的阶段为-1
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NewHARRecorder 创建录制器，path非空时调用 Flush 或 Close 写入该文件
+func NewHARRecorder(path string) *HAR {
+	return &HAR{Mode: HARRecord, Path: path, log: newHARLog()}
+}
+
+// LoadHAR 读取HAR文件并创建回放器
+func LoadHAR(path string) (*HAR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开HAR文件失败: %w", err)
+	}
+	defer f.Close()
+	return ReadHAR(f)
+}
+
+// ReadHAR 从r读取HAR内容并创建回放器
+func ReadHAR(r io.Reader) (*HAR, error) {
+	var file HARFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("解析HAR失败: %w", err)
+	}
+	return &HAR{Mode: HARReplay, log: file.Log, used: make([]bool, len(file.Log.Entries))}, nil
+}
+
+func newHARLog() HARLog {
+	return HARLog{Version: "1.2", Creator: HARCreator{Name: "gokit/ihttp", Version: "1.0"}, Entries: []*HAREntry{}}
+}
+
+// Entries 返回当前全部记录
+func (h *HAR) Entries() []*HAREntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*HAREntry{}, h.log.Entries...)
+}
+
+// WriteTo 以HAR格式写出全部记录
+func (h *HAR) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	data, err := h.marshal()
+	h.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Save 将全部记录写入文件
+func (h *HAR) Save(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.save(path)
+}
+
+// Flush 将尚未写入的记录写入Path，Path为空或没有新记录时不做任何操作
+func (h *HAR) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Path == "" || !h.dirty {
+		return nil
+	}
+	if err := h.save(h.Path); err != nil {
+		return err
+	}
+	h.dirty = false
+	return nil
+}
+
+// Close 结束录制并写入Path，等同于 Flush
+func (h *HAR) Close() error {
+	return h.Flush()
+}
+
+func (h *HAR) save(path string) error {
+	data, err := h.marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("写入HAR文件失败: %w", err)
+	}
+	return nil
+}
+
+func (h *HAR) marshal() ([]byte, error) {
+	log := h.log
+	if log.Version == "" {
+		log = newHARLog()
+	}
+	data, err := json.MarshalIndent(HARFile{Log: log}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("HAR序列化失败: %w", err)
+	}
+	return data, nil
+}
+
+// add 追加一条录制记录
+func (h *HAR) add(entry *HAREntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.log.Version == "" {
+		h.log = newHARLog()
+	}
+	h.log.Entries = append(h.log.Entries, entry)
+	h.dirty = true
+}
+
+// harRedactor 未设置 HAR.Redactor 且全局脱敏规则关闭时使用的规则
+var harRedactor = DefaultRedactor()
+
+// redactor 返回录制与匹配使用的脱敏规则，NoRedact时返回nil
+func (h *HAR) redactor() *Redactor {
+	if h.NoRedact {
+		return nil
+	}
+	if h.Redactor != nil {
+		return h.Redactor
+	}
+	if r := resolveRedactor(nil); r != nil {
+		return r
+	}
+	return harRedactor
+}
+
+// find 查找回放记录，同一请求有多条记录时按录制顺序依次返回，用完后重复返回最后一条
+func (h *HAR) find(req *http.Request, body []byte) *HAREntry {
+	match := h.Match
+	if match == nil {
+		match = matchHAREntry
+		if r := h.redactor(); r != nil {
+			// 录制内容可能已脱敏，原始请求不匹配时按相同规则脱敏后再比较
+			redacted := *req
+			if u, err := url.Parse(r.URL(req.URL.String())); err == nil {
+				redacted.URL = u
+			}
+			redactedBody := harRedactBody(r, body)
+			match = func(entry *HAREntry, req *http.Request, body []byte) bool {
+				return matchHAREntry(entry, req, body) || matchHAREntry(entry, &redacted, redactedBody)
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.log.Entries) - len(h.used); n > 0 {
+		h.used = append(h.used, make([]bool, n)...)
+	}
+	last := -1
+	for i, entry := range h.log.Entries {
+		if !match(entry, req, body) {
+			continue
+		}
+		if !h.used[i] {
+			h.used[i] = true
+			return entry
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil
+	}
+	return h.log.Entries[last]
+}
+
+// wrap 返回使用HAR录制或回放的客户端副本，h为nil时原样返回
+func (h *HAR) wrap(client *http.Client) *http.Client {
+	if h == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &harTransport{har: h, base: base}
+	return &c
+}
+
+var (
+	harMu     sync.RWMutex
+	globalHAR *HAR
+)
+
+// SetHAR 设置全局HAR录制/回放，Opt.HAR 未设置时使用，传nil关闭
+func SetHAR(h *HAR) {
+	harMu.Lock()
+	defer harMu.Unlock()
+	globalHAR = h
+}
+
+// resolveHAR 返回本次请求使用的HAR
+func resolveHAR(opt *Opt) *HAR {
+	if opt.HAR != nil {
+		return opt.HAR
+	}
+	harMu.RLock()
+	defer harMu.RUnlock()
+	return globalHAR
+}
+
+// harTransport 在Transport层录制或回放请求
+type harTransport struct {
+	har  *HAR
+	base http.RoundTripper
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.har.Mode == HARReplay {
+		return t.har.replay(req)
+	}
+	return t.har.record(t.base, req)
+}
+
+// record 发送请求并在响应体读取完毕后记录
+func (h *HAR) record(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	reqBody := &lockedBuffer{}
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &teeBody{ReadCloser: req.Body, buf: reqBody}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	wait := time.Since(start)
+
+	// 记录解压后的内容，后续decodeBody不会重复解压
+	respBody := &lockedBuffer{}
+	resp.Body = &teeBody{ReadCloser: decodeBody(resp), buf: respBody, done: func(bool) {
+		h.add(newHAREntry(req, reqBody.bytes(), resp, respBody.bytes(), start, wait, h.redactor()))
+	}}
+	return resp, nil
+}
+
+// replay 返回与请求匹配的录制响应
+func (h *HAR) replay(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取请求体失败: %w", err)
+		}
+		body = data
+	}
+
+	entry := h.find(req, body)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrHARNotFound, req.Method, req.URL)
+	}
+	return entry.Response.toHTTP(req)
+}
+
+// toHTTP 将录制的响应还原为http.Response
+func (r *HARResponse) toHTTP(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Content.Encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(r.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("解码HAR响应体失败: %w", err)
+		}
+		body = data
+	} else {
+		body = []byte(r.Content.Text)
+	}
+
+	header := http.Header{}
+	for _, h := range r.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	// 响应体已是解压后的内容，浏览器导出的HAR也是如此
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+
+	proto := r.HTTPVersion
+	major, minor, ok := http.ParseHTTPVersion(strings.ToUpper(proto))
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, r.StatusText),
+		StatusCode:    r.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// newHAREntry 根据请求与响应生成记录，r不为nil时对记录内容脱敏
+func newHAREntry(req *http.Request, reqBody []byte, resp *http.Response,
+	respBody []byte, start time.Time, wait time.Duration, r *Redactor) *HAREntry {
+	total := time.Since(start)
+
+	reqURL := req.URL
+	if u, err := url.Parse(r.URL(req.URL.String())); err == nil {
+		reqURL = u
+	}
+	location := resp.Header.Get("Location")
+	if location != "" {
+		location = r.URL(location)
+	}
+
+	entry := &HAREntry{
+		StartedDateTime: start.Format(harTimeFormat),
+		Time:            harMillis(total),
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         reqURL.String(),
+			HTTPVersion: resp.Proto,
+			Cookies:     harCookies(req.Cookies(), r, "Cookie"),
+			Headers:     harHeaders(req.Header, r),
+			QueryString: harValues(reqURL.Query()),
+			HeadersSize: -1,
+			BodySize:    int64(len(reqBody)),
+		},
+		Response: HARResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Cookies:     harCookies(resp.Cookies(), r, "Set-Cookie"),
+			Headers:     harHeaders(resp.Header, r),
+			Content: HARContent{
+				Size:     int64(len(respBody)),
+				MimeType: resp.Header.Get("Content-Type"),
+			},
+			RedirectURL: location,
+			HeadersSize: -1,
+			BodySize:    int64(len(respBody)),
+		},
+		Timings: HARTimings{
+			Blocked: -1, DNS: -1, Connect: -1, SSL: -1,
+			Wait:    harMillis(wait),
+			Receive: harMillis(total - wait),
+		},
+	}
+	if resp.Uncompressed {
+		// 传输大小未知
+		entry.Response.BodySize = -1
+	}
+
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type")}
+		entry.Request.PostData.Text, entry.Request.PostData.Encoding = harText(harRedactBody(r, reqBody))
+	}
+	entry.Response.Content.Text, entry.Response.Content.Encoding = harText(harRedactBody(r, respBody))
+	return entry
+}
+
+// matchHAREntry 默认回放匹配规则
+func matchHAREntry(entry *HAREntry, req *http.Request, body []byte) bool {
+	if !strings.EqualFold(entry.Request.Method, req.Method) {
+		return false
+	}
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil || !sameURL(u, req.URL) {
+		return false
+	}
+
+	var recorded []byte
+	if pd := entry.Request.PostData; pd != nil {
+		if pd.Encoding == "base64" {
+			if recorded, err = base64.StdEncoding.DecodeString(pd.Text); err != nil {
+				return false
+			}
+		} else {
+			recorded = []byte(pd.Text)
+		}
+	}
+	return sameBody(req.Header.Get("Content-Type"), recorded, body)
+}
+
+// sameURL 比较URL，忽略查询参数顺序与片段
+func sameURL(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Host, b.Host) &&
+		a.EscapedPath() == b.EscapedPath() &&
+		a.Query().Encode() == b.Query().Encode()
+}
+
+// sameBody 比较请求体，JSON与表单按语义比较，multipart忽略分隔符
+func sameBody(contentType string, a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.Contains(mediaType, "json"):
+		var va, vb any
+		if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+			return false
+		}
+		return reflect.DeepEqual(va, vb)
+	case mediaType == "application/x-www-form-urlencoded":
+		va, errA := url.ParseQuery(string(a))
+		vb, errB := url.ParseQuery(string(b))
+		return errA == nil && errB == nil && va.Encode() == vb.Encode()
+	case strings.HasPrefix(mediaType, "multipart/"):
+		// 分隔符每次随机生成，取录制内容首行得到录制时的分隔符
+		boundary := params["boundary"]
+		line, _, _ := bytes.Cut(a, []byte("\r\n"))
+		recorded := strings.TrimPrefix(string(line), "--")
+		if boundary == "" || recorded == "" {
+			return false
+		}
+		return bytes.Equal(bytes.ReplaceAll(a, []byte(recorded), []byte(boundary)), b)
+	}
+	return false
+}
+
+// harText 返回用于保存的文本，非UTF-8内容使用base64
+func harText(data []byte) (text, encoding string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+// harRedactBody 对文本内容脱敏，二进制内容与未命中规则的内容保持原样，避免JSON重新序列化改变内容
+func harRedactBody(r *Redactor, body []byte) []byte {
+	if r == nil || len(body) == 0 || !utf8.Valid(body) {
+		return body
+	}
+	return []byte(r.textIfChanged(string(body)))
+}
+
+// harHeaders 按名称排序输出请求头，r不为nil时脱敏
+func harHeaders(header http.Header, r *Redactor) []HARNameValue {
+	list := harValues(header)
+	if r == nil {
+		return list
+	}
+	r.init()
+	for i := range list {
+		nv := &list[i]
+		switch name := strings.ToLower(nv.Name); {
+		case !r.headers[name]:
+			nv.Value = r.Text(nv.Value)
+		case name == "cookie":
+			nv.Value = maskCookies(nv.Value, r.mask())
+		case name == "set-cookie":
+			// 只处理首段name=value，保留Path等属性
+			pair, attrs, found := strings.Cut(nv.Value, ";")
+			nv.Value = maskCookies(pair, r.mask())
+			if found {
+				nv.Value += ";" + attrs
+			}
+		default:
+			nv.Value = r.mask()
+		}
+	}
+	return list
+}
+
+// maskCookies 将 "a=1; b=2" 中的值替换为mask
+func maskCookies(s, mask string) string {
+	parts := strings.Split(s, ";")
+	for i, part := range parts {
+		if name, _, ok := strings.Cut(part, "="); ok {
+			parts[i] = name + "=" + mask
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+func harValues(values map[string][]string) []HARNameValue {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := []HARNameValue{}
+	for _, k := range keys {
+		for _, v := range values[k] {
+			list = append(list, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return list
+}
+
+// harCookies 转换Cookie记录，r中配置了header对应的请求头时替换Cookie值
+func harCookies(cookies []*http.Cookie, r *Redactor, header string) []HARCookie {
+	mask := false
+	if r != nil {
+		r.init()
+		mask = r.headers[strings.ToLower(header)]
+	}
+	list := []HARCookie{}
+	for _, c := range cookies {
+		hc := HARCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain,
+			HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if mask {
+			hc.Value = r.mask()
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.Format(harTimeFormat)
+		}
+		list = append(list, hc)
+	}
+	return list
+}
+
+func harMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,197 @@
+package ihttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHARRecordReplay(t *testing.T) {
+	var polls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: body["user"], Path: "/"})
+			w.Write([]byte(`{"ok":true}`))
+		case "/inbox":
+			polls++
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write([]byte(strings.Repeat("邮件", polls)))
+			zw.Close()
+		case "/bin":
+			w.Write([]byte{0xff, 0x00, 0xfe})
+		}
+	}))
+
+	path := filepath.Join(t.TempDir(), "flow.har")
+	rec := NewHARRecorder(path)
+	// 回放需要原始Cookie
+	rec.NoRedact = true
+	flow := func(h *HAR) (sid string, inbox []string, bin []byte, err error) {
+		cli := NewClient(srv.URL)
+		cli.HAR = h
+		cli.NotLog = true
+		if _, err = cli.Post("/login", &Opt{Json: map[string]string{"user": "tom", "pwd": "1"}}); err != nil {
+			return
+		}
+		for _, c := range cli.Jar.Cookies(mustURL(srv.URL)) {
+			sid = c.Value
+		}
+		for i := 0; i < 3; i++ {
+			resp, e := cli.Get("/inbox?b=2&a=1", &Opt{Headers: map[string]string{"Accept-Encoding": "gzip"}})
+			if e != nil {
+				return sid, inbox, bin, e
+			}
+			inbox = append(inbox, resp.Text)
+		}
+		resp, err := cli.Get("/bin", nil)
+		if err != nil {
+			return
+		}
+		return sid, inbox, resp.Body, nil
+	}
+
+	sid, inbox, bin, err := flow(rec)
+	if err != nil {
+		t.Fatalf("录制失败: %v", err)
+	}
+	if len(rec.Entries()) != 5 {
+		t.Fatalf("记录数量错误: %d", len(rec.Entries()))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Close之前不应写入文件: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("写入HAR失败: %v", err)
+	}
+	entry := rec.Entries()[1]
+	if entry.Response.Content.Text != "邮件" || entry.Request.QueryString[0].Name != "a" {
+		t.Fatalf("记录内容错误: %+v", entry)
+	}
+	srv.Close()
+
+	// 服务端关闭后从文件回放
+	har, err := LoadHAR(path)
+	if err != nil {
+		t.Fatalf("加载HAR失败: %v", err)
+	}
+	sid2, inbox2, bin2, err := flow(har)
+	if err != nil {
+		t.Fatalf("回放失败: %v", err)
+	}
+	if sid2 != sid || sid != "tom" {
+		t.Fatalf("回放Cookie错误: %s", sid2)
+	}
+	// 同一请求按录制顺序返回，用完后重复最后一条
+	if strings.Join(inbox2, ",") != strings.Join(inbox, ",") || inbox2[2] != "邮件邮件邮件" {
+		t.Fatalf("回放顺序错误: %v", inbox2)
+	}
+	if !bytes.Equal(bin, bin2) {
+		t.Fatalf("二进制响应回放错误: %v", bin2)
+	}
+	resp, err := Get(srv.URL+"/inbox?a=1&b=2", &Opt{HAR: har, NotLog: true})
+	if err != nil || resp.Text != "邮件邮件邮件" {
+		t.Fatalf("记录用完后应重复最后一条: %v", err)
+	}
+
+	// 请求体不同则不匹配
+	_, err = Post(srv.URL+"/login", &Opt{HAR: har, NotLog: true, Json: map[string]string{"user": "amy"}})
+	if !errors.Is(err, ErrHARNotFound) {
+		t.Fatalf("期望ErrHARNotFound, 实际: %v", err)
+	}
+}
+
+func TestHARRedact(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s3cr3t", Path: "/"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"t-123","id":70224002415726915146697406828863644162}`))
+	}))
+	defer srv.Close()
+
+	rec := NewHARRecorder("")
+	login := func(h *HAR, pwd string) (*Response, error) {
+		return Post(srv.URL+"/login?access_token=q-1&page=2", &Opt{HAR: h, NotLog: true,
+			Headers: map[string]string{"Authorization": "Bearer abc", "Cookie": "sid=old; lang=zh"},
+			Json:    map[string]string{"user": "tom", "password": pwd}})
+	}
+	if _, err := login(rec, "p1"); err != nil {
+		t.Fatalf("录制失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rec.WriteTo(&buf)
+	out := buf.String()
+	for _, secret := range []string{"abc", "old", "zh", "s3cr3t", "q-1", "p1", "t-123"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("HAR中包含敏感内容 %q: %s", secret, out)
+		}
+	}
+	entry := rec.Entries()[0]
+	if !strings.Contains(out, "sid=***; lang=***") || !strings.Contains(out, "sid=***; Path=/") ||
+		entry.Request.URL != srv.URL+"/login?access_token=***&page=2" {
+		t.Fatalf("脱敏格式错误: %s", out)
+	}
+	// 未命中规则的内容保持原样，数字不丢失精度
+	if !strings.Contains(entry.Response.Content.Text, "70224002415726915146697406828863644162") {
+		t.Fatalf("响应体内容错误: %s", entry.Response.Content.Text)
+	}
+
+	// 回放时请求按相同规则脱敏后匹配
+	har, err := ReadHAR(&buf)
+	if err != nil {
+		t.Fatalf("解析HAR失败: %v", err)
+	}
+	if resp, err := login(har, "p2"); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("脱敏后的记录应能回放: %v", err)
+	}
+}
+
+func TestHARSameBody(t *testing.T) {
+	cases := []struct {
+		contentType string
+		a, b        string
+		same        bool
+	}{
+		{"application/json", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, true},
+		{"application/json", `{"a":1}`, `{"a":2}`, false},
+		{"application/x-www-form-urlencoded", "a=1&b=2", "b=2&a=1", true},
+		{"multipart/form-data; boundary=new", "--old\r\nx\r\n--old--", "--new\r\nx\r\n--new--", true},
+		{"text/plain", "a", "b", false},
+	}
+	for _, c := range cases {
+		if got := sameBody(c.contentType, []byte(c.a), []byte(c.b)); got != c.same {
+			t.Errorf("%s %q %q: 期望 %v", c.contentType, c.a, c.b, c.same)
+		}
+	}
+}
+
+func TestHARCustomMatch(t *testing.T) {
+	har, err := ReadHAR(strings.NewReader(`{"log":{"version":"1.2","entries":[
+		{"request":{"method":"POST","url":"https://example.com/token"},
+		 "response":{"status":201,"httpVersion":"HTTP/2.0","headers":[{"name":"Content-Encoding","value":"gzip"}],
+		 "content":{"mimeType":"application/json","text":"{\"token\":\"t1\"}"}}}]}}`))
+	if err != nil {
+		t.Fatalf("解析HAR失败: %v", err)
+	}
+	// 忽略请求体中的随机内容
+	har.Match = func(entry *HAREntry, req *http.Request, body []byte) bool {
+		return entry.Request.Method == req.Method && strings.HasSuffix(req.URL.Path, "/token")
+	}
+	out := map[string]string{}
+	resp, err := Post("https://example.com/token", &Opt{HAR: har, NotLog: true,
+		Json: map[string]string{"nonce": newRequestID()}, RespOut: &out})
+	if err != nil || resp.StatusCode != 201 || out["token"] != "t1" {
+		t.Fatalf("回放失败: %+v %v", out, err)
+	}
+}
@@ -1,13 +1,23 @@
 package ihttp
 
 import (
-	"fmt"
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/Covsj/gokit/ihttp/ihttptest"
 )
 
 func TestHttp(t *testing.T) {
+	tokenID := "70224002415726915146697406828863644162763565870559027191380082229342088681891"
+	srv := ihttptest.NewServer(t)
+	srv.On("GET", "/book").Query("token_id", tokenID).Header("User-Agent", "ihttp-test").
+		Reply(ihttptest.JSON(200, map[string]any{
+			"asset_id": tokenID,
+			"bids":     []map[string]string{{"price": "0.45", "size": "100"}},
+			"asks":     []map[string]string{{"price": "0.55", "size": "80"}},
+		}).AddHeader("Set-Cookie", "__cf_bm=abc; Path=/; HttpOnly").AddHeader("Set-Cookie", "sid=1; Path=/"))
+
 	var out map[string]any
 	cookies := make(map[string]string)
 
@@ -21,7 +31,7 @@ func TestHttp(t *testing.T) {
 	opt.HttpCLi = &http.Client{
 		Timeout: 30 * time.Second,
 	}
-	resp, err := Get("https://clob.polymarket.com/book?token_id=70224002415726915146697406828863644162763565870559027191380082229342088681891", opt)
+	resp, err := Get(srv.URL+"/book?token_id="+tokenID, opt)
 
 	if err != nil {
 		t.Fatalf("Request failed: %v", err)
@@ -30,7 +40,11 @@ func TestHttp(t *testing.T) {
 	if !resp.IsSuccess() {
 		t.Fatalf("Expected success status, got %d", resp.StatusCode)
 	}
-
-	fmt.Printf("Cookies after request: %+v\n", cookies)
-	fmt.Printf("Response: %+v\n", out)
+	if cookies["__cf_bm"] != "abc" || cookies["sid"] != "1" {
+		t.Fatalf("Cookies not updated: %+v", cookies)
+	}
+	if out["asset_id"] != tokenID || len(out["bids"].([]any)) != 1 {
+		t.Fatalf("Unexpected response: %+v", out)
+	}
+	srv.AssertExpectations()
 }
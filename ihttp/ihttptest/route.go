@@ -0,0 +1,335 @@
+package ihttptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Route 路由，匹配条件全部满足时按顺序返回预设响应，需在发送请求前配置完成
+type Route struct {
+	server *Server
+	method string
+	path   string
+
+	query   map[string]string
+	headers map[string]string
+	json    any
+	matches []func(req *Request) bool
+
+	responses []*Response
+	delay     time.Duration
+	times     int // 最多匹配次数，<=0不限
+
+	calls    int
+	requests []*Request
+}
+
+// String 返回 "方法 路径"，用于断言信息
+func (r *Route) String() string {
+	method := r.method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.path
+}
+
+// Query 要求查询参数key等于value
+func (r *Route) Query(key, value string) *Route {
+	if r.query == nil {
+		r.query = map[string]string{}
+	}
+	r.query[key] = value
+	return r
+}
+
+// Header 要求请求头key等于value，不区分key大小写
+func (r *Route) Header(key, value string) *Route {
+	if r.headers == nil {
+		r.headers = map[string]string{}
+	}
+	r.headers[key] = value
+	return r
+}
+
+// JSONBody 要求请求体为JSON且包含v中的全部字段，对象递归按子集比较，数组与其余值须完全相等
+func (r *Route) JSONBody(v any) *Route {
+	r.json = normalize(v)
+	return r
+}
+
+// Match 自定义匹配条件
+func (r *Route) Match(fn func(req *Request) bool) *Route {
+	r.matches = append(r.matches, fn)
+	return r
+}
+
+// Reply 追加响应序列，按调用顺序依次返回，序列用完后重复最后一个响应
+func (r *Route) Reply(responses ...*Response) *Route {
+	r.responses = append(r.responses, responses...)
+	return r
+}
+
+// ReplyJSON 追加一个JSON响应
+func (r *Route) ReplyJSON(status int, v any) *Route {
+	return r.Reply(JSON(status, v))
+}
+
+// Delay 为该路由的全部响应注入延迟，客户端取消请求时提前返回
+func (r *Route) Delay(d time.Duration) *Route {
+	r.delay = d
+	return r
+}
+
+// Times 限制路由最多匹配n次，之后的请求继续匹配后续路由，AssertExpectations 时要求恰好调用n次
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once 等同于 Times(1)
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// Calls 返回该路由已匹配的次数
+func (r *Route) Calls() int {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return r.calls
+}
+
+// Requests 返回该路由匹配到的请求
+func (r *Route) Requests() []*Request {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return append([]*Request{}, r.requests...)
+}
+
+// match 判断请求是否满足路由条件，返回路径参数
+func (r *Route) match(req *Request) (map[string]string, bool) {
+	if r.times > 0 && r.calls >= r.times {
+		return nil, false
+	}
+	if r.method != "" && r.method != "*" && r.method != req.Method {
+		return nil, false
+	}
+	params, ok := matchPath(r.path, req.Path)
+	if !ok {
+		return nil, false
+	}
+	for k, v := range r.query {
+		if vs, ok := req.Query[k]; !ok || !contains(vs, v) {
+			return nil, false
+		}
+	}
+	for k, v := range r.headers {
+		if req.Header.Get(k) != v {
+			return nil, false
+		}
+	}
+	if r.json != nil {
+		var body any
+		if err := json.Unmarshal(req.Body, &body); err != nil || !subset(r.json, body) {
+			return nil, false
+		}
+	}
+	for _, fn := range r.matches {
+		if !fn(req) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// next 记录调用并返回本次响应，调用方持有 Server.mu
+func (r *Route) next(req *Request) *Response {
+	r.calls++
+	r.requests = append(r.requests, req)
+
+	resp := &Response{Status: http.StatusOK}
+	if n := len(r.responses); n > 0 {
+		resp = r.responses[min(r.calls, n)-1]
+	}
+	if r.delay > 0 && resp.Delay == 0 {
+		copied := *resp
+		copied.Delay = r.delay
+		resp = &copied
+	}
+	return resp
+}
+
+// matchPath 匹配路由路径，支持 "{name}" 参数与 "/*" 前缀
+func matchPath(pattern, path string) (map[string]string, bool) {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return nil, path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	if !strings.Contains(pattern, "{") {
+		return nil, pattern == path
+	}
+	ps, segs := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(ps) != len(segs) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, p := range ps {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segs[i] == "" {
+				return nil, false
+			}
+			params[p[1:len(p)-1]] = segs[i]
+		} else if p != segs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize 将任意值经JSON往返转换为map/slice/float64等基础类型，便于比较
+func normalize(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("ihttptest: JSON序列化失败: %v", err))
+	}
+	var out any
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// subset 判断got是否包含want的全部字段
+func subset(want, got any) bool {
+	wm, ok := want.(map[string]any)
+	if !ok {
+		return reflect.DeepEqual(want, got)
+	}
+	gm, ok := got.(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, wv := range wm {
+		gv, ok := gm[k]
+		if !ok || !subset(wv, gv) {
+			return false
+		}
+	}
+	return true
+}
+
+// Response 预设响应
+type Response struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+
+	Delay time.Duration // 响应前等待的时间
+	Abort bool          // 不返回响应直接断开连接，模拟网络故障
+
+	// Handler 自定义处理函数，设置后忽略Status/Headers/Body
+	Handler http.HandlerFunc
+}
+
+// Status 仅包含状态码的响应
+func Status(status int) *Response {
+	return &Response{Status: status}
+}
+
+// Text 文本响应
+func Text(status int, text string) *Response {
+	return &Response{Status: status, Body: []byte(text),
+		Headers: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}}
+}
+
+// JSON JSON响应，v为[]byte或json.RawMessage时原样返回
+func JSON(status int, v any) *Response {
+	var body []byte
+	switch b := v.(type) {
+	case []byte:
+		body = b
+	case json.RawMessage:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			panic(fmt.Sprintf("ihttptest: JSON序列化失败: %v", err))
+		}
+	}
+	return &Response{Status: status, Body: body, Headers: http.Header{"Content-Type": {"application/json"}}}
+}
+
+// Abort 断开连接的响应，客户端收到网络错误
+func Abort() *Response {
+	return &Response{Abort: true}
+}
+
+// Handle 使用自定义处理函数的响应
+func Handle(fn http.HandlerFunc) *Response {
+	return &Response{Handler: fn}
+}
+
+// WithHeader 设置响应头，覆盖同名的已有值，返回自身便于链式调用
+func (r *Response) WithHeader(key, value string) *Response {
+	if r.Headers == nil {
+		r.Headers = http.Header{}
+	}
+	r.Headers.Set(key, value)
+	return r
+}
+
+// AddHeader 追加响应头，同名多值全部写出(如多个Set-Cookie)，返回自身便于链式调用
+func (r *Response) AddHeader(key, value string) *Response {
+	if r.Headers == nil {
+		r.Headers = http.Header{}
+	}
+	r.Headers.Add(key, value)
+	return r
+}
+
+// WithDelay 设置响应延迟，返回自身便于链式调用
+func (r *Response) WithDelay(d time.Duration) *Response {
+	r.Delay = d
+	return r
+}
+
+// write 写出响应
+func (r *Response) write(w http.ResponseWriter, req *http.Request) {
+	if r.Delay > 0 {
+		timer := time.NewTimer(r.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return
+		}
+	}
+	if r.Abort {
+		// 由http.Server中断连接且不输出日志
+		panic(http.ErrAbortHandler)
+	}
+	if r.Handler != nil {
+		r.Handler(w, req)
+		return
+	}
+	for k, vs := range r.Headers {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(r.Body)
+}
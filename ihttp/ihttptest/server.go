@@ -0,0 +1,164 @@
+// Package ihttptest 声明式Mock服务器，用于离线测试基于ihttp的代码
+//
+// 支持按方法、路径、查询参数、请求头与JSON请求体匹配路由，按顺序返回预设响应，
+// 注入延迟与连接中断，并记录收到的请求用于断言
+package ihttptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server Mock服务器，测试结束时自动关闭
+//
+// 使用示例:
+//
+//	srv := ihttptest.NewServer(t)
+//	srv.On("POST", "/users/{id}").JSONBody(map[string]any{"name": "tom"}).
+//		Reply(ihttptest.Status(500), ihttptest.JSON(200, map[string]any{"id": 1}))
+//	cli := ihttp.NewClient(srv.URL)
+//	...
+//	srv.AssertExpectations()
+type Server struct {
+	*httptest.Server
+
+	t        testing.TB
+	mu       sync.Mutex
+	routes   []*Route
+	requests []*Request
+}
+
+// Request 服务器收到的请求记录
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	Params map[string]string // 路径参数，如 "/users/{id}" 中的id
+
+	Route *Route // 匹配的路由，未匹配时为nil
+}
+
+// JSON 将请求体反序列化到v
+func (r *Request) JSON(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// NewServer 创建并启动HTTP Mock服务器，t结束时自动关闭
+func NewServer(t testing.TB) *Server {
+	s := &Server{t: t}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewTLSServer 创建并启动HTTPS Mock服务器，客户端需使用 Server.Client() 信任其证书
+func NewTLSServer(t testing.TB) *Server {
+	s := &Server{t: t}
+	s.Server = httptest.NewTLSServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// On 注册路由，method为空或"*"时匹配任意方法
+// path支持精确匹配、"{name}"路径参数与以"/*"结尾的前缀匹配，先注册的路由优先
+func (s *Server) On(method, path string) *Route {
+	r := &Route{server: s, method: strings.ToUpper(method), path: path}
+	s.mu.Lock()
+	s.routes = append(s.routes, r)
+	s.mu.Unlock()
+	return r
+}
+
+// Requests 返回收到的全部请求，按到达顺序
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request{}, s.requests...)
+}
+
+// Unmatched 返回未匹配任何路由的请求
+func (s *Server) Unmatched() []*Request {
+	var out []*Request
+	for _, req := range s.Requests() {
+		if req.Route == nil {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// Reset 清空路由与请求记录
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = nil
+	s.requests = nil
+}
+
+// AssertExpectations 校验调用次数：设置了Times的路由须恰好调用n次，其余路由至少调用一次，且不存在未匹配的请求
+func (s *Server) AssertExpectations() {
+	s.t.Helper()
+	s.mu.Lock()
+	routes := append([]*Route{}, s.routes...)
+	s.mu.Unlock()
+
+	for _, r := range routes {
+		calls := r.Calls()
+		switch {
+		case r.times > 0 && calls != r.times:
+			s.t.Errorf("ihttptest: 路由 %s 期望调用%d次，实际%d次", r, r.times, calls)
+		case r.times <= 0 && calls == 0:
+			s.t.Errorf("ihttptest: 路由 %s 未被调用", r)
+		}
+	}
+	for _, req := range s.Unmatched() {
+		s.t.Errorf("ihttptest: 未匹配的请求 %s %s", req.Method, req.Path)
+	}
+}
+
+// ServeHTTP 记录请求并按匹配的路由响应，未匹配时返回404
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("ihttptest: 读取请求体失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	req := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	s.mu.Lock()
+	var resp *Response
+	for _, route := range s.routes {
+		params, ok := route.match(req)
+		if !ok {
+			continue
+		}
+		req.Route, req.Params = route, params
+		resp = route.next(req)
+		break
+	}
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if resp == nil {
+		http.Error(w, fmt.Sprintf("ihttptest: 未匹配的请求 %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+		return
+	}
+	resp.write(w, r)
+}
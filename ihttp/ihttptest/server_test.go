@@ -0,0 +1,141 @@
+package ihttptest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Covsj/gokit/ihttp"
+)
+
+// recorder 记录断言失败而不终止测试
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
+
+func TestServerRouting(t *testing.T) {
+	srv := NewServer(t)
+	srv.On("GET", "/users/{id}").Query("expand", "1").ReplyJSON(200, map[string]any{"id": 1, "name": "tom"})
+	srv.On("GET", "/users/{id}").Reply(Text(200, "plain"))
+	srv.On("POST", "/users").Header("Authorization", "Bearer t1").
+		JSONBody(map[string]any{"name": "tom", "tags": []string{"a"}}).
+		Reply(Status(201).WithHeader("Location", "/users/2").AddHeader("Set-Cookie", "a=1").AddHeader("Set-Cookie", "b=2"))
+	srv.On("*", "/static/*").Reply(Text(200, "static"))
+
+	cli := ihttp.NewClient(srv.URL)
+	cli.NotLog = true
+
+	var user struct{ Name string }
+	resp, err := cli.Get("/users/1?expand=1", &ihttp.Opt{RespOut: &user})
+	if err != nil || user.Name != "tom" || resp.Headers["Content-Type"][0] != "application/json" {
+		t.Fatalf("参数路由错误: %v %+v", err, user)
+	}
+	if resp, _ = cli.Get("/users/1", nil); resp.Text != "plain" {
+		t.Fatalf("查询参数不匹配时应继续匹配后续路由: %s", resp.Text)
+	}
+
+	// JSON子集匹配，多余字段不影响
+	resp, err = cli.Post("/users", &ihttp.Opt{
+		Headers: map[string]string{"Authorization": "Bearer t1"},
+		Json:    map[string]any{"name": "tom", "tags": []string{"a"}, "age": 3},
+	})
+	if err != nil || resp.StatusCode != 201 || resp.Headers["Location"][0] != "/users/2" || len(resp.Headers["Set-Cookie"]) != 2 {
+		t.Fatalf("JSON路由错误: %v %+v", err, resp)
+	}
+	resp, _ = cli.Post("/users", &ihttp.Opt{Headers: map[string]string{"Authorization": "Bearer t1"}, Json: map[string]any{"name": "jim"}})
+	if resp.StatusCode != 404 {
+		t.Fatalf("请求体不匹配应返回404: %d", resp.StatusCode)
+	}
+	if resp, _ = cli.Get("/static/js/app.js", nil); resp.Text != "static" {
+		t.Fatalf("前缀路由错误: %s", resp.Text)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 5 || reqs[0].Params["id"] != "1" || reqs[0].Query.Get("expand") != "1" {
+		t.Fatalf("请求记录错误: %+v", reqs)
+	}
+	var body map[string]any
+	if err := reqs[2].JSON(&body); err != nil || body["age"] != float64(3) {
+		t.Fatalf("请求体记录错误: %v %v", err, body)
+	}
+	if len(srv.Unmatched()) != 1 || srv.Unmatched()[0].Method != "POST" {
+		t.Fatalf("未匹配记录错误: %+v", srv.Unmatched())
+	}
+
+	rec := &recorder{TB: t}
+	srv.t = rec
+	srv.AssertExpectations()
+	if len(rec.errors) != 1 {
+		t.Fatalf("断言应仅报告未匹配的请求: %v", rec.errors)
+	}
+	srv.t = t
+}
+
+func TestServerSequence(t *testing.T) {
+	srv := NewServer(t)
+	route := srv.On("GET", "/flaky").Reply(Abort(), Status(503), Text(200, "ok"))
+
+	// 连接中断与5xx经重试后成功
+	cli := ihttp.NewClient(srv.URL)
+	cli.NotLog = true
+	cli.Retry = &ihttp.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	resp, err := cli.Get("/flaky", nil)
+	if err != nil || resp.Text != "ok" || route.Calls() != 3 {
+		t.Fatalf("脚本化响应错误: %v %v %d", err, resp, route.Calls())
+	}
+	if resp, _ = cli.Get("/flaky", nil); resp.Text != "ok" {
+		t.Fatalf("序列用完后应重复最后一个响应: %s", resp.Text)
+	}
+
+	// Times 用完后交给后续路由
+	srv.Reset()
+	srv.On("GET", "/token").Once().ReplyJSON(200, map[string]any{"token": "a"})
+	srv.On("GET", "/token").ReplyJSON(429, map[string]any{"error": "too many"})
+	cli.Retry = nil
+	first, _ := cli.Get("/token", nil)
+	second, _ := cli.Get("/token", nil)
+	if first.StatusCode != 200 || second.StatusCode != 429 {
+		t.Fatalf("Times错误: %d %d", first.StatusCode, second.StatusCode)
+	}
+	srv.AssertExpectations()
+
+	rec := &recorder{TB: t}
+	srv.t = rec
+	srv.On("GET", "/never").Times(2)
+	srv.AssertExpectations()
+	if len(rec.errors) != 1 {
+		t.Fatalf("应报告调用次数不符: %v", rec.errors)
+	}
+	srv.t = t
+}
+
+func TestServerLatency(t *testing.T) {
+	srv := NewTLSServer(t)
+	srv.On("GET", "/slow").Delay(200 * time.Millisecond)
+	srv.On("GET", "/fast").Reply(Handle(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+
+	opt := &ihttp.Opt{HttpCLi: srv.Client(), NotLog: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	opt.URL = srv.URL + "/slow"
+	if _, err := ihttp.DoContext(ctx, opt); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 150*time.Millisecond {
+		t.Fatalf("延迟注入错误: %v %v", err, time.Since(start))
+	}
+
+	resp, err := ihttp.Get(srv.URL+"/fast", &ihttp.Opt{HttpCLi: srv.Client(), NotLog: true})
+	if err != nil || resp.Text != "HTTP/1.1" {
+		t.Fatalf("自定义处理错误: %v %+v", err, resp)
+	}
+}
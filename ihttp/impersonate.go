@@ -0,0 +1,695 @@
+package ihttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Covsj/gokit/iutil"
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
+	"golang.org/x/sync/singleflight"
+)
+
+// Profile 浏览器指纹伪装配置，对齐TLS ClientHello、ALPN、HTTP/2参数与HTTP/1.1请求头顺序
+//
+// 使用示例:
+//
+//	resp, err := ihttp.Get("https://example.com", &ihttp.Opt{Impersonate: ihttp.ProfileChrome})
+type Profile struct {
+	Name      string
+	HelloID   utls.ClientHelloID // TLS ClientHello指纹，包含ALPN
+	UserAgent func() string      // 请求未设置User-Agent时使用，对应iutil中的浏览器UA族
+
+	Headers     [][2]string // 默认请求头，请求中未设置时补充
+	ClientHints bool        // 是否根据User-Agent生成sec-ch-ua系列请求头(Chromium系)
+	// HeaderOrder HTTP/1.1请求头发送顺序及大小写，未列出的请求头排在其后
+	// 仅对HTTP/1.1生效：HTTP/2由x/net/http2编码，伪头部固定为 :authority :method :path :scheme，
+	// 其余请求头按map遍历顺序随机排列且名称统一为小写，该顺序不受此配置控制
+	HeaderOrder []string
+	HTTP2       HTTP2Settings
+
+	uaOnce sync.Once
+	ua     string
+}
+
+// HTTP2Settings HTTP/2连接参数，零值使用x/net/http2默认值
+// SETTINGS帧中的参数顺序与伪头部顺序由x/net/http2决定，无法调整
+type HTTP2Settings struct {
+	HeaderTableSize           uint32 // SETTINGS_HEADER_TABLE_SIZE
+	InitialWindowSize         uint32 // SETTINGS_INITIAL_WINDOW_SIZE
+	MaxFrameSize              uint32 // SETTINGS_MAX_FRAME_SIZE
+	MaxHeaderListSize         uint32 // SETTINGS_MAX_HEADER_LIST_SIZE，math.MaxUint32表示不发送
+	ConnectionWindowIncrement uint32 // 连接建立后WINDOW_UPDATE帧的增量，实际连接窗口为65535加该值
+}
+
+// http2InitialWindow HTTP/2协议规定的初始流量控制窗口
+const http2InitialWindow = 65535
+
+// connectionWindow 返回连接级接收窗口，即协议初始窗口加上WINDOW_UPDATE增量，0表示使用默认值
+func (s HTTP2Settings) connectionWindow() int {
+	if s.ConnectionWindowIncrement == 0 {
+		return 0
+	}
+	return min(http2InitialWindow+int(s.ConnectionWindowIncrement), math.MaxInt32)
+}
+
+// 预置浏览器配置
+var (
+	ProfileChrome = &Profile{
+		Name:        "chrome",
+		HelloID:     utls.HelloChrome_Auto,
+		UserAgent:   iutil.Chrome,
+		ClientHints: true,
+		Headers: [][2]string{
+			{"Connection", "keep-alive"},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-User", "?1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Accept-Encoding", AcceptEncoding},
+			{"Accept-Language", "en-US,en;q=0.9"},
+			{"Priority", "u=0, i"},
+		},
+		HeaderOrder: []string{
+			"Host", "Connection", "Content-Length",
+			"sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform",
+			"Upgrade-Insecure-Requests", "Origin", "Content-Type", "User-Agent", "Accept",
+			"Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest",
+			"Referer", "Accept-Encoding", "Accept-Language", "Cookie", "Priority",
+		},
+		HTTP2: HTTP2Settings{
+			HeaderTableSize:           65536,
+			InitialWindowSize:         6291456,
+			MaxHeaderListSize:         262144,
+			ConnectionWindowIncrement: 15663105,
+		},
+	}
+
+	ProfileFirefox = &Profile{
+		Name:      "firefox",
+		HelloID:   utls.HelloFirefox_Auto,
+		UserAgent: iutil.Firefox,
+		Headers: [][2]string{
+			{"Connection", "keep-alive"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"Accept-Language", "en-US,en;q=0.5"},
+			{"Accept-Encoding", AcceptEncoding},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-User", "?1"},
+			{"Priority", "u=0, i"},
+		},
+		HeaderOrder: []string{
+			"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding",
+			"Content-Type", "Content-Length", "Origin", "Connection", "Referer", "Cookie",
+			"Upgrade-Insecure-Requests", "Sec-Fetch-Dest", "Sec-Fetch-Mode", "Sec-Fetch-Site",
+			"Sec-Fetch-User", "Priority", "TE",
+		},
+		HTTP2: HTTP2Settings{
+			HeaderTableSize:           65536,
+			InitialWindowSize:         131072,
+			MaxFrameSize:              16384,
+			MaxHeaderListSize:         math.MaxUint32,
+			ConnectionWindowIncrement: 12517377,
+		},
+	}
+
+	ProfileSafari = &Profile{
+		Name:      "safari",
+		HelloID:   utls.HelloSafari_Auto,
+		UserAgent: iutil.Safari,
+		Headers: [][2]string{
+			{"Connection", "keep-alive"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Priority", "u=0, i"},
+		},
+		HeaderOrder: []string{
+			"Host", "Content-Type", "Accept", "Sec-Fetch-Site", "Accept-Language",
+			"Sec-Fetch-Mode", "Accept-Encoding", "Origin", "Content-Length", "User-Agent",
+			"Referer", "Sec-Fetch-Dest", "Connection", "Cookie", "Priority",
+		},
+		HTTP2: HTTP2Settings{
+			InitialWindowSize:         4194304,
+			MaxHeaderListSize:         math.MaxUint32,
+			ConnectionWindowIncrement: 10485760,
+		},
+	}
+)
+
+// ProfileByName 按名称获取预置配置(chrome/firefox/safari)，不存在返回nil
+func ProfileByName(name string) *Profile {
+	switch strings.ToLower(name) {
+	case "chrome":
+		return ProfileChrome
+	case "firefox":
+		return ProfileFirefox
+	case "safari":
+		return ProfileSafari
+	}
+	return nil
+}
+
+// userAgent 返回该配置的默认UA，同一配置在进程内保持一致
+func (p *Profile) userAgent() string {
+	p.uaOnce.Do(func() {
+		if p.UserAgent != nil {
+			p.ua = p.UserAgent()
+		}
+	})
+	return p.ua
+}
+
+// headers 返回需要补充的默认请求头，ua为本次请求实际使用的User-Agent
+func (p *Profile) headers(ua string) [][2]string {
+	headers := make([][2]string, 0, len(p.Headers)+4)
+	if ua != "" {
+		headers = append(headers, [2]string{"User-Agent", ua})
+	}
+	if p.ClientHints {
+		headers = append(headers, clientHints(ua)...)
+	}
+	return append(headers, p.Headers...)
+}
+
+var chromeVersionRe = regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)
+
+// clientHints 根据Chrome UA生成sec-ch-ua系列请求头，保证与UA版本、平台一致
+func clientHints(ua string) [][2]string {
+	version := "133"
+	if m := chromeVersionRe.FindStringSubmatch(ua); m != nil {
+		version = m[1]
+	}
+	mobile := "?0"
+	if strings.Contains(ua, "Mobile") {
+		mobile = "?1"
+	}
+	platform := "Windows"
+	switch {
+	case strings.Contains(ua, "Android"):
+		platform = "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		platform = "iOS"
+	case strings.Contains(ua, "CrOS"):
+		platform = "Chrome OS"
+	case strings.Contains(ua, "Macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "Linux"):
+		platform = "Linux"
+	}
+	return [][2]string{
+		{"sec-ch-ua", fmt.Sprintf(`"Not(A:Brand";v="99", "Google Chrome";v="%s", "Chromium";v="%s"`, version, version)},
+		{"sec-ch-ua-mobile", mobile},
+		{"sec-ch-ua-platform", strconv.Quote(platform)},
+	}
+}
+
+// impersonateTransports 按配置、代理与基础Transport缓存伪装Transport
+var impersonateTransports = newTransportCache(maxCachedTransports)
+
+type impersonateKey struct {
+	profile *Profile
+	proxy   string
+	base    *http.Transport
+}
+
+// clientWithImpersonate 返回使用伪装Transport的客户端副本，不修改base
+// base为*http.Transport时沿用其TLSClientConfig中的RootCAs与InsecureSkipVerify
+func clientWithImpersonate(base *http.Client, profile *Profile, proxy string) (*http.Client, error) {
+	var proxyURL *url.URL
+	if proxy != "" {
+		u, err := parseProxyURL(proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxyURL = u
+	}
+	baseTransport, _ := base.Transport.(*http.Transport)
+
+	key := impersonateKey{profile: profile, proxy: proxy, base: baseTransport}
+	tr, _ := impersonateTransports.get(key, func() (http.RoundTripper, error) {
+		return newImpersonateTransport(profile, proxyURL, baseTransport), nil
+	})
+
+	c := *base
+	c.Transport = tr
+	return &c, nil
+}
+
+// impersonateTransport 使用uTLS握手，按ALPN协商结果分发到HTTP/2或HTTP/1.1
+type impersonateTransport struct {
+	profile *Profile
+	proxy   *url.URL
+	tlsConf *utls.Config
+	dialer  *net.Dialer
+	h1      *http.Transport
+	h2      *http2.Transport
+
+	mu      sync.Mutex
+	h2conns map[string]*http2.ClientConn
+	h1hosts map[string]bool       // ALPN协商为http/1.1的地址
+	pending map[string][]net.Conn // 已完成握手、等待HTTP/1.1使用的连接
+	dials   singleflight.Group    // 同一地址并发的首次请求共用一次握手
+}
+
+func newImpersonateTransport(profile *Profile, proxyURL *url.URL, base *http.Transport) *impersonateTransport {
+	t := &impersonateTransport{
+		profile: profile,
+		proxy:   proxyURL,
+		tlsConf: &utls.Config{},
+		dialer:  &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		h2conns: map[string]*http2.ClientConn{},
+		h1hosts: map[string]bool{},
+		pending: map[string][]net.Conn{},
+	}
+	if base != nil && base.TLSClientConfig != nil {
+		t.tlsConf.RootCAs = base.TLSClientConfig.RootCAs
+		t.tlsConf.InsecureSkipVerify = base.TLSClientConfig.InsecureSkipVerify
+	}
+
+	t.h1 = &http.Transport{
+		DialContext:         t.dialH1,
+		DialTLSContext:      t.dialH1TLS,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	s := profile.HTTP2
+	conf := &http.HTTP2Config{
+		MaxDecoderHeaderTableSize: int(s.HeaderTableSize),
+		MaxReadFrameSize:          int(s.MaxFrameSize),
+		MaxReceiveBufferPerStream: int(s.InitialWindowSize),
+	}
+	if window := s.connectionWindow(); window > 0 {
+		// x/net/http2将该值作为首个WINDOW_UPDATE的增量发送，连接窗口为65535加该值
+		conf.MaxReceiveBufferPerConnection = window - http2InitialWindow
+	}
+	t1 := &http.Transport{HTTP2: conf}
+	t.h2, _ = http2.ConfigureTransports(t1)
+	t.h2.MaxHeaderListSize = s.MaxHeaderListSize
+	t.h2.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
+func (t *impersonateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.h1.RoundTrip(req)
+	}
+	addr := canonicalAddr(req.URL)
+
+	if cc := t.h2Conn(addr); cc != nil {
+		resp, err := cc.RoundTrip(req)
+		if err == nil {
+			return resp, nil
+		}
+		// 调用方取消不代表连接失效
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		t.removeH2(addr, cc)
+		// 仅在请求确定未被服务端处理时换新连接重发，避免重复提交非幂等请求
+		if !h2RequestUnsent(err) || (req.Body != nil && req.GetBody == nil) {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	t.mu.Lock()
+	h1 := t.h1hosts[addr]
+	t.mu.Unlock()
+	if h1 {
+		return t.h1.RoundTrip(req)
+	}
+
+	// 握手不随单个请求取消，避免影响共用该握手的其他请求
+	ctx := req.Context()
+	ch := t.dials.DoChan(addr, func() (any, error) {
+		dialCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), t.dialer.Timeout)
+		defer cancel()
+		return t.connect(dialCtx, addr)
+	})
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	if cc := res.Val.(*http2.ClientConn); cc != nil {
+		return cc.RoundTrip(req)
+	}
+	return t.h1.RoundTrip(req)
+}
+
+// h2UnsentErrors http2未导出的错误，表示连接不可用或GOAWAY时流未被服务端处理
+var h2UnsentErrors = []string{
+	"http2: client conn not usable",
+	"http2: Transport received Server's graceful shutdown GOAWAY",
+}
+
+// h2RequestUnsent 判断请求是否确定未被服务端处理，可安全换连接重发
+func h2RequestUnsent(err error) bool {
+	if errors.Is(err, http2.ErrNoCachedConn) {
+		return true
+	}
+	var se http2.StreamError
+	if errors.As(err, &se) && se.Code == http2.ErrCodeRefusedStream {
+		return true
+	}
+	return slices.Contains(h2UnsentErrors, err.Error())
+}
+
+// connect 建立到addr的TLS连接，协商为h2时返回保存的ClientConn
+// 服务端不支持h2时连接交给HTTP/1.1 Transport使用并返回nil
+func (t *impersonateTransport) connect(ctx context.Context, addr string) (*http2.ClientConn, error) {
+	// 之前的握手可能已建立可用连接
+	if cc := t.h2Conn(addr); cc != nil {
+		return cc, nil
+	}
+	conn, err := t.dialTLS(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if conn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		t.mu.Lock()
+		t.h1hosts[addr] = true
+		t.pending[addr] = append(t.pending[addr], conn)
+		t.mu.Unlock()
+		return nil, nil
+	}
+	cc, err := t.h2.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	t.h2conns[addr] = cc
+	t.mu.Unlock()
+	return cc, nil
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (t *impersonateTransport) CloseIdleConnections() {
+	t.h1.CloseIdleConnections()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, cc := range t.h2conns {
+		if cc.State().StreamsActive == 0 {
+			cc.Close()
+			delete(t.h2conns, addr)
+		}
+	}
+}
+
+func (t *impersonateTransport) h2Conn(addr string) *http2.ClientConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cc := t.h2conns[addr]
+	if cc != nil && !cc.CanTakeNewRequest() {
+		delete(t.h2conns, addr)
+		return nil
+	}
+	return cc
+}
+
+func (t *impersonateTransport) removeH2(addr string, cc *http2.ClientConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.h2conns[addr] == cc {
+		delete(t.h2conns, addr)
+	}
+}
+
+// dialH1 HTTP/1.1明文连接
+func (t *impersonateTransport) dialH1(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := t.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return t.ordered(conn), nil
+}
+
+// dialH1TLS HTTP/1.1 TLS连接，优先使用ALPN协商后转交的连接
+func (t *impersonateTransport) dialH1TLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	var conn net.Conn
+	if conns := t.pending[addr]; len(conns) > 0 {
+		conn, t.pending[addr] = conns[0], conns[1:]
+	}
+	t.mu.Unlock()
+
+	if conn == nil {
+		uconn, err := t.dialTLS(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		if uconn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+			uconn.Close()
+			return nil, errors.New("服务端协议协商结果不一致")
+		}
+		conn = uconn
+	}
+	return t.ordered(conn), nil
+}
+
+// dialTLS 建立连接并使用配置的ClientHello完成TLS握手
+func (t *impersonateTransport) dialTLS(ctx context.Context, addr string) (*utls.UConn, error) {
+	raw, err := t.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	conf := t.tlsConf.Clone()
+	conf.ServerName = host
+
+	uconn := utls.UClient(raw, conf, t.profile.HelloID)
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("TLS握手失败: %w", err)
+	}
+	return uconn, nil
+}
+
+// dial 建立TCP连接，配置代理时通过代理建立隧道
+func (t *impersonateTransport) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if t.proxy == nil {
+		return t.dialer.DialContext(ctx, "tcp", addr)
+	}
+	switch t.proxy.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(t.proxy, t.dialer)
+		if err != nil {
+			return nil, fmt.Errorf("创建代理失败: %v", err)
+		}
+		return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	default:
+		return t.dialConnect(ctx, addr)
+	}
+}
+
+// dialConnect 通过HTTP(S)代理的CONNECT方法建立隧道
+func (t *impersonateTransport) dialConnect(ctx context.Context, addr string) (net.Conn, error) {
+	proxyAddr := canonicalAddr(t.proxy)
+	conn, err := t.dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if t.proxy.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: t.proxy.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("代理TLS握手失败: %w", err)
+		}
+		conn = tc
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := t.proxy.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("代理请求失败: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("读取代理响应失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("代理连接失败, 状态码: %d", resp.StatusCode)
+	}
+	return conn, nil
+}
+
+// ordered 包装连接，使HTTP/1.1请求头按配置顺序发送
+func (t *impersonateTransport) ordered(conn net.Conn) net.Conn {
+	if len(t.profile.HeaderOrder) == 0 {
+		return conn
+	}
+	return &orderedConn{Conn: conn, order: t.profile.HeaderOrder}
+}
+
+// canonicalAddr 返回 host:port，缺省端口按协议补全
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// orderedConn 重排写出的HTTP/1.1请求头
+// 请求体长度由Content-Length确定，遇到chunked请求体后该连接不再重排
+type orderedConn struct {
+	net.Conn
+	order []string
+
+	buf         []byte
+	remaining   int64 // 当前请求体剩余字节数
+	passthrough bool
+}
+
+func (c *orderedConn) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if c.passthrough {
+			if _, err := c.Conn.Write(p); err != nil {
+				return 0, err
+			}
+			break
+		}
+		if c.remaining > 0 {
+			k := int64(len(p))
+			if k > c.remaining {
+				k = c.remaining
+			}
+			if _, err := c.Conn.Write(p[:k]); err != nil {
+				return 0, err
+			}
+			c.remaining -= k
+			p = p[k:]
+			continue
+		}
+
+		c.buf = append(c.buf, p...)
+		p = nil
+		idx := bytes.Index(c.buf, []byte("\r\n\r\n"))
+		if idx < 0 {
+			break
+		}
+		head, rest := c.buf[:idx+4], c.buf[idx+4:]
+		c.buf = nil
+
+		reordered, bodyLen, chunked := reorderHeader(head, c.order)
+		if _, err := c.Conn.Write(reordered); err != nil {
+			return 0, err
+		}
+		c.remaining = bodyLen
+		c.passthrough = chunked
+		p = rest
+	}
+	return n, nil
+}
+
+// reorderHeader 按order重排请求头块，返回新的请求头、请求体长度及是否为chunked
+func reorderHeader(head []byte, order []string) ([]byte, int64, bool) {
+	lines := strings.Split(strings.TrimSuffix(string(head), "\r\n\r\n"), "\r\n")
+	var bodyLen int64
+	var chunked bool
+
+	type field struct {
+		name, line string
+		used       bool
+	}
+	fields := make([]*field, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.EqualFold(name, "Content-Length"):
+			bodyLen, _ = strconv.ParseInt(value, 10, 64)
+		case strings.EqualFold(name, "Transfer-Encoding") && strings.Contains(strings.ToLower(value), "chunked"):
+			chunked = true
+		}
+		fields = append(fields, &field{name: name, line: line})
+	}
+
+	var b strings.Builder
+	b.Grow(len(head))
+	b.WriteString(lines[0])
+	b.WriteString("\r\n")
+	for _, name := range order {
+		for _, f := range fields {
+			if !f.used && strings.EqualFold(f.name, name) {
+				f.used = true
+				b.WriteString(name)
+				b.WriteString(f.line[len(f.name):])
+				b.WriteString("\r\n")
+			}
+		}
+	}
+	for _, f := range fields {
+		if !f.used {
+			b.WriteString(f.line)
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String()), bodyLen, chunked
+}
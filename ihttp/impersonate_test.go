@@ -0,0 +1,450 @@
+package ihttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// helloRecorder 记录服务端收到的ClientHello
+type helloRecorder struct {
+	mu    sync.Mutex
+	hello *tls.ClientHelloInfo
+}
+
+func (h *helloRecorder) config(next []string) *tls.Config {
+	return &tls.Config{
+		NextProtos: next,
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			h.mu.Lock()
+			h.hello = info
+			h.mu.Unlock()
+			return nil, nil
+		},
+	}
+}
+
+func (h *helloRecorder) get() *tls.ClientHelloInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hello
+}
+
+func hasGrease(values []uint16) bool {
+	for _, v := range values {
+		if v&0x0f0f == 0x0a0a {
+			return true
+		}
+	}
+	return false
+}
+
+func TestImpersonateClientHello(t *testing.T) {
+	rec := &helloRecorder{}
+	var proto, ua, hint string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto, ua, hint = r.Proto, r.UserAgent(), r.Header.Get("sec-ch-ua")
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = rec.config(nil)
+	srv.StartTLS()
+	defer srv.Close()
+
+	// 标准库握手作为对照
+	if _, err := Get(srv.URL, &Opt{HttpCLi: srv.Client(), NotLog: true}); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if hasGrease(rec.get().CipherSuites) {
+		t.Fatalf("标准库ClientHello不应包含GREASE")
+	}
+
+	cases := []struct {
+		profile *Profile
+		grease  bool
+		ext     uint16 // 该浏览器特有的扩展
+		uaMark  string
+	}{
+		{ProfileChrome, true, 0x001b, "Chrome"},    // compress_certificate
+		{ProfileFirefox, false, 0x001c, "Firefox"}, // record_size_limit
+		{ProfileSafari, true, 0x001b, "Safari"},
+	}
+	for _, c := range cases {
+		_, err := Get(srv.URL, &Opt{HttpCLi: srv.Client(), Impersonate: c.profile, NotLog: true})
+		if err != nil {
+			t.Fatalf("%s 请求失败: %v", c.profile.Name, err)
+		}
+		hello := rec.get()
+		if hasGrease(hello.CipherSuites) != c.grease {
+			t.Errorf("%s GREASE不符合预期: %x", c.profile.Name, hello.CipherSuites)
+		}
+		if !slices.Equal(hello.SupportedProtos, []string{"h2", "http/1.1"}) {
+			t.Errorf("%s ALPN错误: %v", c.profile.Name, hello.SupportedProtos)
+		}
+		if !slices.Contains(hello.Extensions, c.ext) {
+			t.Errorf("%s 缺少扩展 %#04x: %x", c.profile.Name, c.ext, hello.Extensions)
+		}
+		if proto != "HTTP/2.0" {
+			t.Errorf("%s 未协商HTTP/2: %s", c.profile.Name, proto)
+		}
+		if !strings.Contains(ua, c.uaMark) {
+			t.Errorf("%s UA与配置不一致: %s", c.profile.Name, ua)
+		}
+		if (hint != "") != c.profile.ClientHints {
+			t.Errorf("%s sec-ch-ua不符合预期: %q", c.profile.Name, hint)
+		}
+	}
+}
+
+func TestImpersonateHTTP2Settings(t *testing.T) {
+	cert := httptest.NewTLSServer(http.NotFoundHandler())
+	certificate := cert.TLS.Certificates[0]
+	pool := cert.Client()
+	cert.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		NextProtos:   []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type result struct {
+		settings map[http2.SettingID]uint32
+		window   uint32
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(conn, preface); err != nil {
+			return
+		}
+		res := result{settings: map[http2.SettingID]uint32{}}
+		fr := http2.NewFramer(io.Discard, conn)
+		for i := 0; i < 2; i++ {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				break
+			}
+			switch f := f.(type) {
+			case *http2.SettingsFrame:
+				f.ForeachSetting(func(s http2.Setting) error {
+					res.settings[s.ID] = s.Val
+					return nil
+				})
+			case *http2.WindowUpdateFrame:
+				res.window = f.Increment
+			}
+		}
+		done <- res
+	}()
+
+	Get("https://"+ln.Addr().String(), &Opt{HttpCLi: pool, Impersonate: ProfileChrome, TimeOut: 2, NotLog: true})
+	res := <-done
+
+	want := ProfileChrome.HTTP2
+	if res.settings[http2.SettingHeaderTableSize] != want.HeaderTableSize ||
+		res.settings[http2.SettingInitialWindowSize] != want.InitialWindowSize ||
+		res.settings[http2.SettingMaxHeaderListSize] != want.MaxHeaderListSize ||
+		res.settings[http2.SettingEnablePush] != 0 {
+		t.Fatalf("SETTINGS不符合配置: %v", res.settings)
+	}
+	if res.window != want.ConnectionWindowIncrement {
+		t.Fatalf("WINDOW_UPDATE错误: %d", res.window)
+	}
+	// Chrome的连接窗口为15MB: 初始65535加WINDOW_UPDATE增量
+	if want.connectionWindow() != 15728640 || (HTTP2Settings{}).connectionWindow() != 0 {
+		t.Fatalf("连接窗口计算错误: %d", want.connectionWindow())
+	}
+}
+
+func TestImpersonateConcurrentDial(t *testing.T) {
+	var conns atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// 并发的首次请求共用一次握手，不会各自建立连接后相互覆盖
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Get(srv.URL, &Opt{HttpCLi: srv.Client(), Impersonate: ProfileFirefox, NotLog: true})
+			errs <- err
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+	}
+	if got := conns.Load(); got != 1 {
+		t.Fatalf("期望复用1个HTTP/2连接, 实际建立%d个", got)
+	}
+}
+
+func TestImpersonateHTTP2NoReplay(t *testing.T) {
+	var conns, posts atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/abort":
+			posts.Add(1)
+			io.ReadAll(r.Body)
+			panic(http.ErrAbortHandler)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	opt := func() *Opt { return &Opt{HttpCLi: srv.Client(), Impersonate: ProfileFirefox, NotLog: true} }
+
+	if _, err := Get(srv.URL, opt()); err != nil {
+		t.Fatalf("建立连接失败: %v", err)
+	}
+	// 请求已发出后连接出错，不应在新连接上重发POST
+	if _, err := Post(srv.URL+"/abort", &Opt{HttpCLi: srv.Client(), Impersonate: ProfileFirefox, NotLog: true, Body: []byte("x")}); err == nil {
+		t.Fatal("期望请求失败")
+	}
+	if got := posts.Load(); got != 1 {
+		t.Fatalf("已发出的POST被重发: %d次", got)
+	}
+
+	// 调用方取消不应丢弃可用连接
+	o := opt()
+	o.TimeOut = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := GetContext(ctx, srv.URL+"/slow", o); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望超时, 实际: %v", err)
+	}
+	before := conns.Load()
+	if _, err := Get(srv.URL, opt()); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if got := conns.Load(); got != before {
+		t.Fatalf("取消请求后应复用原连接, 连接数 %d -> %d", before, got)
+	}
+}
+
+func TestImpersonateHTTP2HeaderOrderUncontrolled(t *testing.T) {
+	cert := httptest.NewTLSServer(http.NotFoundHandler())
+	certificate := cert.TLS.Certificates[0]
+	pool := cert.Client()
+	cert.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		NextProtos:   []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// 简易HTTP/2服务端，按收到的顺序记录每个请求的头部名称
+	orders := make(chan []string, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(conn, preface); err != nil {
+			return
+		}
+		fr := http2.NewFramer(conn, conn)
+		fr.WriteSettings()
+		dec := hpack.NewDecoder(4096, nil)
+		var buf bytes.Buffer
+		enc := hpack.NewEncoder(&buf)
+		for {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch f := f.(type) {
+			case *http2.SettingsFrame:
+				if !f.IsAck() {
+					fr.WriteSettingsAck()
+				}
+			case *http2.HeadersFrame:
+				fields, err := dec.DecodeFull(f.HeaderBlockFragment())
+				if err != nil {
+					return
+				}
+				names := make([]string, len(fields))
+				for i, hf := range fields {
+					names[i] = hf.Name
+				}
+				orders <- names
+				buf.Reset()
+				enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+				fr.WriteHeaders(http2.HeadersFrameParam{StreamID: f.StreamID,
+					BlockFragment: buf.Bytes(), EndStream: true, EndHeaders: true})
+			}
+		}
+	}()
+
+	const n = 10
+	seen := map[string]bool{}
+	for i := 0; i < n; i++ {
+		resp, err := Get("https://"+ln.Addr().String(), &Opt{HttpCLi: pool, Impersonate: ProfileChrome, TimeOut: 2, NotLog: true})
+		if err != nil || resp.Proto != "HTTP/2.0" {
+			t.Fatalf("HTTP/2请求失败: %v", err)
+		}
+		names := <-orders
+		if strings.Join(names[:4], ",") != ":authority,:method,:path,:scheme" {
+			t.Fatalf("伪头部顺序错误: %v", names)
+		}
+		for _, name := range names {
+			if name != strings.ToLower(name) {
+				t.Fatalf("HTTP/2头部名称应为小写: %v", names)
+			}
+		}
+		seen[strings.Join(names[4:], ",")] = true
+	}
+	// HeaderOrder对HTTP/2不生效，普通头部顺序随请求变化
+	if len(seen) < 2 {
+		t.Fatalf("HTTP/2请求头顺序不应固定: %v", seen)
+	}
+}
+
+func TestImpersonateHTTP1HeaderOrder(t *testing.T) {
+	cert := httptest.NewTLSServer(http.NotFoundHandler())
+	certificate := cert.TLS.Certificates[0]
+	pool := cert.Client()
+	cert.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		NextProtos:   []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// 同一连接上依次读取请求，记录原始请求头名称
+	heads := make(chan []string, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			var names []string
+			var length int
+			for {
+				line, err := br.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				if line == "" {
+					break
+				}
+				if name, value, ok := strings.Cut(line, ":"); ok {
+					names = append(names, name)
+					if strings.EqualFold(name, "Content-Length") {
+						length, _ = strconv.Atoi(strings.TrimSpace(value))
+					}
+				}
+			}
+			io.CopyN(io.Discard, br, int64(length))
+			heads <- names
+			conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+		}
+	}()
+
+	url := "https://" + ln.Addr().String()
+	opt := &Opt{HttpCLi: pool, Impersonate: ProfileChrome, NotLog: true}
+	if _, err := Post(url, &Opt{HttpCLi: pool, Impersonate: ProfileChrome, NotLog: true,
+		Data: map[string]any{"a": "1"}}); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if _, err := Get(url, opt); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		names := <-heads
+		// 请求头需按配置顺序出现，并使用配置中的大小写
+		last := -1
+		for _, name := range names {
+			idx := slices.Index(ProfileChrome.HeaderOrder, name)
+			if idx < 0 {
+				t.Fatalf("请求头 %s 不在配置顺序中或大小写错误: %v", name, names)
+			}
+			if idx < last {
+				t.Fatalf("请求头顺序错误: %v", names)
+			}
+			last = idx
+		}
+		if names[0] != "Host" || !slices.Contains(names, "sec-ch-ua") {
+			t.Fatalf("请求头错误: %v", names)
+		}
+	}
+}
+
+func TestReorderHeaderChunked(t *testing.T) {
+	head := "POST / HTTP/1.1\r\nUser-Agent: x\r\nTransfer-Encoding: chunked\r\nHost: a\r\n\r\n"
+	out, n, chunked := reorderHeader([]byte(head), []string{"Host", "user-agent"})
+	if !chunked || n != 0 {
+		t.Fatalf("chunked识别错误")
+	}
+	want := "POST / HTTP/1.1\r\nHost: a\r\nuser-agent: x\r\nTransfer-Encoding: chunked\r\n\r\n"
+	if string(out) != want {
+		t.Fatalf("重排结果错误: %q", out)
+	}
+}
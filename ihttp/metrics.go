@@ -0,0 +1,421 @@
+package ihttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing 单次请求各阶段耗时，复用连接或命中缓存时对应阶段为0
+type Timing struct {
+	DNS     time.Duration // DNS解析
+	Connect time.Duration // TCP建连
+	TLS     time.Duration // TLS握手
+	TTFB    time.Duration // 请求写出到收到首字节
+	Total   time.Duration // 从发送到读完响应体(Stream模式为收到响应头)
+
+	Reused     bool   // 是否复用已有连接
+	RemoteAddr string // 实际连接的地址
+}
+
+// timingTrace 通过httptrace记录各阶段耗时
+type timingTrace struct {
+	mu     sync.Mutex
+	timing Timing
+
+	start, dnsStart, connStart, tlsStart, wroteRequest time.Time
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.timing.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) { t.mark(&t.connStart) },
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			if err == nil {
+				t.timing.Connect = time.Since(t.connStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.mu.Lock()
+			if err == nil {
+				t.timing.TLS = time.Since(t.tlsStart)
+			}
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.timing.Reused = info.Reused
+			if info.Conn != nil {
+				t.timing.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			if !t.wroteRequest.IsZero() {
+				t.timing.TTFB = time.Since(t.wroteRequest)
+			}
+			t.mu.Unlock()
+		},
+	}
+}
+
+func (t *timingTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// result 返回最终耗时
+func (t *timingTrace) result() *Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := t.timing
+	timing.Total = time.Since(t.start)
+	return &timing
+}
+
+// ---------------- 链路追踪 ----------------
+
+// Attribute 追踪属性，键名遵循OpenTelemetry HTTP语义约定
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Tracer 链路追踪钩子，可适配OpenTelemetry等实现，每次尝试(含重试)开启一个Span
+// Start可通过req.Header注入traceparent等传播头，返回的ctx用于本次请求
+type Tracer interface {
+	Start(ctx context.Context, req *http.Request, attrs []Attribute) (context.Context, Span)
+}
+
+// Span 单次请求的追踪区间
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+var (
+	tracerMu sync.RWMutex
+	tracer   Tracer
+)
+
+// SetTracer 设置全局链路追踪钩子，Opt.Tracer 未设置时使用，传nil关闭
+func SetTracer(t Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	tracer = t
+}
+
+// resolveTracer 返回本次请求使用的追踪钩子
+func resolveTracer(opt *Opt) Tracer {
+	if opt.Tracer != nil {
+		return opt.Tracer
+	}
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return tracer
+}
+
+// startSpan 开启Span并替换请求ctx，未设置追踪钩子时返回nil
+func startSpan(t Tracer, req *Request) Span {
+	if t == nil {
+		return nil
+	}
+	raw := req.Raw
+	attrs := []Attribute{
+		{"http.request.method", raw.Method},
+		{"url.full", resolveRedactor(req.Opt).URL(raw.URL.String())},
+		{"server.address", raw.URL.Hostname()},
+	}
+	if port := raw.URL.Port(); port != "" {
+		attrs = append(attrs, Attribute{"server.port", port})
+	}
+	if req.Attempt > 1 {
+		attrs = append(attrs, Attribute{"http.request.resend_count", req.Attempt - 1})
+	}
+	ctx, span := t.Start(raw.Context(), raw, attrs)
+	if ctx != nil && ctx != raw.Context() {
+		req.Raw = raw.WithContext(ctx)
+	}
+	return span
+}
+
+// endSpan 记录响应信息并结束Span
+func endSpan(span Span, response *Response, timing *Timing, err error) {
+	if span == nil {
+		return
+	}
+	if response != nil {
+		span.SetAttributes(Attribute{"http.response.status_code", response.StatusCode})
+		if response.StatusCode >= 500 {
+			span.SetAttributes(Attribute{"error.type", strconv.Itoa(response.StatusCode)})
+		}
+	}
+	if timing != nil {
+		span.SetAttributes(
+			Attribute{"ihttp.dns_ms", timing.DNS.Milliseconds()},
+			Attribute{"ihttp.connect_ms", timing.Connect.Milliseconds()},
+			Attribute{"ihttp.tls_ms", timing.TLS.Milliseconds()},
+			Attribute{"ihttp.ttfb_ms", timing.TTFB.Milliseconds()},
+			Attribute{"ihttp.connection_reused", timing.Reused},
+		)
+		if timing.RemoteAddr != "" {
+			span.SetAttributes(Attribute{"network.peer.address", timing.RemoteAddr})
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// ---------------- 指标 ----------------
+
+// DefaultBuckets 默认请求耗时直方图分桶(秒)
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics 按主机与状态码统计的请求指标，可通过 WriteTo 或作为 http.Handler 以Prometheus文本格式导出
+//
+// 导出的指标:
+//
+//	ihttp_requests_total{host,method,code}   请求次数，网络错误的code为"error"
+//	ihttp_request_duration_seconds{host}     请求总耗时直方图
+//	ihttp_request_phase_seconds{host,phase}  各阶段耗时(dns/connect/tls/ttfb)的sum与count
+type Metrics struct {
+	Namespace string    // 指标名前缀，默认 ihttp
+	Buckets   []float64 // 耗时分桶(秒)，默认 DefaultBuckets；各主机首次记录时复制当前分桶，之后修改只影响新主机或 Reset 之后
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+	phases    map[phaseKey]*summary
+}
+
+type requestKey struct{ host, method, code string }
+
+type phaseKey struct{ host, phase string }
+
+type histogram struct {
+	buckets []float64 // 创建时复制的分桶上界
+	counts  []uint64  // 与buckets对应，非累计
+	sum     float64
+	count   uint64
+}
+
+type summary struct {
+	sum   float64
+	count uint64
+}
+
+// NewMetrics 创建指标收集器
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// observe 记录一次请求
+func (m *Metrics) observe(host, method string, response *Response, timing *Timing, err error) {
+	if m == nil {
+		return
+	}
+	code := "error"
+	if response != nil {
+		code = strconv.Itoa(response.StatusCode)
+	} else if err == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == nil {
+		m.requests = map[requestKey]uint64{}
+		m.durations = map[string]*histogram{}
+		m.phases = map[phaseKey]*summary{}
+	}
+	m.requests[requestKey{host, method, code}]++
+	if timing == nil {
+		return
+	}
+
+	h := m.durations[host]
+	if h == nil {
+		buckets := append([]float64(nil), m.buckets()...)
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		m.durations[host] = h
+	}
+	seconds := timing.Total.Seconds()
+	for i, le := range h.buckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{{"dns", timing.DNS}, {"connect", timing.Connect}, {"tls", timing.TLS}, {"ttfb", timing.TTFB}} {
+		if p.d <= 0 {
+			continue
+		}
+		s := m.phases[phaseKey{host, p.name}]
+		if s == nil {
+			s = &summary{}
+			m.phases[phaseKey{host, p.name}] = s
+		}
+		s.sum += p.d.Seconds()
+		s.count++
+	}
+}
+
+// Requests 返回指定主机与状态码的请求次数，code为0时统计该主机全部请求
+func (m *Metrics) Requests(host string, code int) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n uint64
+	for k, v := range m.requests {
+		if k.host == host && (code == 0 || k.code == strconv.Itoa(code)) {
+			n += v
+		}
+	}
+	return n
+}
+
+// Reset 清空已收集的指标
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests, m.durations, m.phases = nil, nil, nil
+}
+
+// WriteTo 以Prometheus文本格式输出指标
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ns := m.Namespace
+	if ns == "" {
+		ns = "ihttp"
+	}
+	cw := &countWriter{}
+	bw := bufio.NewWriter(io.MultiWriter(w, cw))
+
+	name := ns + "_requests_total"
+	fmt.Fprintf(bw, "# HELP %s 请求次数，按主机、方法与状态码统计\n# TYPE %s counter\n", name, name)
+	reqKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Slice(reqKeys, func(i, j int) bool {
+		a, b := reqKeys[i], reqKeys[j]
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, k := range reqKeys {
+		fmt.Fprintf(bw, "%s{host=%s,method=%s,code=%s} %d\n", name,
+			promLabel(k.host), promLabel(k.method), promLabel(k.code), m.requests[k])
+	}
+
+	name = ns + "_request_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s 请求总耗时\n# TYPE %s histogram\n", name, name)
+	for _, host := range sortedKeys(m.durations) {
+		h := m.durations[host]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(bw, "%s_bucket{host=%s,le=\"%s\"} %d\n", name, promLabel(host),
+				strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket{host=%s,le=\"+Inf\"} %d\n", name, promLabel(host), h.count)
+		fmt.Fprintf(bw, "%s_sum{host=%s} %g\n", name, promLabel(host), h.sum)
+		fmt.Fprintf(bw, "%s_count{host=%s} %d\n", name, promLabel(host), h.count)
+	}
+
+	name = ns + "_request_phase_seconds"
+	fmt.Fprintf(bw, "# HELP %s 请求各阶段耗时\n# TYPE %s summary\n", name, name)
+	phaseKeys := make([]phaseKey, 0, len(m.phases))
+	for k := range m.phases {
+		phaseKeys = append(phaseKeys, k)
+	}
+	sort.Slice(phaseKeys, func(i, j int) bool {
+		if phaseKeys[i].host != phaseKeys[j].host {
+			return phaseKeys[i].host < phaseKeys[j].host
+		}
+		return phaseKeys[i].phase < phaseKeys[j].phase
+	})
+	for _, k := range phaseKeys {
+		s := m.phases[k]
+		fmt.Fprintf(bw, "%s_sum{host=%s,phase=%s} %g\n", name, promLabel(k.host), promLabel(k.phase), s.sum)
+		fmt.Fprintf(bw, "%s_count{host=%s,phase=%s} %d\n", name, promLabel(k.host), promLabel(k.phase), s.count)
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP 以Prometheus文本格式响应，可直接挂载到 /metrics
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func (m *Metrics) buckets() []float64 {
+	if len(m.Buckets) > 0 {
+		return m.Buckets
+	}
+	return DefaultBuckets
+}
+
+// promLabel 转义Prometheus标签值
+func promLabel(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   *Metrics
+)
+
+// SetMetrics 设置全局指标收集器，Opt.Metrics 未设置时使用，传nil关闭
+func SetMetrics(m *Metrics) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = m
+}
+
+// resolveMetrics 返回本次请求使用的指标收集器
+func resolveMetrics(opt *Opt) *Metrics {
+	if opt.Metrics != nil {
+		return opt.Metrics
+	}
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
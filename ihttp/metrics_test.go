@@ -0,0 +1,179 @@
+package ihttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimingAndMetrics(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	m := NewMetrics()
+	cli := NewClient(srv.URL)
+	cli.NotLog = true
+	cli.HttpCLi = srv.Client()
+	cli.Metrics = m
+
+	resp, err := cli.Get("/a", nil)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	timing := resp.Timing
+	if timing == nil || timing.Connect <= 0 || timing.TLS <= 0 || timing.TTFB < 5*time.Millisecond ||
+		timing.Total < timing.TTFB || timing.Reused || timing.RemoteAddr != host {
+		t.Fatalf("耗时记录错误: %+v", timing)
+	}
+	resp, _ = cli.Get("/b", nil)
+	if !resp.Timing.Reused || resp.Timing.TLS != 0 {
+		t.Fatalf("复用连接时不应有握手耗时: %+v", resp.Timing)
+	}
+	cli.Get("/missing", nil)
+
+	// 网络错误
+	Get("http://127.0.0.1:1/", &Opt{NotLog: true, Metrics: m})
+
+	if m.Requests(host, 0) != 3 || m.Requests(host, 200) != 2 || m.Requests(host, 404) != 1 {
+		t.Fatalf("请求计数错误: %d %d", m.Requests(host, 0), m.Requests(host, 200))
+	}
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	out := buf.String()
+	if err != nil || n != int64(len(out)) {
+		t.Fatalf("导出失败: %v %d", err, n)
+	}
+	for _, want := range []string{
+		`ihttp_requests_total{host="` + host + `",method="GET",code="200"} 2`,
+		`ihttp_requests_total{host="127.0.0.1:1",method="GET",code="error"} 1`,
+		`ihttp_request_duration_seconds_bucket{host="` + host + `",le="+Inf"} 3`,
+		`ihttp_request_duration_seconds_count{host="` + host + `"} 3`,
+		`ihttp_request_phase_seconds_count{host="` + host + `",phase="tls"} 1`,
+		`ihttp_request_phase_seconds_count{host="` + host + `",phase="ttfb"} 3`,
+		"# TYPE ihttp_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("导出内容缺少 %s:\n%s", want, out)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") || rec.Body.String() != out {
+		t.Fatal("Handler输出错误")
+	}
+}
+
+func TestMetricsBucketsChanged(t *testing.T) {
+	m := &Metrics{Buckets: []float64{0.1}}
+	m.observe("a", "GET", &Response{StatusCode: 200}, &Timing{Total: 50 * time.Millisecond}, nil)
+
+	// 修改分桶不影响已创建的直方图，新主机使用新分桶
+	m.Buckets = []float64{0.01, 0.1, 1}
+	m.observe("a", "GET", &Response{StatusCode: 200}, &Timing{Total: 500 * time.Millisecond}, nil)
+	m.observe("b", "GET", &Response{StatusCode: 200}, &Timing{Total: 50 * time.Millisecond}, nil)
+	m.Buckets = nil
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`ihttp_request_duration_seconds_bucket{host="a",le="0.1"} 1`,
+		`ihttp_request_duration_seconds_bucket{host="a",le="+Inf"} 2`,
+		`ihttp_request_duration_seconds_bucket{host="b",le="0.01"} 0`,
+		`ihttp_request_duration_seconds_bucket{host="b",le="1"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("导出内容缺少 %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `host="a",le="1"`) {
+		t.Fatalf("已创建的直方图不应使用新分桶:\n%s", out)
+	}
+}
+
+// fakeTracer 记录Span并注入traceparent请求头
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+type spanKey struct{}
+
+func (f *fakeTracer) Start(ctx context.Context, req *http.Request, attrs []Attribute) (context.Context, Span) {
+	span := &fakeSpan{attrs: map[string]any{}}
+	span.SetAttributes(attrs...)
+	f.mu.Lock()
+	f.spans = append(f.spans, span)
+	f.mu.Unlock()
+	req.Header.Set("Traceparent", "00-trace-span-01")
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *fakeSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *fakeSpan) RecordError(err error) { s.err = err }
+
+func (s *fakeSpan) End() { s.ended = true }
+
+func TestTracer(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Traceparent") == "" {
+			t.Error("未注入传播头")
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	tracer := &fakeTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	_, err := Get(srv.URL+"/x", &Opt{NotLog: true, Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+	if err != nil || len(tracer.spans) != 2 {
+		t.Fatalf("每次尝试应开启一个Span: %v %d", err, len(tracer.spans))
+	}
+	first, second := tracer.spans[0], tracer.spans[1]
+	if first.attrs["http.response.status_code"] != 502 || first.attrs["error.type"] != "502" || !first.ended {
+		t.Fatalf("首个Span错误: %+v", first)
+	}
+	if second.attrs["http.request.resend_count"] != 1 || second.attrs["http.request.method"] != "GET" ||
+		second.attrs["url.full"] != srv.URL+"/x" || second.attrs["ihttp.connection_reused"] != true {
+		t.Fatalf("重试Span错误: %+v", second.attrs)
+	}
+
+	Get("http://127.0.0.1:1/", &Opt{NotLog: true})
+	if last := tracer.spans[2]; last.err == nil || !last.ended {
+		t.Fatalf("网络错误应记录到Span: %+v", last)
+	}
+}
@@ -0,0 +1,246 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Covsj/gokit/ilog"
+)
+
+// Request 中间件链中传递的单次请求
+type Request struct {
+	Raw     *http.Request // 即将发送的HTTP请求，中间件可修改Header等
+	Opt     *Opt          // 原始请求配置
+	Attempt int           // 第几次尝试，从1开始
+	Proxy   string        // 本次使用的代理，未使用为空
+
+	LimitWait time.Duration // 本次在限流器中等待的时间
+
+	payload []byte // 完整请求体，供签名使用
+	reached bool   // 已到达中间件链末端，此后请求体由其负责关闭
+}
+
+// Handler 处理请求并返回响应
+type Handler func(req *Request) (*Response, error)
+
+// Middleware 中间件，包装下一个Handler，可在调用前后修改请求与响应
+// 每次重试都会完整经过中间件链
+type Middleware func(next Handler) Handler
+
+var (
+	middlewareMu sync.RWMutex
+	// 全局中间件，默认包含调试日志
+	globalMiddlewares = []Middleware{LogMiddleware()}
+)
+
+// Use 追加全局中间件，先注册的位于外层
+func Use(mw ...Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	globalMiddlewares = append(globalMiddlewares, mw...)
+}
+
+// SetMiddlewares 替换全部全局中间件，可用于移除默认日志
+func SetMiddlewares(mw ...Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	globalMiddlewares = append([]Middleware{}, mw...)
+}
+
+// Middlewares 返回当前全局中间件
+func Middlewares() []Middleware {
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+	return append([]Middleware{}, globalMiddlewares...)
+}
+
+// buildChain 组装中间件链：全局中间件在外层，Opt中间件在内层
+func buildChain(final Handler, optMiddlewares []Middleware) Handler {
+	all := append(Middlewares(), optMiddlewares...)
+	h := final
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i] != nil {
+			h = all[i](h)
+		}
+	}
+	return h
+}
+
+// LogMiddleware 以Debug级别记录每次请求，Opt.NotLog为true时跳过
+func LogMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			response, err := next(req)
+			if !req.Opt.NotLog {
+				logRequest(req, time.Since(start), response, err)
+			}
+			return response, err
+		}
+	}
+}
+
+// logRequest 输出单次请求日志
+func logRequest(req *Request, elapsed time.Duration, response *Response, err error) {
+	opt := req.Opt
+	r := resolveRedactor(opt)
+
+	// 准备请求体用于日志记录，敏感字段在写入日志前脱敏
+	var logBody any
+	if len(opt.Data) > 0 && len(opt.Files) > 0 {
+		logBody = map[string]any{"Data": r.Body(opt.Data), "Files": opt.Files}
+	} else if len(opt.Data) > 0 {
+		logBody = r.Body(opt.Data)
+	} else if opt.Json != nil {
+		logBody = r.Body(opt.Json)
+	} else if len(opt.Files) > 0 {
+		logBody = opt.Files
+	} else if opt.Body != nil {
+		logBody = r.Text(string(opt.Body))
+	}
+
+	args := []any{
+		"方法", opt.Method,
+		"URL", r.URL(opt.URL),
+		"请求体", logBody,
+		"请求Header", r.Header(opt.Headers),
+		"耗时", elapsed.String(),
+	}
+	if opt.Retry.attempts() > 1 {
+		args = append(args, "尝试次数", req.Attempt)
+	}
+	if req.Proxy != "" {
+		args = append(args, "代理", redactProxy(req.Proxy))
+	}
+	if req.LimitWait > 0 {
+		args = append(args, "限流等待", req.LimitWait.String())
+	}
+
+	if response != nil {
+		args = append(args, "响应码", response.StatusCode)
+		if opt.Protocol != ProtocolAuto {
+			args = append(args, "协议", response.Proto)
+		}
+		if n := len(response.RedirectChain); n > 0 {
+			args = append(args, "重定向次数", n, "最终URL", r.URL(response.URL))
+		}
+		if response.CacheStatus != "" {
+			args = append(args, "缓存", string(response.CacheStatus))
+		}
+		if t := response.Timing; t != nil && t.TTFB > 0 {
+			args = append(args, "首字节耗时", t.TTFB.String())
+		}
+
+		// 仅在错误或非2xx时记录响应体
+		if err != nil || response.StatusCode >= 400 {
+			args = append(args, "响应体", truncateText(r.Text(response.Text), 1024))
+		}
+	} else {
+		args = append(args, "响应码", 0)
+	}
+
+	if err != nil {
+		args = append(args, "错误信息", r.Error(err))
+	}
+
+	ilog.Debug("调用请求", args...)
+}
+
+// RequestIDMiddleware 为请求添加唯一ID请求头，已存在时保留原值
+// header为空时使用 X-Request-ID
+func RequestIDMiddleware(header string) Middleware {
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.Raw.Header.Get(header) == "" {
+				req.Raw.Header.Set(header, newRequestID())
+			}
+			return next(req)
+		}
+	}
+}
+
+// newRequestID 生成随机请求ID(UUID v4格式)
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// TokenSource 获取访问令牌，refresh为true表示当前令牌已失效需要强制刷新
+type TokenSource func(ctx context.Context, refresh bool) (string, error)
+
+// BearerTokenMiddleware 自动添加 Authorization: Bearer 请求头
+// 令牌在首次使用时获取并缓存，响应401时刷新令牌并重发一次请求
+func BearerTokenMiddleware(source TokenSource) Middleware {
+	auth := &bearerAuth{source: source}
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			ctx := req.Raw.Context()
+			token, err := auth.get(ctx, "")
+			if err != nil {
+				return nil, err
+			}
+			req.Raw.Header.Set("Authorization", "Bearer "+token)
+
+			response, err := next(req)
+			if err != nil || response == nil || response.StatusCode != http.StatusUnauthorized {
+				return response, err
+			}
+
+			// 请求体无法重建时不重发
+			if req.Raw.Body != nil && req.Raw.GetBody == nil {
+				return response, err
+			}
+			newToken, err := auth.get(ctx, token)
+			if err != nil {
+				return response, nil
+			}
+
+			retry := req.Raw.Clone(ctx)
+			if req.Raw.GetBody != nil {
+				if retry.Body, err = req.Raw.GetBody(); err != nil {
+					return response, nil
+				}
+			}
+			retry.Header.Set("Authorization", "Bearer "+newToken)
+			response.Close()
+
+			retryReq := *req
+			retryReq.Raw = retry
+			return next(&retryReq)
+		}
+	}
+}
+
+// bearerAuth 缓存令牌，并发刷新时只刷新一次
+type bearerAuth struct {
+	mu     sync.Mutex
+	token  string
+	source TokenSource
+}
+
+// get 获取令牌，stale非空表示该令牌已失效，若缓存仍为该令牌则刷新
+func (b *bearerAuth) get(ctx context.Context, stale string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.token != "" && b.token != stale {
+		return b.token, nil
+	}
+	token, err := b.source(ctx, stale != "")
+	if err != nil {
+		return "", err
+	}
+	b.token = token
+	return token, nil
+}
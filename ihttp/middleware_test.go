@@ -0,0 +1,92 @@
+package ihttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareOrderAndRewrite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				req.Raw.Header.Add("X-Trace", name)
+				resp, err := next(req)
+				if resp != nil {
+					resp.Text += "<" + name
+				}
+				return resp, err
+			}
+		}
+	}
+
+	old := Middlewares()
+	defer SetMiddlewares(old...)
+	Use(tag("global"))
+
+	resp, err := Get(srv.URL, &Opt{NotLog: true, Middlewares: []Middleware{tag("opt")}})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.Text != "global<opt<global" {
+		t.Fatalf("中间件顺序错误: %s", resp.Text)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Request-ID")))
+	}))
+	defer srv.Close()
+
+	resp, err := Get(srv.URL, &Opt{NotLog: true, Middlewares: []Middleware{RequestIDMiddleware("")}})
+	if err != nil || len(resp.Text) != 36 {
+		t.Fatalf("未生成请求ID: %v %s", err, resp.Text)
+	}
+
+	resp, _ = Get(srv.URL, &Opt{NotLog: true, Headers: map[string]string{"X-Request-ID": "fixed"},
+		Middlewares: []Middleware{RequestIDMiddleware("")}})
+	if resp.Text != "fixed" {
+		t.Fatalf("已有请求ID不应覆盖: %s", resp.Text)
+	}
+}
+
+func TestBearerTokenRefresh(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	fetches := []bool{}
+	source := func(ctx context.Context, refresh bool) (string, error) {
+		fetches = append(fetches, refresh)
+		if refresh {
+			return "fresh", nil
+		}
+		return "stale", nil
+	}
+	mw := BearerTokenMiddleware(source)
+
+	resp, err := Post(srv.URL, &Opt{NotLog: true, Json: map[string]int{"a": 1}, Middlewares: []Middleware{mw}})
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Text != `{"a":1}` {
+		t.Fatalf("刷新令牌后应重发成功: %v %+v", err, resp)
+	}
+
+	// 刷新后的令牌应被缓存
+	resp, _ = Get(srv.URL, &Opt{NotLog: true, Middlewares: []Middleware{mw}})
+	if resp.StatusCode != http.StatusOK || len(fetches) != 2 || fetches[0] || !fetches[1] {
+		t.Fatalf("令牌缓存错误: %v", fetches)
+	}
+}
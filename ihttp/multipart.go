@@ -0,0 +1,299 @@
+package ihttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// multipartBody 流式multipart请求体，文件内容通过io.Pipe边读边发
+type multipartBody struct {
+	boundary string
+	fields   []formField
+	files    []*filePart
+	size     int64 // 总长度，存在未知大小的文件时为-1
+
+	mu     sync.Mutex
+	opened int
+}
+
+type formField struct {
+	name  string
+	value string
+}
+
+type filePart struct {
+	field       string
+	fileName    string
+	contentType string
+	path        string
+	reader      io.Reader
+	offset      int64 // Reader实现io.Seeker时的起始位置
+	size        int64 // 未知为-1
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// newMultipartBody 预处理表单字段与文件，计算Content-Type与总长度
+func newMultipartBody(data map[string]any, files map[string]File) (*multipartBody, error) {
+	b := &multipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+
+	// 排序保证每次生成的请求体一致
+	for _, name := range sortedKeys(data) {
+		b.fields = append(b.fields, formField{name: name, value: fmt.Sprintf("%v", data[name])})
+	}
+
+	for _, field := range sortedKeys(files) {
+		part, err := newFilePart(field, files[field])
+		if err != nil {
+			return nil, err
+		}
+		if part != nil {
+			b.files = append(b.files, part)
+		}
+	}
+
+	b.size = b.computeSize()
+	return b, nil
+}
+
+// newFilePart 解析文件信息，未指定ContentType时按扩展名或内容探测
+func newFilePart(field string, file File) (*filePart, error) {
+	part := &filePart{field: field, fileName: file.FileName, contentType: file.ContentType, size: -1}
+
+	var sniff []byte
+	switch {
+	case file.Reader != nil:
+		part.reader = file.Reader
+		if seeker, ok := file.Reader.(io.Seeker); ok {
+			cur, err := seeker.Seek(0, io.SeekCurrent)
+			if err == nil {
+				if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
+					part.size = end - cur
+				}
+				if _, err := seeker.Seek(cur, io.SeekStart); err != nil {
+					return nil, fmt.Errorf("文件定位失败: %v", err)
+				}
+				part.offset = cur
+			}
+		}
+		if part.contentType == "" && part.typeByName() == "" {
+			if seeker, ok := file.Reader.(io.Seeker); ok && part.size >= 0 {
+				buf := make([]byte, 512)
+				n, _ := io.ReadFull(file.Reader, buf)
+				sniff = buf[:n]
+				if _, err := seeker.Seek(part.offset, io.SeekStart); err != nil {
+					return nil, fmt.Errorf("文件定位失败: %v", err)
+				}
+			} else {
+				// 不可定位的Reader通过缓冲预读探测类型
+				br := bufio.NewReaderSize(file.Reader, 512)
+				sniff, _ = br.Peek(512)
+				part.reader = br
+			}
+		}
+	case file.Path != "":
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("打开文件失败: %v", err)
+		}
+		part.path = file.Path
+		part.size = info.Size()
+		if part.fileName == "" {
+			part.fileName = filepath.Base(file.Path)
+		}
+		if part.contentType == "" && part.typeByName() == "" {
+			f, err := os.Open(file.Path)
+			if err != nil {
+				return nil, fmt.Errorf("打开文件失败: %v", err)
+			}
+			buf := make([]byte, 512)
+			n, _ := io.ReadFull(f, buf)
+			f.Close()
+			sniff = buf[:n]
+		}
+	default:
+		return nil, nil
+	}
+
+	if part.contentType == "" {
+		part.contentType = part.typeByName()
+	}
+	if part.contentType == "" {
+		if len(sniff) > 0 {
+			part.contentType = http.DetectContentType(sniff)
+		} else {
+			part.contentType = "application/octet-stream"
+		}
+	}
+	return part, nil
+}
+
+// typeByName 按文件扩展名推断MIME类型
+func (p *filePart) typeByName() string {
+	return mime.TypeByExtension(filepath.Ext(p.fileName))
+}
+
+func (p *filePart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(p.field), quoteEscaper.Replace(p.fileName)))
+	h.Set("Content-Type", p.contentType)
+	return h
+}
+
+func (b *multipartBody) contentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// computeSize 计算请求体总长度，存在未知大小文件时返回-1
+func (b *multipartBody) computeSize() int64 {
+	cw := &countWriter{}
+	mw := multipart.NewWriter(cw)
+	mw.SetBoundary(b.boundary)
+	for _, f := range b.fields {
+		mw.WriteField(f.name, f.value)
+	}
+	for _, p := range b.files {
+		if p.size < 0 {
+			return -1
+		}
+		mw.CreatePart(p.header())
+		cw.n += p.size
+	}
+	mw.Close()
+	return cw.n
+}
+
+// open 生成新的请求体读取器，重试时重新打开文件或回到Reader起始位置
+func (b *multipartBody) open(ctx context.Context) (io.ReadCloser, error) {
+	b.mu.Lock()
+	reopen := b.opened > 0
+	b.opened++
+	b.mu.Unlock()
+
+	if reopen {
+		for _, p := range b.files {
+			if p.reader == nil {
+				continue
+			}
+			seeker, ok := p.reader.(io.Seeker)
+			if !ok {
+				return nil, errors.New("文件Reader不支持重复读取，无法重发请求")
+			}
+			if _, err := seeker.Seek(p.offset, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("文件定位失败: %v", err)
+			}
+		}
+	}
+
+	pr, pw := io.Pipe()
+	// 请求被放弃且无人读取或关闭管道时，ctx结束即中断写入，避免协程永久阻塞
+	stop := context.AfterFunc(ctx, func() { pr.CloseWithError(ctx.Err()) })
+	go func() {
+		defer stop()
+		pw.CloseWithError(b.write(ctx, pw))
+	}()
+	return pr, nil
+}
+
+// bytes 将完整请求体读入内存
+func (b *multipartBody) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.write(context.Background(), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write 将表单字段与文件依次写入管道
+func (b *multipartBody) write(ctx context.Context, w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	for _, f := range b.fields {
+		if err := mw.WriteField(f.name, f.value); err != nil {
+			return fmt.Errorf("写入表单字段失败: %w", err)
+		}
+	}
+
+	for _, p := range b.files {
+		if err := b.writeFile(ctx, mw, p); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (b *multipartBody) writeFile(ctx context.Context, mw *multipart.Writer, p *filePart) error {
+	reader := p.reader
+	if p.path != "" {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return fmt.Errorf("打开文件失败: %v", err)
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	part, err := mw.CreatePart(p.header())
+	if err != nil {
+		return fmt.Errorf("创建文件字段失败: %w", err)
+	}
+	if _, err := io.Copy(part, &ctxReader{ctx: ctx, r: reader}); err != nil {
+		return fmt.Errorf("复制文件内容失败: %w", err)
+	}
+	return nil
+}
+
+// countWriter 只统计写入字节数
+type countWriter struct {
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// progressReader 读取时回调进度
+type progressReader struct {
+	io.ReadCloser
+	current int64
+	total   int64
+	fn      ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.current += int64(n)
+		p.fn(p.current, p.total)
+	}
+	return n, err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
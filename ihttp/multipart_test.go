@@ -0,0 +1,196 @@
+package ihttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// multipartEcho 解析multipart请求并返回 字段=值/文件名:类型:长度
+func multipartEcho(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			t.Errorf("非multipart请求: %v", err)
+			return
+		}
+		res := []string{}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("读取part失败: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(part)
+			if part.FileName() == "" {
+				res = append(res, part.FormName()+"="+string(data))
+			} else {
+				res = append(res, part.FormName()+"/"+part.FileName()+":"+
+					part.Header.Get("Content-Type")+":"+strconv.Itoa(len(data)))
+			}
+		}
+		if r.ContentLength > 0 {
+			res = append(res, "len")
+		}
+		if n == 1 && r.Header.Get("X-Fail-First") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(strings.Join(res, ",")))
+	}))
+}
+
+func TestMultipartDataAndFiles(t *testing.T) {
+	var calls int32
+	srv := multipartEcho(t, &calls)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "a.json")
+	os.WriteFile(path, []byte(`{"a":1}`), 0o644)
+
+	var progress int64
+	resp, err := Post(srv.URL, &Opt{
+		NotLog: true,
+		Data:   map[string]any{"name": "gokit", "n": 1},
+		Files: map[string]File{
+			"doc": {Path: path},
+			"img": {Reader: bytes.NewReader([]byte("\x89PNG\r\n\x1a\nxx")), FileName: "blob"},
+			"raw": {Reader: strings.NewReader("abc"), FileName: "x.bin", ContentType: "application/x-custom"},
+		},
+		UploadProgress: func(current, total int64) { progress = current },
+	})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	want := "n=1,name=gokit,doc/a.json:application/json:7,img/blob:image/png:10,raw/x.bin:application/x-custom:3,len"
+	if resp.Text != want {
+		t.Fatalf("期望 %s\n实际 %s", want, resp.Text)
+	}
+	if progress == 0 {
+		t.Fatal("未回调上传进度")
+	}
+}
+
+func TestMultipartRetryRewind(t *testing.T) {
+	var calls int32
+	srv := multipartEcho(t, &calls)
+	defer srv.Close()
+
+	resp, err := Post(srv.URL, &Opt{
+		NotLog:  true,
+		Headers: map[string]string{"X-Fail-First": "1"},
+		Files:   map[string]File{"f": {Reader: strings.NewReader("hello"), FileName: "h.txt"}},
+		Retry:   &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("重试失败: %v, 次数%d", err, calls)
+	}
+	if resp.Text != "f/h.txt:text/plain; charset=utf-8:5,len" {
+		t.Fatalf("重试请求体错误: %s", resp.Text)
+	}
+}
+
+func TestMultipartNonSeekableStreams(t *testing.T) {
+	var calls int32
+	srv := multipartEcho(t, &calls)
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("streamed"))
+		pw.Close()
+	}()
+	resp, err := Post(srv.URL, &Opt{NotLog: true, Files: map[string]File{"s": {Reader: pr, FileName: "s"}}})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	if resp.Text != "s/s:text/plain; charset=utf-8:8" {
+		t.Fatalf("未知长度应使用chunked: %s", resp.Text)
+	}
+}
+
+func TestMultipartEarlyReturnNoLeak(t *testing.T) {
+	var calls int32
+	srv := multipartEcho(t, &calls)
+	defer srv.Close()
+
+	limiter := NewRateLimiter(Limit{Rate: 0.001})
+	limiter.FailFast = true
+	shortCircuit := func(next Handler) Handler {
+		return func(req *Request) (*Response, error) { return &Response{StatusCode: 204}, nil }
+	}
+	upload := func(opt *Opt) (*Response, error) {
+		opt.NotLog = true
+		opt.Files = map[string]File{"f": {Reader: strings.NewReader("hello"), FileName: "h.txt"}}
+		return Post(srv.URL, opt)
+	}
+	if _, err := upload(&Opt{RateLimiter: limiter}); err != nil {
+		t.Fatalf("首次上传失败: %v", err)
+	}
+
+	base := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		if _, err := upload(&Opt{RateLimiter: limiter}); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("期望ErrRateLimited, 实际: %v", err)
+		}
+		if resp, err := upload(&Opt{Middlewares: []Middleware{shortCircuit}}); err != nil || resp.StatusCode != 204 {
+			t.Fatalf("中间件短路失败: %v", err)
+		}
+	}
+	// 未发送的请求体应被关闭，multipart写入协程随之退出
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base+2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base+2 {
+		t.Fatalf("协程泄漏: %d -> %d", base, n)
+	}
+}
+
+func TestMultipartAbandonedBody(t *testing.T) {
+	b, err := newMultipartBody(nil, map[string]File{"f": {Reader: strings.NewReader("hello"), FileName: "h.txt"}})
+	if err != nil {
+		t.Fatalf("创建请求体失败: %v", err)
+	}
+	base := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := b.open(ctx)
+	if err != nil {
+		t.Fatalf("打开请求体失败: %v", err)
+	}
+
+	// 请求被放弃且请求体未被读取或关闭时，ctx结束后写入协程应退出
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base {
+		t.Fatalf("协程泄漏: %d -> %d", base, n)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("请求体应已关闭, 实际: %v", err)
+	}
+}
+
+func TestJsonWithFilesRejected(t *testing.T) {
+	_, err := Post("http://127.0.0.1", &Opt{Json: map[string]any{}, Files: map[string]File{"f": {Path: "x"}}})
+	if err == nil {
+		t.Fatal("Json与Files同时使用应报错")
+	}
+}
@@ -0,0 +1,211 @@
+package ihttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	xmlDeclRe     = regexp.MustCompile(`^\s*<\?xml[^>]*encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+	metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset`)
+)
+
+// content 返回完整响应体，Stream模式下读取全部剩余内容后关闭响应流
+func (r *Response) content() ([]byte, error) {
+	if r == nil {
+		return nil, errors.New("空响应")
+	}
+	if r.Body == nil && r.Reader != nil {
+		data, err := io.ReadAll(r.Reader)
+		r.Close()
+		r.Reader = nil
+		if err != nil {
+			return nil, fmt.Errorf("读取响应体失败: %w", err)
+		}
+		r.Body = data
+		r.Text = string(data)
+	}
+	return r.Body, nil
+}
+
+// Charset 返回响应体字符集名称
+// 依次根据BOM、Content-Type、XML声明、HTML meta判断，均未声明时合法UTF-8视为utf-8，否则视为gb18030
+func (r *Response) Charset() string {
+	body, err := r.content()
+	if err != nil {
+		return "utf-8"
+	}
+	head := body
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+
+	contentType := http.Header(r.Headers).Get("Content-Type")
+	_, name, certain := charset.DetermineEncoding(head, contentType)
+	if certain {
+		return name
+	}
+	if m := xmlDeclRe.FindSubmatch(head); m != nil {
+		if _, xmlName := charset.Lookup(string(m[1])); xmlName != "" {
+			return xmlName
+		}
+	}
+	// DetermineEncoding在未找到声明时默认windows-1252，此处改为按内容判断
+	if name != "windows-1252" || metaCharsetRe.Match(head) {
+		return name
+	}
+	if utf8.Valid(body) {
+		return "utf-8"
+	}
+	return "gb18030"
+}
+
+// UTF8 返回转换为UTF-8编码的响应体
+func (r *Response) UTF8() ([]byte, error) {
+	body, err := r.content()
+	if err != nil {
+		return nil, err
+	}
+	name := r.Charset()
+	if name == "utf-8" {
+		return body, nil
+	}
+	enc, _ := charset.Lookup(name)
+	if enc == nil {
+		return nil, fmt.Errorf("不支持的字符集: %s", name)
+	}
+	data, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("字符集转换失败(%s): %v", name, err)
+	}
+	return data, nil
+}
+
+// UTF8Text 返回转换为UTF-8编码的响应文本
+func (r *Response) UTF8Text() (string, error) {
+	data, err := r.UTF8()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// JSON 将响应体按JSON解码到v
+func (r *Response) JSON(v any) error {
+	body, err := r.content()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("JSON解析失败: %w", err)
+	}
+	return nil
+}
+
+// XML 将响应体按XML解码到v，支持XML声明或Content-Type中的非UTF-8编码
+func (r *Response) XML(v any) error {
+	body, err := r.content()
+	if err != nil {
+		return err
+	}
+	// 有编码声明时交给解码器按声明转换，否则先按检测到的字符集转为UTF-8
+	if !xmlDeclRe.Match(body) {
+		if body, err = r.UTF8(); err != nil {
+			return err
+		}
+	}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("XML解析失败: %w", err)
+	}
+	return nil
+}
+
+// HTML 将响应体(转换为UTF-8后)解析为HTML节点树，结果会被缓存
+func (r *Response) HTML() (*html.Node, error) {
+	if r != nil && r.root != nil {
+		return r.root, nil
+	}
+	body, err := r.UTF8()
+	if err != nil {
+		return nil, err
+	}
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("HTML解析失败: %w", err)
+	}
+	r.root = root
+	return root, nil
+}
+
+// Document 返回可使用CSS选择器查询的文档
+func (r *Response) Document() (*goquery.Document, error) {
+	root, err := r.HTML()
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromNode(root), nil
+}
+
+// Find 使用CSS选择器查询HTML
+//
+// 使用示例:
+//
+//	sel, err := resp.Find("ul.list > li a")
+//	sel.Each(func(i int, s *goquery.Selection) { href, _ := s.Attr("href") })
+func (r *Response) Find(selector string) (*goquery.Selection, error) {
+	doc, err := r.Document()
+	if err != nil {
+		return nil, err
+	}
+	return doc.Find(selector), nil
+}
+
+// XPath 使用XPath表达式查询HTML节点
+func (r *Response) XPath(expr string) ([]*html.Node, error) {
+	root, err := r.HTML()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := htmlquery.QueryAll(root, expr)
+	if err != nil {
+		return nil, fmt.Errorf("XPath表达式错误: %w", err)
+	}
+	return nodes, nil
+}
+
+// XPathOne 返回XPath匹配的第一个节点，未匹配返回nil
+func (r *Response) XPathOne(expr string) (*html.Node, error) {
+	nodes, err := r.XPath(expr)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// XPathText 返回XPath匹配节点的文本，属性节点返回属性值
+func (r *Response) XPathText(expr string) ([]string, error) {
+	nodes, err := r.XPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		texts = append(texts, strings.TrimSpace(htmlquery.InnerText(n)))
+	}
+	return texts, nil
+}
@@ -0,0 +1,182 @@
+package ihttp
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func compressBody(t *testing.T, enc string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "deflate-raw":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = zw
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	plain := bytes.Repeat([]byte("你好, gokit! "), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := r.URL.Query().Get("enc")
+		header := enc
+		if enc == "deflate-raw" {
+			header = "deflate"
+		}
+		w.Header().Set("Content-Encoding", header)
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(compressBody(t, enc, plain))
+	}))
+	defer srv.Close()
+
+	for _, enc := range []string{"gzip", "deflate", "deflate-raw", "br", "zstd"} {
+		opt := &Opt{NotLog: true, Headers: map[string]string{"Accept-Encoding": AcceptEncoding}}
+		resp, err := Get(srv.URL+"?enc="+enc, opt)
+		if err != nil {
+			t.Fatalf("%s 请求失败: %v", enc, err)
+		}
+		if !bytes.Equal(resp.Body, plain) {
+			t.Fatalf("%s 解压结果错误: %q", enc, resp.Text[:20])
+		}
+		if http.Header(resp.Headers).Get("Content-Encoding") != "" {
+			t.Fatalf("%s 解压后应移除Content-Encoding", enc)
+		}
+
+		// 流式读取
+		resp, err = Get(srv.URL+"?enc="+enc, &Opt{NotLog: true, Stream: true,
+			Headers: map[string]string{"Accept-Encoding": AcceptEncoding}})
+		if err != nil {
+			t.Fatalf("%s 请求失败: %v", enc, err)
+		}
+		data, err := io.ReadAll(resp.Reader)
+		resp.Close()
+		if err != nil || !bytes.Equal(data, plain) {
+			t.Fatalf("%s 流式解压失败: %v", enc, err)
+		}
+	}
+
+	// HEAD响应无响应体不应报错
+	if _, err := Head(srv.URL+"?enc=gzip", &Opt{NotLog: true}); err != nil {
+		t.Fatalf("HEAD请求失败: %v", err)
+	}
+}
+
+func gbk(s string) []byte {
+	b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+	return b
+}
+
+func TestCharset(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		charset     string
+	}{
+		{"header", "text/html; charset=GBK", gbk("<p>中文</p>"), "gbk"},
+		{"meta", "text/html", gbk(`<html><head><meta charset="gb2312"></head><body>中文</body></html>`), "gbk"},
+		{"undeclared", "text/html", gbk("<p>中文内容</p>"), "gb18030"},
+		{"utf8", "text/plain", []byte("中文"), "utf-8"},
+	}
+	for _, c := range cases {
+		resp := &Response{Headers: http.Header{"Content-Type": {c.contentType}}, Body: c.body, Text: string(c.body)}
+		if got := resp.Charset(); got != c.charset {
+			t.Errorf("%s 字符集检测错误: %s", c.name, got)
+		}
+		text, err := resp.UTF8Text()
+		if err != nil || !bytes.Contains([]byte(text), []byte("中文")) {
+			t.Errorf("%s 转换失败: %q %v", c.name, text, err)
+		}
+	}
+}
+
+func TestResponseXML(t *testing.T) {
+	type item struct {
+		Title string `xml:"title"`
+	}
+	type feed struct {
+		Items []item `xml:"item"`
+	}
+
+	body := append([]byte(`<?xml version="1.0" encoding="GBK"?><rss>`), gbk("<item><title>标题一</title></item><item><title>标题二</title></item></rss>")...)
+	resp := &Response{Headers: http.Header{"Content-Type": {"application/xml"}}, Body: body}
+	var f feed
+	if err := resp.XML(&f); err != nil {
+		t.Fatalf("XML解析失败: %v", err)
+	}
+	if len(f.Items) != 2 || f.Items[1].Title != "标题二" {
+		t.Fatalf("XML解析结果错误: %+v", f)
+	}
+
+	// 编码由Content-Type声明
+	resp = &Response{Headers: http.Header{"Content-Type": {"text/xml; charset=gbk"}},
+		Body: gbk("<rss><item><title>标题</title></item></rss>")}
+	f = feed{}
+	if err := resp.XML(&f); err != nil || f.Items[0].Title != "标题" {
+		t.Fatalf("XML解析失败: %+v %v", f, err)
+	}
+}
+
+func TestResponseHTMLQuery(t *testing.T) {
+	page := `<html><head><meta http-equiv="Content-Type" content="text/html; charset=gbk"></head><body>
+<ul class="list"><li><a href="/a">第一</a></li><li><a href="/b">第二</a></li></ul></body></html>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(gbk(page))
+	}))
+	defer srv.Close()
+
+	resp, err := Get(srv.URL, &Opt{NotLog: true, Stream: true})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+
+	sel, err := resp.Find("ul.list li a")
+	if err != nil || sel.Length() != 2 || sel.First().Text() != "第一" {
+		t.Fatalf("CSS查询失败: %v", err)
+	}
+	if href, _ := sel.Last().Attr("href"); href != "/b" {
+		t.Fatalf("属性读取错误: %s", href)
+	}
+
+	texts, err := resp.XPathText("//ul[@class='list']/li/a")
+	if err != nil || len(texts) != 2 || texts[1] != "第二" {
+		t.Fatalf("XPath查询失败: %v %v", texts, err)
+	}
+	hrefs, err := resp.XPathText("//a/@href")
+	if err != nil || len(hrefs) != 2 || hrefs[0] != "/a" {
+		t.Fatalf("XPath属性查询失败: %v %v", hrefs, err)
+	}
+	if n, err := resp.XPathOne("//p"); err != nil || n != nil {
+		t.Fatalf("未匹配应返回nil: %v", err)
+	}
+	if _, err := resp.XPath("//a["); err == nil {
+		t.Fatalf("非法表达式应报错")
+	}
+}
@@ -0,0 +1,102 @@
+package ihttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Protocol HTTP协议版本，实际使用的协议见 Response.Proto
+type Protocol string
+
+const (
+	ProtocolAuto  Protocol = ""         // 由Transport协商，https优先HTTP/2
+	ProtocolHTTP1 Protocol = "http/1.1" // 强制HTTP/1.1，不进行HTTP/2协商
+	ProtocolHTTP2 Protocol = "h2"       // 强制HTTP/2 over TLS，服务端不支持时请求失败
+	ProtocolH2C   Protocol = "h2c"      // 明文HTTP/2(prior knowledge)，用于本地或内网服务
+	ProtocolHTTP3 Protocol = "h3"       // HTTP/3 over QUIC，不支持代理
+)
+
+// protocolTransports 缓存按协议派生的Transport
+var protocolTransports = newTransportCache(maxCachedTransports)
+
+type protocolTransportKey struct {
+	base     http.RoundTripper
+	protocol Protocol
+}
+
+// clientWithProtocol 基于原客户端派生出使用指定协议的客户端，不修改原客户端
+func clientWithProtocol(base *http.Client, protocol Protocol) (*http.Client, error) {
+	if protocol == ProtocolAuto {
+		return base, nil
+	}
+
+	baseTransport := base.Transport
+	if baseTransport == nil {
+		baseTransport = http.DefaultTransport
+	}
+	t, ok := baseTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("自定义Transport不支持指定协议")
+	}
+
+	key := protocolTransportKey{base: t, protocol: protocol}
+	tr, err := protocolTransports.get(key, func() (http.RoundTripper, error) {
+		switch protocol {
+		case ProtocolHTTP3:
+			h3 := &http3.Transport{DisableCompression: t.DisableCompression}
+			if t.TLSClientConfig != nil {
+				h3.TLSClientConfig = t.TLSClientConfig.Clone()
+			}
+			return h3, nil
+		case ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C:
+			clone := t.Clone()
+			clone.Protocols = new(http.Protocols)
+			switch protocol {
+			case ProtocolHTTP1:
+				clone.Protocols.SetHTTP1(true)
+				if clone.TLSClientConfig != nil {
+					// 原配置的ALPN可能包含h2，需同步去掉
+					clone.TLSClientConfig.NextProtos = []string{"http/1.1"}
+				}
+			case ProtocolHTTP2:
+				clone.Protocols.SetHTTP2(true)
+			case ProtocolH2C:
+				clone.Protocols.SetUnencryptedHTTP2(true)
+			}
+			return clone, nil
+		default:
+			return nil, fmt.Errorf("不支持的协议: %s", protocol)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := *base
+	c.Transport = tr
+	return &c, nil
+}
+
+// transportProxy 返回客户端Transport的Proxy函数对目标地址生效的代理，未配置时返回空
+func transportProxy(c *http.Client, method, rawURL string) (string, error) {
+	t, ok := c.Transport.(*http.Transport)
+	if c.Transport == nil {
+		t, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok || t.Proxy == nil {
+		return "", nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	p, err := t.Proxy(&http.Request{Method: method, URL: u, Header: http.Header{}})
+	if err != nil || p == nil {
+		return "", err
+	}
+	return p.String(), nil
+}
@@ -0,0 +1,133 @@
+package ihttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// protoHandler 返回服务端看到的协议版本
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, r.Proto)
+})
+
+func TestProtocolTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(protoHandler)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		protocol Protocol
+		want     string
+	}{
+		{ProtocolAuto, "HTTP/2.0"},
+		{ProtocolHTTP1, "HTTP/1.1"},
+		{ProtocolHTTP2, "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		resp, err := Get(srv.URL, &Opt{HttpCLi: srv.Client(), Protocol: tt.protocol, NotLog: true})
+		if err != nil {
+			t.Fatalf("%q 请求失败: %v", tt.protocol, err)
+		}
+		if resp.Proto != tt.want || resp.Text != tt.want {
+			t.Fatalf("%q 协议错误: 客户端 %s 服务端 %s", tt.protocol, resp.Proto, resp.Text)
+		}
+	}
+
+	// 仅支持HTTP/1.1的服务端不能强制HTTP/2
+	h1 := httptest.NewTLSServer(protoHandler)
+	defer h1.Close()
+	if _, err := Get(h1.URL, &Opt{HttpCLi: h1.Client(), Protocol: ProtocolHTTP2, NotLog: true}); err == nil {
+		t.Fatal("服务端不支持HTTP/2时应失败")
+	}
+}
+
+func TestProtocolH2C(t *testing.T) {
+	srv := httptest.NewUnstartedServer(protoHandler)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	cli := NewClient(srv.URL)
+	cli.NotLog = true
+	cli.Protocol = ProtocolH2C
+	resp, err := cli.Get("/", nil)
+	if err != nil || resp.Proto != "HTTP/2.0" || resp.Text != "HTTP/2.0" {
+		t.Fatalf("h2c请求错误: %v %+v", err, resp)
+	}
+	// 单次请求覆盖会话配置
+	resp, err = cli.Get("/", &Opt{Protocol: ProtocolHTTP1})
+	if err != nil || resp.Proto != "HTTP/1.1" || resp.Text != "HTTP/1.1" {
+		t.Fatalf("覆盖协议错误: %v %+v", err, resp)
+	}
+}
+
+func TestProtocolHTTP3(t *testing.T) {
+	// 借用httptest的证书，客户端通过 srv.Client() 信任该证书
+	tlsSrv := httptest.NewTLSServer(protoHandler)
+	defer tlsSrv.Close()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听UDP: %v", err)
+	}
+	h3 := &http3.Server{Handler: protoHandler, TLSConfig: http3.ConfigureTLSConfig(tlsSrv.TLS.Clone())}
+	go h3.Serve(conn)
+	defer h3.Close()
+
+	url := "https://" + conn.LocalAddr().String() + "/"
+	resp, err := Get(url, &Opt{HttpCLi: tlsSrv.Client(), Protocol: ProtocolHTTP3, NotLog: true})
+	if err != nil {
+		t.Fatalf("HTTP/3请求失败: %v", err)
+	}
+	if resp.Proto != "HTTP/3.0" || resp.Text != "HTTP/3.0" {
+		t.Fatalf("HTTP/3协议错误: %s %s", resp.Proto, resp.Text)
+	}
+
+	// 任何来源的代理生效时都应报错，而不是绕过代理直连
+	assertNoProxy := func(name string, opt *Opt) {
+		t.Helper()
+		opt.Protocol, opt.NotLog = ProtocolHTTP3, true
+		if _, err := Get(url, opt); err == nil || err.Error() != "HTTP/3不支持代理" {
+			t.Fatalf("%s: 期望HTTP/3不支持代理, 实际: %v", name, err)
+		}
+	}
+	assertNoProxy("Opt.Proxy", &Opt{HttpCLi: tlsSrv.Client(), Proxy: "http://127.0.0.1:1"})
+
+	custom := tlsSrv.Client()
+	tr := custom.Transport.(*http.Transport).Clone()
+	tr.Proxy = http.ProxyURL(&neturl.URL{Scheme: "http", Host: "127.0.0.1:1"})
+	custom.Transport = tr
+	assertNoProxy("Transport.Proxy", &Opt{HttpCLi: custom})
+
+	for _, k := range []string{"NO_PROXY", "no_proxy"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+	assertNoProxy("HTTPS_PROXY", &Opt{HttpCLi: tlsSrv.Client()})
+}
+
+func TestProtocolInvalid(t *testing.T) {
+	if _, err := Get("http://127.0.0.1:1", &Opt{Protocol: "spdy", NotLog: true}); err == nil {
+		t.Fatal("未知协议应失败")
+	}
+	srv := httptest.NewServer(protoHandler)
+	defer srv.Close()
+	if _, err := Get(srv.URL, &Opt{Protocol: ProtocolHTTP1, Impersonate: ProfileChrome, NotLog: true}); err == nil ||
+		err.Error() != "Impersonate不能与Protocol同时使用" {
+		t.Fatalf("Impersonate与Protocol不能同时使用: %v", err)
+	}
+	// 会话级Impersonate与单次请求的Protocol同样冲突
+	cli := &Client{Impersonate: ProfileChrome, NotLog: true}
+	if _, err := cli.Get(srv.URL, &Opt{Protocol: ProtocolHTTP1}); err == nil {
+		t.Fatal("会话Impersonate与Opt.Protocol不能同时使用")
+	}
+}
@@ -0,0 +1,322 @@
+package ihttp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoProxyAvailable 代理池中没有可用代理
+var ErrNoProxyAvailable = errors.New("代理池无可用代理")
+
+// ProxyStrategy 代理轮换策略
+type ProxyStrategy int
+
+const (
+	ProxyRoundRobin ProxyStrategy = iota // 轮询
+	ProxyRandom                          // 随机
+)
+
+// ProxyPool 代理池，支持轮询/随机轮换与失败冷却
+type ProxyPool struct {
+	Strategy ProxyStrategy
+	Cooldown time.Duration // 失败后的冷却时间，默认1分钟
+	MaxFails int           // 连续失败多少次进入冷却，默认1
+
+	mu      sync.Mutex
+	proxies []*proxyState
+	next    int
+}
+
+type proxyState struct {
+	url       string
+	fails     int
+	coolUntil time.Time
+}
+
+// NewProxyPool 创建代理池
+// proxies 支持 http://、https://、socks5:// 格式，可携带 user:pass@ 认证信息
+func NewProxyPool(proxies []string, strategy ProxyStrategy) *ProxyPool {
+	p := &ProxyPool{Strategy: strategy}
+	for _, proxy := range proxies {
+		p.Add(proxy)
+	}
+	return p
+}
+
+// Add 添加代理，重复代理忽略
+func (p *ProxyPool) Add(proxy string) {
+	proxy = strings.TrimSpace(proxy)
+	if proxy == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.proxies {
+		if s.url == proxy {
+			return
+		}
+	}
+	p.proxies = append(p.proxies, &proxyState{url: proxy})
+}
+
+// Remove 移除代理
+func (p *ProxyPool) Remove(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, s := range p.proxies {
+		if s.url == proxy {
+			p.proxies = append(p.proxies[:i], p.proxies[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len 返回代理总数
+func (p *ProxyPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.proxies)
+}
+
+// Healthy 返回当前可用的代理列表
+func (p *ProxyPool) Healthy() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	res := []string{}
+	for _, s := range p.proxies {
+		if !now.Before(s.coolUntil) {
+			res = append(res, s.url)
+		}
+	}
+	return res
+}
+
+// Next 按策略取出下一个可用代理
+func (p *ProxyPool) Next() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]int, 0, len(p.proxies))
+	for i, s := range p.proxies {
+		if !now.Before(s.coolUntil) {
+			healthy = append(healthy, i)
+		}
+	}
+	if len(healthy) == 0 {
+		return "", ErrNoProxyAvailable
+	}
+
+	if p.Strategy == ProxyRandom {
+		return p.proxies[healthy[rand.Intn(len(healthy))]].url, nil
+	}
+
+	// 轮询：从上次位置开始找第一个可用代理
+	for i := 0; i < len(p.proxies); i++ {
+		idx := (p.next + i) % len(p.proxies)
+		if !now.Before(p.proxies[idx].coolUntil) {
+			p.next = idx + 1
+			return p.proxies[idx].url, nil
+		}
+	}
+	return "", ErrNoProxyAvailable
+}
+
+// MarkFailed 标记代理失败，连续失败达到MaxFails后进入冷却
+func (p *ProxyPool) MarkFailed(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.proxies {
+		if s.url != proxy {
+			continue
+		}
+		s.fails++
+		maxFails := p.MaxFails
+		if maxFails < 1 {
+			maxFails = 1
+		}
+		if s.fails >= maxFails {
+			cooldown := p.Cooldown
+			if cooldown <= 0 {
+				cooldown = time.Minute
+			}
+			s.coolUntil = time.Now().Add(cooldown)
+			s.fails = 0
+		}
+		return
+	}
+}
+
+// MarkSuccess 标记代理成功，清除失败计数
+func (p *ProxyPool) MarkSuccess(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.proxies {
+		if s.url == proxy {
+			s.fails = 0
+			s.coolUntil = time.Time{}
+			return
+		}
+	}
+}
+
+// resolveProxy 确定本次请求使用的代理
+// 优先级: Opt.Proxy > Opt.ProxyPool > 环境变量
+func resolveProxy(opt *Opt) (proxy string, fromEnv bool, err error) {
+	if opt.Proxy != "" {
+		return opt.Proxy, false, nil
+	}
+	if opt.ProxyPool != nil {
+		proxy, err = opt.ProxyPool.Next()
+		return proxy, false, err
+	}
+	proxy = getProxyFromEnv(opt.URL)
+	return proxy, proxy != "", nil
+}
+
+// markProxy 根据请求结果更新代理池中代理的健康状态
+func markProxy(pool *ProxyPool, proxy string, resp *Response, err error) {
+	if resp == nil && err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		pool.MarkFailed(proxy)
+		return
+	}
+	if resp != nil && resp.StatusCode == http.StatusProxyAuthRequired {
+		pool.MarkFailed(proxy)
+		return
+	}
+	pool.MarkSuccess(proxy)
+}
+
+// parseProxyURL 解析代理地址，未带协议时默认为http
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址解析失败: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少主机: %s", proxy)
+	}
+	return u, nil
+}
+
+// maxCachedTransports 每类派生Transport的缓存上限，超出时淘汰最久未使用的并关闭其空闲连接
+const maxCachedTransports = 64
+
+// transportCache 按key缓存派生的Transport，复用连接池，按LRU淘汰
+// 每次请求新建Transport或轮换大量代理时不会无限增长
+type transportCache struct {
+	mu    sync.Mutex
+	limit int
+	ll    *list.List // 元素为 *transportEntry，队首为最近使用
+	items map[any]*list.Element
+}
+
+type transportEntry struct {
+	key any
+	rt  http.RoundTripper
+}
+
+func newTransportCache(limit int) *transportCache {
+	return &transportCache{limit: limit, ll: list.New(), items: map[any]*list.Element{}}
+}
+
+// get 返回key对应的Transport，不存在时通过create创建
+func (c *transportCache) get(key any, create func() (http.RoundTripper, error)) (http.RoundTripper, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*transportEntry).rt, nil
+	}
+	rt, err := create()
+	if err != nil {
+		return nil, err
+	}
+	c.items[key] = c.ll.PushFront(&transportEntry{key: key, rt: rt})
+	for c.ll.Len() > c.limit {
+		oldest := c.ll.Back()
+		entry := oldest.Value.(*transportEntry)
+		c.ll.Remove(oldest)
+		delete(c.items, entry.key)
+		// 进行中的请求不受影响，仅关闭空闲连接
+		if ci, ok := entry.rt.(interface{ CloseIdleConnections() }); ok {
+			ci.CloseIdleConnections()
+		}
+	}
+	return rt, nil
+}
+
+// len 返回缓存的Transport数量
+func (c *transportCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// proxyTransports 缓存按代理派生的Transport
+var proxyTransports = newTransportCache(maxCachedTransports)
+
+type proxyTransportKey struct {
+	base  *http.Transport
+	proxy string
+}
+
+// clientWithProxy 基于原客户端派生出走代理的客户端，不修改原客户端
+func clientWithProxy(base *http.Client, proxy string) (*http.Client, error) {
+	if proxy == "" {
+		return base, nil
+	}
+	u, err := parseProxyURL(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTransport := base.Transport
+	if baseTransport == nil {
+		baseTransport = http.DefaultTransport
+	}
+	t, ok := baseTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("自定义Transport不支持设置代理")
+	}
+
+	key := proxyTransportKey{base: t, proxy: u.String()}
+	tr, _ := proxyTransports.get(key, func() (http.RoundTripper, error) {
+		clone := t.Clone()
+		clone.Proxy = http.ProxyURL(u)
+		return clone, nil
+	})
+
+	c := *base
+	c.Transport = tr
+	return &c, nil
+}
+
+// redactProxy 隐藏代理地址中的密码，用于日志
+func redactProxy(proxy string) string {
+	u, err := parseProxyURL(proxy)
+	if err != nil {
+		return proxy
+	}
+	return u.Redacted()
+}
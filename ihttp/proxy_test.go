@@ -0,0 +1,171 @@
+package ihttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestProxy 返回一个记录请求次数的简易HTTP正向代理
+func newTestProxy(t *testing.T, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.Header.Get("Proxy-Authorization") == "" {
+			t.Errorf("代理未收到认证信息")
+		}
+		w.Write([]byte("via-proxy " + r.URL.String()))
+	}))
+}
+
+func TestOptProxy(t *testing.T) {
+	var hits int32
+	proxy := newTestProxy(t, &hits)
+	defer proxy.Close()
+
+	u, _ := url.Parse(proxy.URL)
+	u.User = url.UserPassword("user", "pass")
+
+	shared := &http.Client{}
+	resp, err := Get("http://example.invalid/path", &Opt{HttpCLi: shared, Proxy: u.String(), NotLog: true})
+	if err != nil {
+		t.Fatalf("代理请求失败: %v", err)
+	}
+	if resp.Text != "via-proxy http://example.invalid/path" || hits != 1 {
+		t.Fatalf("请求未经过代理: %s", resp.Text)
+	}
+	if shared.Transport != nil {
+		t.Fatal("共享客户端被修改")
+	}
+}
+
+func TestProxyPoolRotationAndCooldown(t *testing.T) {
+	pool := NewProxyPool([]string{"http://a:1", "http://b:2", "http://c:3"}, ProxyRoundRobin)
+	pool.Cooldown = 50 * time.Millisecond
+
+	seen := []string{}
+	for i := 0; i < 4; i++ {
+		p, _ := pool.Next()
+		seen = append(seen, p)
+	}
+	if seen[0] != "http://a:1" || seen[1] != "http://b:2" || seen[2] != "http://c:3" || seen[3] != "http://a:1" {
+		t.Fatalf("轮询顺序错误: %v", seen)
+	}
+
+	pool.MarkFailed("http://b:2")
+	if len(pool.Healthy()) != 2 {
+		t.Fatalf("失败代理应进入冷却: %v", pool.Healthy())
+	}
+	for i := 0; i < 4; i++ {
+		if p, _ := pool.Next(); p == "http://b:2" {
+			t.Fatal("冷却中的代理不应被选中")
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if len(pool.Healthy()) != 3 {
+		t.Fatal("冷却结束后代理应恢复")
+	}
+
+	pool.MarkFailed("http://a:1")
+	pool.MarkFailed("http://b:2")
+	pool.MarkFailed("http://c:3")
+	if _, err := pool.Next(); err != ErrNoProxyAvailable {
+		t.Fatalf("全部冷却时应返回ErrNoProxyAvailable: %v", err)
+	}
+}
+
+func TestProxyPoolFailover(t *testing.T) {
+	var hits int32
+	proxy := newTestProxy(t, &hits)
+	defer proxy.Close()
+
+	good, _ := url.Parse(proxy.URL)
+	good.User = url.User("user")
+	// 先使用一个无法连接的代理，失败后重试应切换到可用代理
+	pool := NewProxyPool([]string{"http://127.0.0.1:1", good.String()}, ProxyRoundRobin)
+
+	resp, err := Get("http://example.invalid/", &Opt{
+		ProxyPool: pool,
+		NotLog:    true,
+		Retry:     &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	})
+	if err != nil || hits != 1 {
+		t.Fatalf("代理切换失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态码错误: %d", resp.StatusCode)
+	}
+	if healthy := pool.Healthy(); len(healthy) != 1 || healthy[0] != good.String() {
+		t.Fatalf("失败代理应被标记: %v", healthy)
+	}
+}
+
+func TestProxyFromEnv(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://env-proxy:8080")
+	t.Setenv("NO_PROXY", "localhost,.internal.com")
+
+	if p := getProxyFromEnv("http://example.com"); p != "http://env-proxy:8080" {
+		t.Fatalf("环境变量代理读取错误: %s", p)
+	}
+	if p := getProxyFromEnv("http://api.internal.com"); p != "" {
+		t.Fatalf("NO_PROXY未生效: %s", p)
+	}
+	if p := getProxyFromEnv("http://localhost:8080"); p != "" {
+		t.Fatalf("NO_PROXY未生效: %s", p)
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	if u, err := parseProxyURL("socks5://u:p@127.0.0.1:1080"); err != nil || u.User.Username() != "u" {
+		t.Fatalf("socks5解析失败: %v", err)
+	}
+	if u, err := parseProxyURL("127.0.0.1:8080"); err != nil || u.Scheme != "http" {
+		t.Fatalf("默认协议应为http: %v", err)
+	}
+	if _, err := parseProxyURL("ftp://127.0.0.1"); err == nil {
+		t.Fatal("不支持的协议应报错")
+	}
+}
+
+// idleCounter 记录CloseIdleConnections调用次数
+type idleCounter struct {
+	http.RoundTripper
+	closed atomic.Int32
+}
+
+func (c *idleCounter) CloseIdleConnections() { c.closed.Add(1) }
+
+func TestTransportCacheEviction(t *testing.T) {
+	cache := newTransportCache(2)
+	created := map[string]*idleCounter{}
+	get := func(key string) http.RoundTripper {
+		rt, _ := cache.get(key, func() (http.RoundTripper, error) {
+			created[key] = &idleCounter{}
+			return created[key], nil
+		})
+		return rt
+	}
+	a := get("a")
+	get("b")
+	if get("a") != a || len(created) != 2 {
+		t.Fatal("命中时应复用已缓存的Transport")
+	}
+	// a最近使用过，淘汰b
+	get("c")
+	if cache.len() != 2 || created["b"].closed.Load() != 1 || created["a"].closed.Load() != 0 {
+		t.Fatalf("LRU淘汰错误: %d %d %d", cache.len(), created["b"].closed.Load(), created["a"].closed.Load())
+	}
+
+	// 每次请求新建Transport时全局缓存不会无限增长
+	for i := 0; i < maxCachedTransports*2; i++ {
+		if _, err := clientWithProxy(&http.Client{Transport: &http.Transport{}}, "http://127.0.0.1:8080"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := proxyTransports.len(); n > maxCachedTransports {
+		t.Fatalf("代理Transport缓存超出上限: %d", n)
+	}
+}
@@ -0,0 +1,230 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited FailFast模式下限流预算耗尽
+var ErrRateLimited = errors.New("请求被限流")
+
+// Limit 单个主机的限流配置，零值字段表示不限制
+type Limit struct {
+	Rate          float64 // 每秒允许的请求数(令牌桶填充速率)
+	Burst         int     // 令牌桶容量，默认1
+	MaxConcurrent int     // 最大并发请求数
+}
+
+// RateLimiter 按主机限流，包含令牌桶速率限制与最大并发限制
+// 可通过 SetRateLimiter 全局生效，或设置到 Opt/Client 上按会话生效
+//
+// 使用示例:
+//
+//	limiter := ihttp.NewRateLimiter(ihttp.Limit{Rate: 5, MaxConcurrent: 2})
+//	limiter.SetLimit("api.mail.tm", ihttp.Limit{Rate: 1, Burst: 3})
+//	ihttp.SetRateLimiter(limiter)
+type RateLimiter struct {
+	Default  Limit // 未单独配置的主机使用的限制
+	FailFast bool  // 为true时预算耗尽立即返回ErrRateLimited，否则阻塞等待
+
+	mu     sync.Mutex
+	limits map[string]Limit
+	hosts  map[string]*hostLimiter
+}
+
+// NewRateLimiter 创建限流器，def为各主机默认限制
+func NewRateLimiter(def Limit) *RateLimiter {
+	return &RateLimiter{Default: def}
+}
+
+// SetLimit 设置指定主机的限制，host可为 "example.com" 或 "example.com:8080"
+func (l *RateLimiter) SetLimit(host string, limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	host = strings.ToLower(host)
+	if l.limits == nil {
+		l.limits = map[string]Limit{}
+	}
+	l.limits[host] = limit
+	// 配置变化后重新创建该主机的状态
+	for key := range l.hosts {
+		if key == host || hostnameOf(key) == host {
+			delete(l.hosts, key)
+		}
+	}
+}
+
+// Wait 获取host的请求预算，返回释放函数与等待时长
+// 请求结束后必须调用release归还并发名额
+func (l *RateLimiter) Wait(ctx context.Context, host string) (release func(), wait time.Duration, err error) {
+	if l == nil {
+		return func() {}, 0, nil
+	}
+	return l.host(strings.ToLower(host)).acquire(ctx, l.FailFast)
+}
+
+// host 返回主机状态，优先匹配带端口的配置
+func (l *RateLimiter) host(host string) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, ok := l.hosts[host]; ok {
+		return h
+	}
+	limit, ok := l.limits[host]
+	if !ok {
+		if limit, ok = l.limits[hostnameOf(host)]; !ok {
+			limit = l.Default
+		}
+	}
+	h := newHostLimiter(limit)
+	if l.hosts == nil {
+		l.hosts = map[string]*hostLimiter{}
+	}
+	l.hosts[host] = h
+	return h
+}
+
+// hostLimiter 单个主机的令牌桶与并发信号量
+type hostLimiter struct {
+	rate  float64
+	burst float64
+	sem   chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newHostLimiter(limit Limit) *hostLimiter {
+	h := &hostLimiter{rate: limit.Rate, burst: float64(limit.Burst)}
+	if h.burst < 1 {
+		h.burst = 1
+	}
+	h.tokens = h.burst
+	if limit.MaxConcurrent > 0 {
+		h.sem = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return h
+}
+
+// acquire 先占用并发名额再获取令牌，失败时归还已占用的名额
+func (h *hostLimiter) acquire(ctx context.Context, failFast bool) (func(), time.Duration, error) {
+	start := time.Now()
+	release := func() {}
+
+	if h.sem != nil {
+		if failFast {
+			select {
+			case h.sem <- struct{}{}:
+			default:
+				return nil, 0, ErrRateLimited
+			}
+		} else {
+			select {
+			case h.sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, time.Since(start), ctx.Err()
+			}
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-h.sem }) }
+	}
+
+	if h.rate > 0 {
+		delay, ok := h.reserve(failFast)
+		if !ok {
+			release()
+			return nil, 0, ErrRateLimited
+		}
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				h.cancel()
+				release()
+				return nil, time.Since(start), ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return release, time.Since(start), nil
+}
+
+// reserve 预占一个令牌，返回需要等待的时间
+// failFast时令牌不足直接返回false且不消耗令牌
+func (h *hostLimiter) reserve(failFast bool) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if !h.last.IsZero() {
+		h.tokens += now.Sub(h.last).Seconds() * h.rate
+		if h.tokens > h.burst {
+			h.tokens = h.burst
+		}
+	}
+	h.last = now
+
+	if h.tokens >= 1 {
+		h.tokens--
+		return 0, true
+	}
+	if failFast {
+		return 0, false
+	}
+	// 允许令牌为负数，后续请求按顺序排队
+	h.tokens--
+	return time.Duration(-h.tokens / h.rate * float64(time.Second)), true
+}
+
+// cancel 等待被取消时归还预占的令牌
+func (h *hostLimiter) cancel() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens++
+}
+
+// hostnameOf 去掉host中的端口
+func hostnameOf(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
+
+var (
+	rateLimiterMu sync.RWMutex
+	rateLimiter   *RateLimiter
+)
+
+// SetRateLimiter 设置全局限流器，Opt.RateLimiter 未设置时使用，传nil关闭
+func SetRateLimiter(l *RateLimiter) {
+	rateLimiterMu.Lock()
+	defer rateLimiterMu.Unlock()
+	rateLimiter = l
+}
+
+// resolveRateLimiter 返回本次请求使用的限流器
+func resolveRateLimiter(opt *Opt) *RateLimiter {
+	if opt.RateLimiter != nil {
+		return opt.RateLimiter
+	}
+	rateLimiterMu.RLock()
+	defer rateLimiterMu.RUnlock()
+	return rateLimiter
+}
+
+// releaseBody 关闭响应流时归还限流并发名额
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
@@ -0,0 +1,132 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	l := NewRateLimiter(Limit{Rate: 10, Burst: 2})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		release, _, err := l.Wait(ctx, "example.com")
+		if err != nil {
+			t.Fatalf("获取令牌失败: %v", err)
+		}
+		release()
+	}
+	// 突发2个后剩余2个各需等待100ms
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("限流未生效, 耗时 %v", elapsed)
+	}
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	l := NewRateLimiter(Limit{Rate: 1})
+	l.FailFast = true
+	ctx := context.Background()
+
+	if _, _, err := l.Wait(ctx, "example.com"); err != nil {
+		t.Fatalf("首次请求不应被限流: %v", err)
+	}
+	if _, _, err := l.Wait(ctx, "example.com"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("期望ErrRateLimited, 实际: %v", err)
+	}
+	// 不同主机互不影响
+	if _, _, err := l.Wait(ctx, "other.com"); err != nil {
+		t.Fatalf("其他主机不应被限流: %v", err)
+	}
+}
+
+func TestRateLimiterHostOverride(t *testing.T) {
+	l := NewRateLimiter(Limit{Rate: 1})
+	l.FailFast = true
+	l.SetLimit("example.com", Limit{Rate: 100, Burst: 5})
+	for i := 0; i < 5; i++ {
+		if _, _, err := l.Wait(context.Background(), "example.com:443"); err != nil {
+			t.Fatalf("第%d次请求被限流: %v", i+1, err)
+		}
+	}
+}
+
+func TestRateLimiterMaxConcurrent(t *testing.T) {
+	var cur, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&cur, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&cur, -1)
+	}))
+	defer srv.Close()
+
+	limiter := NewRateLimiter(Limit{MaxConcurrent: 2})
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Get(srv.URL, &Opt{NotLog: true, RateLimiter: limiter}); err != nil {
+				t.Errorf("请求失败: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Fatalf("并发超过限制: %d", peak)
+	}
+}
+
+func TestRateLimiterWaitRecorded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var waits []time.Duration
+	record := func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			resp, err := next(req)
+			waits = append(waits, req.LimitWait)
+			return resp, err
+		}
+	}
+
+	opt := &Opt{NotLog: true, RateLimiter: NewRateLimiter(Limit{Rate: 20}), Middlewares: []Middleware{record}}
+	Get(srv.URL, opt)
+	Get(srv.URL, opt)
+	if len(waits) != 2 || waits[0] > 20*time.Millisecond || waits[1] < 20*time.Millisecond {
+		t.Fatalf("等待时间记录错误: %v", waits)
+	}
+}
+
+func TestRateLimiterStreamReleasesOnClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	limiter := NewRateLimiter(Limit{MaxConcurrent: 1})
+	limiter.FailFast = true
+	resp, err := Get(srv.URL, &Opt{NotLog: true, Stream: true, RateLimiter: limiter})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if _, err := Get(srv.URL, &Opt{NotLog: true, RateLimiter: limiter}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("流未关闭时应占用并发名额: %v", err)
+	}
+	resp.Close()
+	if _, err := Get(srv.URL, &Opt{NotLog: true, RateLimiter: limiter}); err != nil {
+		t.Fatalf("关闭流后应归还名额: %v", err)
+	}
+}
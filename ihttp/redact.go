@@ -0,0 +1,248 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// defaultMask 脱敏后的替换内容
+const defaultMask = "***"
+
+// Redactor 脱敏规则，作用于ilog日志、HTTPError、HAR录制与追踪属性url.full
+// 字段在首次使用后不应再修改
+type Redactor struct {
+	Headers  []string         // 需脱敏的请求头，忽略大小写
+	Keys     []string         // 需脱敏的JSON/表单字段名，忽略大小写与 _ -，如 clientKey 可匹配 client_key
+	Query    []string         // 需脱敏的URL查询参数，忽略大小写，同样作用于文本中出现的 key=value
+	Patterns []*regexp.Regexp // 匹配内容整体替换为Mask
+	Mask     string           // 替换内容，默认 ***
+
+	once    sync.Once
+	headers map[string]bool
+	keys    map[string]bool
+	query   *regexp.Regexp
+}
+
+// DefaultRedactor 返回默认脱敏规则，覆盖常见的认证头、密码、令牌与密钥字段
+func DefaultRedactor() *Redactor {
+	return &Redactor{
+		Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+			"X-API-KEY", "X-Auth-Token", "X-Access-Token", "X-Signature", "Api-Key"},
+		Keys: []string{"password", "passwd", "pass", "pwd", "secret", "clientSecret", "token",
+			"accessToken", "refreshToken", "idToken", "clientKey", "apiKey", "apiSecret",
+			"privateKey", "mnemonic", "seed", "signature", "sign"},
+		Query: []string{"password", "passwd", "pass", "pwd", "token", "access_token", "refresh_token",
+			"apikey", "api_key", "key", "secret", "signature", "sign", "clientKey"},
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9\-._~+/]+=*`),
+			regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), // JWT
+		},
+	}
+}
+
+func (r *Redactor) init() {
+	r.once.Do(func() {
+		r.headers = make(map[string]bool, len(r.Headers))
+		for _, h := range r.Headers {
+			r.headers[strings.ToLower(h)] = true
+		}
+		r.keys = make(map[string]bool, len(r.Keys))
+		for _, k := range r.Keys {
+			r.keys[normalizeKey(k)] = true
+		}
+		if len(r.Query) > 0 {
+			names := make([]string, len(r.Query))
+			for i, q := range r.Query {
+				names[i] = regexp.QuoteMeta(q)
+			}
+			r.query = regexp.MustCompile(`(?i)((?:^|[?&\s"'])(?:` + strings.Join(names, "|") + `)=)[^&#\s"']*`)
+		}
+	})
+}
+
+func (r *Redactor) mask() string {
+	if r.Mask == "" {
+		return defaultMask
+	}
+	return r.Mask
+}
+
+// normalizeKey 统一字段名大小写并去掉 _ -
+func normalizeKey(k string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(k))
+}
+
+// Header 返回脱敏后的请求头副本，r为nil时原样返回
+func (r *Redactor) Header(headers map[string]string) map[string]string {
+	if r == nil || len(headers) == 0 {
+		return headers
+	}
+	r.init()
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if r.headers[strings.ToLower(k)] {
+			out[k] = r.mask()
+		} else {
+			out[k] = r.Text(v)
+		}
+	}
+	return out
+}
+
+// URL 返回查询参数脱敏后的地址
+func (r *Redactor) URL(rawURL string) string {
+	if r == nil {
+		return rawURL
+	}
+	r.init()
+	if u, err := url.Parse(rawURL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			rawURL = u.Redacted()
+		}
+	}
+	return r.replaceQuery(rawURL)
+}
+
+// Body 返回脱敏后的请求体，结构体等先转为JSON通用结构再处理
+func (r *Redactor) Body(v any) any {
+	if r == nil || v == nil {
+		return v
+	}
+	r.init()
+	switch body := v.(type) {
+	case map[string]any:
+		return r.redactValue(body)
+	case string:
+		return r.Text(body)
+	case []byte:
+		return r.Text(string(body))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return v
+	}
+	return r.redactValue(generic)
+}
+
+// Text 对任意文本脱敏：JSON文本按字段名处理，并替换 key=value 形式的敏感参数与Patterns匹配内容
+func (r *Redactor) Text(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	r.init()
+	if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		// 保留数字原文，避免大整数丢失精度
+		var generic any
+		dec := json.NewDecoder(strings.NewReader(trimmed))
+		dec.UseNumber()
+		if dec.Decode(&generic) == nil && !dec.More() {
+			if data, err := json.Marshal(r.redactValue(generic)); err == nil {
+				s = string(data)
+			}
+		}
+	}
+	s = r.replaceQuery(s)
+	for _, p := range r.Patterns {
+		s = p.ReplaceAllString(s, r.mask())
+	}
+	return s
+}
+
+// textIfChanged 对文本脱敏，未命中任何规则时返回原文，避免JSON重新序列化改变内容
+func (r *Redactor) textIfChanged(s string) string {
+	if r == nil {
+		return s
+	}
+	redacted := r.Text(s)
+	if !strings.Contains(redacted, r.mask()) {
+		return s
+	}
+	return redacted
+}
+
+// Error 返回脱敏后的错误描述，err为nil时返回nil
+func (r *Redactor) Error(err error) any {
+	if err == nil {
+		return nil
+	}
+	if r == nil {
+		return err
+	}
+	return r.Text(err.Error())
+}
+
+func (r *Redactor) replaceQuery(s string) string {
+	if r.query == nil {
+		return s
+	}
+	return r.query.ReplaceAllString(s, "${1}"+r.mask())
+}
+
+// redactValue 递归处理JSON通用结构，返回新的值不修改原值
+func (r *Redactor) redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			if r.keys[normalizeKey(k)] {
+				out[k] = r.mask()
+			} else {
+				out[k] = r.redactValue(item)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = r.redactValue(item)
+		}
+		return out
+	case string:
+		for _, p := range r.Patterns {
+			val = p.ReplaceAllString(val, r.mask())
+		}
+		return val
+	case json.Number:
+		return val
+	default:
+		if s, ok := v.(fmt.Stringer); ok {
+			return r.Text(s.String())
+		}
+		return v
+	}
+}
+
+var (
+	redactorMu sync.RWMutex
+	redactor   = DefaultRedactor()
+)
+
+// SetRedactor 设置全局脱敏规则，Opt.Redactor 未设置时使用，默认为 DefaultRedactor，传nil关闭(HAR录制仍使用 DefaultRedactor)
+func SetRedactor(r *Redactor) {
+	redactorMu.Lock()
+	defer redactorMu.Unlock()
+	redactor = r
+}
+
+// resolveRedactor 返回本次请求使用的脱敏规则
+func resolveRedactor(opt *Opt) *Redactor {
+	if opt != nil && opt.Redactor != nil {
+		return opt.Redactor
+	}
+	redactorMu.RLock()
+	defer redactorMu.RUnlock()
+	return redactor
+}
+
+// Redact 使用全局脱敏规则处理文本，可用于调用方自行输出的日志
+func Redact(s string) string {
+	return resolveRedactor(nil).Text(s)
+}
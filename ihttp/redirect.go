@@ -0,0 +1,75 @@
+package ihttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxRedirects 未设置 Opt.MaxRedirects 时最多跟随的重定向次数，与标准库一致
+const defaultMaxRedirects = 10
+
+// ErrTooManyRedirects 重定向次数超过 Opt.MaxRedirects
+var ErrTooManyRedirects = errors.New("重定向次数超过上限")
+
+// RedirectHop 重定向链中的一跳
+type RedirectHop struct {
+	StatusCode int            // 重定向响应状态码，如302
+	URL        string         // 本跳请求地址
+	Location   string         // 重定向目标地址
+	Headers    http.Header    // 重定向响应头
+	Cookies    []*http.Cookie // 本跳响应设置的Cookie
+}
+
+// withRedirect 返回按Opt控制重定向并记录重定向链的客户端副本
+// 中间跳设置的Cookie会合并到 Opt.Cookies 并随后续请求发送，使用Jar时由Jar自动处理
+func withRedirect(client *http.Client, opt *Opt, chain *[]RedirectHop) *http.Client {
+	c := *client
+	checkRedirect := client.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if opt.NoRedirect {
+			return http.ErrUseLastResponse
+		}
+
+		prev := via[len(via)-1]
+		if resp := req.Response; resp != nil {
+			*chain = append(*chain, RedirectHop{
+				StatusCode: resp.StatusCode,
+				URL:        prev.URL.String(),
+				Location:   req.URL.String(),
+				Headers:    resp.Header,
+				Cookies:    resp.Cookies(),
+			})
+			if opt.Cookies != nil && c.Jar == nil {
+				updateCookiesFromResponse(opt.Cookies, resp)
+				// 与标准库一致，跳转到其他域名时不携带Cookie
+				if isDomainOrSubdomain(req.URL.Hostname(), via[0].URL.Hostname()) {
+					req.Header.Del("Cookie")
+					for name, value := range *opt.Cookies {
+						req.AddCookie(&http.Cookie{Name: name, Value: value})
+					}
+				}
+			}
+		}
+
+		max := opt.MaxRedirects
+		if max <= 0 {
+			max = defaultMaxRedirects
+		}
+		if len(via) > max {
+			return fmt.Errorf("%w(%d)", ErrTooManyRedirects, max)
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		return nil
+	}
+	return &c
+}
+
+// isDomainOrSubdomain 判断sub是否为parent或其子域名
+func isDomainOrSubdomain(sub, parent string) bool {
+	sub, parent = strings.ToLower(sub), strings.ToLower(parent)
+	return sub == parent || strings.HasSuffix(sub, "."+parent)
+}
@@ -0,0 +1,103 @@
+package ihttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newLoginServer 模拟登录流程：/login 与 /sso 在302响应中设置Cookie
+func newLoginServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			http.Redirect(w, r, "/sso", http.StatusFound)
+		case "/sso":
+			if _, err := r.Cookie("session"); err != nil {
+				http.Error(w, "缺少session", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "t1", Path: "/"})
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			var names []string
+			for _, c := range r.Cookies() {
+				names = append(names, c.Name+"="+c.Value)
+			}
+			sort.Strings(names)
+			w.Write([]byte(strings.Join(names, ";")))
+		}
+	}))
+}
+
+func TestRedirectChainCookies(t *testing.T) {
+	srv := newLoginServer()
+	defer srv.Close()
+
+	cookies := map[string]string{"lang": "zh"}
+	resp, err := Post(srv.URL+"/login", &Opt{NotLog: true, Cookies: &cookies})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Text != "lang=zh;session=s1;token=t1" {
+		t.Fatalf("中间跳Cookie未携带: %d %s", resp.StatusCode, resp.Text)
+	}
+	if cookies["session"] != "s1" || cookies["token"] != "t1" {
+		t.Fatalf("中间跳Cookie未合并: %v", cookies)
+	}
+	if resp.URL != srv.URL+"/home" || len(resp.RedirectChain) != 2 {
+		t.Fatalf("重定向链错误: %s %+v", resp.URL, resp.RedirectChain)
+	}
+	hop := resp.RedirectChain[0]
+	if hop.StatusCode != 302 || hop.URL != srv.URL+"/login" || hop.Location != srv.URL+"/sso" ||
+		len(hop.Cookies) != 1 || hop.Cookies[0].Name != "session" {
+		t.Fatalf("第一跳记录错误: %+v", hop)
+	}
+	if resp.RedirectChain[1].StatusCode != 303 || resp.RedirectChain[1].Cookies[0].Value != "t1" {
+		t.Fatalf("第二跳记录错误: %+v", resp.RedirectChain[1])
+	}
+
+	// 会话Jar
+	cli := NewClient(srv.URL)
+	cli.NotLog = true
+	resp, err = cli.Get("/login", nil)
+	if err != nil || resp.Text != "session=s1;token=t1" || len(resp.RedirectChain) != 2 {
+		t.Fatalf("Jar模式失败: %v %s", err, resp.Text)
+	}
+	if cli.Jar.Len() != 2 {
+		t.Fatalf("Jar中Cookie数量错误: %d", cli.Jar.Len())
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	srv := newLoginServer()
+	defer srv.Close()
+
+	cookies := map[string]string{}
+	resp, err := Get(srv.URL+"/login", &Opt{NotLog: true, NoRedirect: true, Cookies: &cookies})
+	if err != nil || resp.StatusCode != http.StatusFound || len(resp.RedirectChain) != 0 {
+		t.Fatalf("不跟随重定向失败: %v %+v", err, resp)
+	}
+	if http.Header(resp.Headers).Get("Location") != "/sso" || cookies["session"] != "s1" {
+		t.Fatalf("3xx响应信息错误: %v %v", resp.Headers, cookies)
+	}
+
+	_, err = Get(srv.URL+"/login", &Opt{NotLog: true, MaxRedirects: 1, Cookies: &map[string]string{}})
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatalf("期望ErrTooManyRedirects, 实际: %v", err)
+	}
+	_, err = Get(srv.URL+"/loop", &Opt{NotLog: true})
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatalf("默认上限未生效: %v", err)
+	}
+	resp, err = Get(srv.URL+"/login", &Opt{NotLog: true, MaxRedirects: 2, Cookies: &map[string]string{}})
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("上限内应成功: %v", err)
+	}
+}
@@ -2,15 +2,14 @@ package ihttp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,166 +18,213 @@ import (
 
 // Do 执行HTTP请求
 func Do(opt *Opt) (*Response, error) {
+	return DoContext(context.Background(), opt)
+}
+
+// DoContext 执行HTTP请求，ctx贯穿请求体构建、发送与响应读取全过程
+// opt.TimeOut 以ctx截止时间的方式生效，不会修改共享的 HttpCLi
+func DoContext(ctx context.Context, opt *Opt) (response *Response, err error) {
 	if opt == nil {
 		return nil, errors.New("空配置项")
 	}
 	if opt.URL == "" {
 		return nil, errors.New("空请求链接")
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	start := time.Now()
 	if opt.Method == "" {
 		opt.Method = "GET"
 	}
 	method := strings.ToUpper(opt.Method)
 
-	// 校验请求体类型互斥
-	bodyKinds := 0
-	if len(opt.Data) > 0 {
-		bodyKinds++
+	// 校验请求体类型互斥，Data与Files可同时使用(multipart)
+	if opt.Json != nil && (len(opt.Data) > 0 || len(opt.Files) > 0) {
+		return nil, errors.New("Json不能与Data/Files同时使用")
 	}
-	if opt.Json != nil {
-		bodyKinds++
+	if opt.Body != nil && (opt.Json != nil || len(opt.Data) > 0 || len(opt.Files) > 0) {
+		return nil, errors.New("Body不能与Json/Data/Files同时使用")
 	}
-	if len(opt.Files) > 0 {
-		bodyKinds++
-	}
-	if bodyKinds > 1 {
-		return nil, errors.New("只能设置Data/Json/Files其中一种请求")
+	if opt.Impersonate != nil && opt.Protocol != ProtocolAuto {
+		return nil, errors.New("Impersonate不能与Protocol同时使用")
 	}
 
-	// 准备请求体用于日志记录
-	var logBody any
-	if len(opt.Data) > 0 {
-		logBody = opt.Data
-	} else if opt.Json != nil {
-		logBody = opt.Json
-	} else if len(opt.Files) > 0 {
-		logBody = opt.Files
+	client := opt.HttpCLi
+	if client == nil {
+		client = defaultClient
 	}
 
-	// 使用defer确保日志记录
-	var response *Response
-	var err error
+	// 超时通过ctx控制，避免修改共享客户端的Timeout
+	// Stream模式下超时覆盖整个读取过程，关闭响应流时才释放
+	cancel := context.CancelFunc(func() {})
+	if opt.TimeOut > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(opt.TimeOut)*time.Second)
+	}
 	defer func() {
-		if !opt.NotLog {
-			elapsed := time.Since(start)
-			args := []any{
-				"方法", opt.Method,
-				"URL", opt.URL,
-				"请求体", logBody,
-				"请求Header", opt.Headers,
-				"耗时", elapsed.String(),
-			}
+		if response == nil || response.Reader == nil {
+			cancel()
+			return
+		}
+		if err != nil {
+			response.Close()
+			cancel()
+			return
+		}
+		response.Reader = &streamBody{ReadCloser: response.Reader, cancel: cancel}
+	}()
 
-			if response != nil {
-				args = append(args, "响应码", response.StatusCode)
-
-				// 仅在错误或非2xx时记录响应体
-				if err != nil || response.StatusCode >= 400 {
-					rb := response.Text
-					if len(rb) > 1024 {
-						rb = rb[:1024] + "...(省略)"
-					}
-					args = append(args, "响应体", rb)
-				}
-			} else {
-				args = append(args, "响应码", 0)
-			}
+	// 创建请求体，重试时重新打开
+	body, err := buildBody(opt)
+	if err != nil {
+		return nil, err
+	}
 
-			if err != nil {
-				args = append(args, "错误信息", err)
-			}
+	attempts := opt.Retry.attempts()
+	for attempt := 1; ; attempt++ {
+		req := &Request{Opt: opt, Attempt: attempt}
+		response, err = attemptOnce(ctx, client, method, body, req)
+		if opt.ProxyPool != nil && opt.Proxy == "" && req.Proxy != "" {
+			markProxy(opt.ProxyPool, req.Proxy, response, err)
+		}
 
-			ilog.Debug("调用请求", args...)
+		if attempt >= attempts || !opt.Retry.shouldRetry(ctx, response, err) {
+			return response, err
 		}
-	}()
+		response.Close()
 
-	var client *http.Client
+		wait := opt.Retry.backoff(attempt, response)
+		if !opt.NotLog {
+			ilog.Debug("请求重试", "方法", opt.Method, "URL", resolveRedactor(opt).URL(opt.URL),
+				"尝试次数", attempt, "重试等待", wait.String())
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return response, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attemptOnce 执行单次尝试：选择代理、构建请求并经过中间件链发送
+func attemptOnce(ctx context.Context, client *http.Client, method string,
+	body *requestBody, req *Request) (*Response, error) {
+	opt := req.Opt
 
-	if opt.HttpCLi != nil {
-		client = opt.HttpCLi
+	proxy, fromEnv, err := resolveProxy(opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Protocol == ProtocolHTTP3 {
+		// 代理无法承载QUIC，直接忽略会从本机出口发出请求，因此任何代理生效时都报错
+		if proxy == "" {
+			proxy, err = transportProxy(client, method, opt.URL)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if proxy != "" {
+			return nil, errors.New("HTTP/3不支持代理")
+		}
+	}
+	var proxyClient *http.Client
+	if opt.Impersonate != nil {
+		proxyClient, err = clientWithImpersonate(client, opt.Impersonate, proxy)
 	} else {
-		// 创建HTTP客户端
-		client = &http.Client{}
+		protocolClient, protoErr := clientWithProtocol(client, opt.Protocol)
+		if protoErr != nil {
+			return nil, protoErr
+		}
+		proxyClient, err = clientWithProxy(protocolClient, proxy)
 	}
-
-	if opt.TimeOut > 0 {
-		client.Timeout = time.Duration(opt.TimeOut) * time.Second
+	if err != nil {
+		if !fromEnv {
+			return nil, err
+		}
+		// 环境变量代理无法应用于自定义Transport时直接忽略
+		proxyClient, proxy = client, ""
 	}
+	req.Proxy = proxy
+	proxyClient = resolveHAR(opt).wrap(proxyClient)
 
-	// 创建请求
-	var reqBody io.Reader
-	var contentType string
+	req.Raw, err = newHTTPRequest(ctx, method, opt, body)
+	if err != nil {
+		return nil, err
+	}
+	req.payload = body.data
+
+	handler := buildChain(transportHandler(withJar(proxyClient, opt.Jar)), opt.Middlewares)
+	req.reached = false
+	rawBody := req.Raw.Body
+	response, err := handler(req)
+	if !req.reached && rawBody != nil {
+		// 中间件直接返回时请求体未被读取，需关闭以结束multipart写入协程
+		rawBody.Close()
+	}
+	if err != nil {
+		return response, err
+	}
 
-	// 设置请求体
-	if len(opt.Data) > 0 {
-		// Form数据
-		formData := url.Values{}
-		for k, v := range opt.Data {
-			formData.Set(k, fmt.Sprintf("%v", v))
-		}
-		reqBody = strings.NewReader(formData.Encode())
-		contentType = "application/x-www-form-urlencoded"
-	} else if opt.Json != nil {
-		// JSON数据
-		jsonData, err := json.Marshal(opt.Json)
-		if err != nil {
-			return nil, fmt.Errorf("JSON序列化失败: %v", err)
+	// 处理响应体反序列化
+	if opt.RespOut != nil && !opt.Stream &&
+		response.IsSuccess() &&
+		len(response.Body) > 0 {
+		if jsonErr := json.Unmarshal(response.Body,
+			opt.RespOut); jsonErr != nil {
+			r := resolveRedactor(opt)
+			ilog.Error("反序列化失败", "原始数据", r.Text(response.Text),
+				"传入结构", r.Body(opt.RespOut), "错误信息", jsonErr)
+			return response, jsonErr
 		}
-		reqBody = bytes.NewReader(jsonData)
-		contentType = "application/json"
-	} else if len(opt.Files) > 0 {
-		// 文件上传
-		var buf bytes.Buffer
-		writer := multipart.NewWriter(&buf)
-
-		for fieldName, file := range opt.Files {
-			var fileReader io.Reader
-			var fileName string
-
-			if file.Reader != nil {
-				fileReader = file.Reader
-				fileName = file.FileName
-			} else if file.Path != "" {
-				fileObj, err := os.Open(file.Path)
-				if err != nil {
-					return nil, fmt.Errorf("打开文件失败: %v", err)
-				}
-				defer fileObj.Close()
-				fileReader = fileObj
-				fileName = file.FileName
-				if fileName == "" {
-					fileName = filepath.Base(file.Path)
-				}
-			}
+	}
+	return response, nil
+}
 
-			if fileReader != nil {
-				part, err := writer.CreateFormFile(fieldName, fileName)
-				if err != nil {
-					return nil, fmt.Errorf("创建文件字段失败: %v", err)
-				}
-				_, err = io.Copy(part, fileReader)
-				if err != nil {
-					return nil, fmt.Errorf("复制文件内容失败: %v", err)
-				}
-			}
-		}
+// closeRequestBody 关闭未交给http.Client发送的请求体，http.Client.Do 会自行关闭请求体
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
 
-		writer.Close()
-		reqBody = bytes.NewReader(buf.Bytes())
-		contentType = writer.FormDataContentType()
+// newHTTPRequest 构建单次尝试的HTTP请求
+func newHTTPRequest(ctx context.Context, method string, opt *Opt,
+	body *requestBody) (*http.Request, error) {
+	reqBody, err := body.open(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建HTTP请求
-	req, err := http.NewRequest(method, opt.URL, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, opt.URL, reqBody)
 	if err != nil {
+		if reqBody != nil {
+			reqBody.Close()
+		}
 		return nil, fmt.Errorf("创建请求失败: %v", err)
 	}
+	if reqBody != nil {
+		req.ContentLength = body.length()
+		req.GetBody = func() (io.ReadCloser, error) { return body.open(ctx) }
+	}
 
 	// 设置请求头
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
+	if body.contentType != "" {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+	if p := opt.Impersonate; p != nil {
+		// 伪装请求头与实际使用的UA保持一致
+		ua := p.userAgent()
+		for k, v := range opt.Headers {
+			if strings.EqualFold(k, "User-Agent") {
+				ua = v
+			}
+		}
+		for _, h := range p.headers(ua) {
+			req.Header.Set(h[0], h[1])
+		}
 	}
 	for k, v := range opt.Headers {
 		req.Header.Set(k, v)
@@ -193,27 +239,83 @@ func Do(opt *Opt) (*Response, error) {
 			})
 		}
 	}
+	return req, nil
+}
 
-	// 执行请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// transportHandler 中间件链末端，发送请求并读取响应，同时记录耗时、指标与追踪
+func transportHandler(client *http.Client) Handler {
+	return func(req *Request) (*Response, error) {
+		req.reached = true
+		opt := req.Opt
+		host, method := req.Raw.URL.Host, req.Raw.Method
+		span := startSpan(resolveTracer(opt), req)
+		trace := &timingTrace{}
+		req.Raw = req.Raw.WithContext(httptrace.WithClientTrace(req.Raw.Context(), trace.clientTrace()))
+
+		response, err := roundTrip(client, req, trace)
+		var timing *Timing
+		if !trace.start.IsZero() {
+			timing = trace.result()
+			if response != nil {
+				response.Timing = timing
+			}
+		}
+		resolveMetrics(opt).observe(host, method, response, timing, err)
+		endSpan(span, response, timing, err)
+		return response, err
 	}
-	defer resp.Body.Close()
+}
 
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应体失败: %v", err)
+// roundTrip 优先使用新鲜缓存，否则限流、签名后发送请求，并读取响应
+func roundTrip(client *http.Client, req *Request, trace *timingTrace) (*Response, error) {
+	opt := req.Opt
+
+	// 新鲜的缓存直接返回，不经过限流与签名
+	var cacheStatus CacheStatus
+	var chain []RedirectHop
+	cache := resolveHTTPCache(opt)
+	resp, release := cache.hit(req.Raw, opt, client.Jar), func() {}
+	if resp != nil {
+		closeRequestBody(req.Raw)
+		trace.mark(&trace.start)
+		cacheStatus = CacheHit
+	} else {
+		var err error
+		if resp, release, err = sendRequest(client, cache, req, trace, &cacheStatus, &chain); err != nil {
+			return nil, err
+		}
 	}
 
+	// 按Content-Encoding自动解压
+	body := decodeBody(resp)
+
 	// 处理响应
-	response = &Response{
-		StatusCode: resp.StatusCode,
-		Body:       body,
-		Text:       string(body),
-		Headers:    resp.Header,
-		CookieList: []*http.Cookie{},
+	response := &Response{
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		Headers:       resp.Header,
+		CookieList:    []*http.Cookie{},
+		RedirectChain: chain,
+		CacheStatus:   cacheStatus,
+	}
+	if resp.Request != nil {
+		response.URL = resp.Request.URL.String()
+	}
+
+	if opt.Stream {
+		// 流式模式直接交出响应体，由调用方读取并关闭
+		response.Reader = &releaseBody{ReadCloser: body, release: release}
+	} else {
+		defer release()
+		defer body.Close()
+
+		// 读取响应体
+		respBody, err := io.ReadAll(&ctxReader{ctx: req.Raw.Context(), r: body})
+		if err != nil {
+			return nil, fmt.Errorf("读取响应体失败: %w", err)
+		}
+		response.Body = respBody
+		response.Text = string(respBody)
 	}
 
 	// 自动更新Cookies
@@ -221,21 +323,131 @@ func Do(opt *Opt) (*Response, error) {
 		cklist := updateCookiesFromResponse(opt.Cookies, resp)
 
 		response.CookieList = cklist
+	} else if opt.Jar != nil {
+		response.CookieList = resp.Cookies()
 	}
+	return response, nil
+}
 
-	// 处理响应体反序列化
-	if opt.RespOut != nil && response != nil &&
-		response.IsSuccess() &&
-		len(response.Body) > 0 {
-		if jsonErr := json.Unmarshal(response.Body,
-			opt.RespOut); jsonErr != nil {
-			ilog.Error("反序列化失败", "原始数据", response.Text,
-				"传入结构", opt.RespOut, "错误信息", jsonErr)
-			return response, jsonErr
+// sendRequest 限流、签名后经缓存发送请求，release在响应体读取完毕后归还限流并发名额
+func sendRequest(client *http.Client, cache *HTTPCache, req *Request, trace *timingTrace,
+	cacheStatus *CacheStatus, chain *[]RedirectHop) (*http.Response, func(), error) {
+	opt := req.Opt
+
+	// 按主机限流，并发名额在响应体读取完毕(Stream模式为关闭)后归还
+	release, wait, err := resolveRateLimiter(opt).Wait(req.Raw.Context(), req.Raw.URL.Host)
+	req.LimitWait += wait
+	if err != nil {
+		closeRequestBody(req.Raw)
+		return nil, nil, err
+	}
+
+	// 限流等待之后签名，保证时间戳有效
+	if opt.Signer != nil {
+		if err := signRequest(opt.Signer, req); err != nil {
+			closeRequestBody(req.Raw)
+			release()
+			return nil, nil, err
+		}
+	}
+
+	// 执行请求，过期缓存可能经条件请求重新验证
+	cli := withRedirect(cache.wrap(client, opt, cacheStatus), opt, chain)
+	trace.mark(&trace.start)
+	resp, err := cli.Do(req.Raw)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return resp, release, nil
+}
+
+// requestBody 请求体，每次尝试通过open重新生成读取器
+type requestBody struct {
+	data        []byte // Form/Json数据
+	contentType string
+	mp          *multipartBody
+	progress    ProgressFunc
+}
+
+// buildBody 根据Body/Data/Json/Files构建请求体
+// 含Files时使用multipart流式上传，Data作为普通表单字段一并发送
+func buildBody(opt *Opt) (*requestBody, error) {
+	body := &requestBody{progress: opt.UploadProgress}
+
+	if opt.Body != nil {
+		body.data = opt.Body
+		return body, nil
+	}
+
+	if len(opt.Files) > 0 {
+		mp, err := newMultipartBody(opt.Data, opt.Files)
+		if err != nil {
+			return nil, err
+		}
+		body.mp = mp
+		body.contentType = mp.contentType()
+		if opt.Signer != nil {
+			// 签名需要完整请求体，读入内存
+			if body.data, err = mp.bytes(); err != nil {
+				return nil, err
+			}
+			body.mp = nil
+		}
+		return body, nil
+	}
+
+	if len(opt.Data) > 0 {
+		// Form数据
+		formData := url.Values{}
+		for k, v := range opt.Data {
+			formData.Set(k, fmt.Sprintf("%v", v))
+		}
+		body.data = []byte(formData.Encode())
+		body.contentType = "application/x-www-form-urlencoded"
+		return body, nil
+	}
+
+	if opt.Json != nil {
+		// JSON数据
+		jsonData, err := json.Marshal(opt.Json)
+		if err != nil {
+			return nil, fmt.Errorf("JSON序列化失败: %v", err)
 		}
+		body.data = jsonData
+		body.contentType = "application/json"
 	}
+	return body, nil
+}
 
-	return response, err
+// open 生成新的请求体读取器，无请求体时返回nil
+func (b *requestBody) open(ctx context.Context) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	switch {
+	case b.mp != nil:
+		r, err := b.mp.open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rc = r
+	case b.data != nil:
+		rc = io.NopCloser(bytes.NewReader(b.data))
+	default:
+		return nil, nil
+	}
+
+	if b.progress != nil {
+		rc = &progressReader{ReadCloser: rc, total: b.length(), fn: b.progress}
+	}
+	return rc, nil
+}
+
+// length 返回请求体长度，未知时返回-1
+func (b *requestBody) length() int64 {
+	if b.mp != nil {
+		return b.mp.size
+	}
+	return int64(len(b.data))
 }
 
 // 便捷方法 - 支持多种HTTP方法
@@ -310,6 +522,52 @@ func Options(url string, opt *Opt) (*Response, error) {
 	return Do(opt)
 }
 
+// 支持context的便捷方法
+
+// GetContext 执行带ctx的GET请求
+func GetContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "GET", url, opt)
+}
+
+// PostContext 执行带ctx的POST请求
+func PostContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "POST", url, opt)
+}
+
+// PutContext 执行带ctx的PUT请求
+func PutContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "PUT", url, opt)
+}
+
+// PatchContext 执行带ctx的PATCH请求
+func PatchContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "PATCH", url, opt)
+}
+
+// DeleteContext 执行带ctx的DELETE请求
+func DeleteContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "DELETE", url, opt)
+}
+
+// HeadContext 执行带ctx的HEAD请求
+func HeadContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "HEAD", url, opt)
+}
+
+// OptionsContext 执行带ctx的OPTIONS请求
+func OptionsContext(ctx context.Context, url string, opt *Opt) (*Response, error) {
+	return doMethodContext(ctx, "OPTIONS", url, opt)
+}
+
+func doMethodContext(ctx context.Context, method, url string, opt *Opt) (*Response, error) {
+	if opt == nil {
+		opt = NewOpt()
+	}
+	opt.URL = url
+	opt.Method = method
+	return DoContext(ctx, opt)
+}
+
 // 便捷的JSON请求方法
 
 // PostJSON 发送JSON POST请求
@@ -0,0 +1,104 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := GetContext(ctx, srv.URL, &Opt{NotLog: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望context.Canceled, 实际: %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("取消未及时生效")
+	}
+}
+
+func TestDoContextTimeoutKeepsSharedClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	shared := &http.Client{Timeout: 10 * time.Second}
+	_, err := Get(srv.URL, &Opt{HttpCLi: shared, TimeOut: 1, NotLog: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望context.DeadlineExceeded, 实际: %v", err)
+	}
+	if shared.Timeout != 10*time.Second {
+		t.Fatalf("共享客户端Timeout被修改: %v", shared.Timeout)
+	}
+}
+
+func TestDoContextValues(t *testing.T) {
+	type ctxKey struct{}
+	var got any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.Context().Value(ctxKey{})
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	resp, err := GetContext(ctx, srv.URL, &Opt{HttpCLi: client, NotLog: true})
+	if err != nil || resp.Text != "ok" {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if got != "v" {
+		t.Fatalf("ctx值未透传: %v", got)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestNewOptTimeOutAboveDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// 调大TimeOut后不应再被客户端的30秒超时截断
+	opt := NewOpt()
+	opt.TimeOut = 120
+	opt.NotLog = true
+	var remaining time.Duration
+	opt.Middlewares = []Middleware{func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			deadline, _ := req.Raw.Context().Deadline()
+			remaining = time.Until(deadline)
+			return next(req)
+		}
+	}}
+	if _, err := Get(srv.URL, opt); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if remaining < 110*time.Second {
+		t.Fatalf("TimeOut未生效: %v", remaining)
+	}
+	if opt.HttpCLi != nil && opt.HttpCLi.Timeout > 0 && opt.HttpCLi.Timeout < 120*time.Second {
+		t.Fatalf("客户端超时会截断请求: %v", opt.HttpCLi.Timeout)
+	}
+}
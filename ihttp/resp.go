@@ -1,14 +1,38 @@
 package ihttp
 
-import "net/http"
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/html"
+)
 
 // Response 响应结构
 type Response struct {
 	StatusCode int
+	Proto      string // 实际使用的协议，如 "HTTP/1.1"、"HTTP/2.0"、"HTTP/3.0"
 	Body       []byte
 	Text       string
 	Headers    map[string][]string
 	CookieList []*http.Cookie
+
+	// Reader Stream模式下的响应体，非Stream模式为nil
+	Reader io.ReadCloser
+
+	// URL 最终响应对应的请求地址，发生重定向时为最后一跳的地址
+	URL string
+	// RedirectChain 经过的重定向，按顺序记录每一跳，不含最终响应
+	RedirectChain []RedirectHop
+
+	// CacheStatus 响应缓存状态，未启用缓存或非GET请求时为空
+	CacheStatus CacheStatus
+
+	// Timing 各阶段耗时，Stream模式的Total截止到收到响应头
+	Timing *Timing
+
+	root *html.Node // HTML解析结果缓存
 }
 
 // IsSuccess 检查响应是否成功
@@ -21,3 +45,29 @@ func (r *Response) IsSuccess() bool {
 	}
 	return true
 }
+
+// FromCache 响应内容是否来自缓存
+func (r *Response) FromCache() bool {
+	return r != nil && (r.CacheStatus == CacheHit || r.CacheStatus == CacheRevalidated)
+}
+
+// Close 关闭Stream模式下的响应体，非Stream模式无操作
+func (r *Response) Close() error {
+	if r == nil || r.Reader == nil {
+		return nil
+	}
+	return r.Reader.Close()
+}
+
+// streamBody 关闭响应流时同时释放请求ctx
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (s *streamBody) Close() error {
+	err := s.ReadCloser.Close()
+	s.once.Do(s.cancel)
+	return err
+}
@@ -0,0 +1,141 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 重试策略
+// 零值字段使用默认值，MaxAttempts<=1 时不重试
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数(含首次)
+	BaseDelay   time.Duration // 首次重试等待时间，默认200ms
+	MaxDelay    time.Duration // 单次退避等待上限，同样限制Retry-After，默认10s
+	Multiplier  float64       // 退避倍数，默认2
+	Jitter      float64       // 抖动比例，取值(0,1]，0使用默认0.2，负数关闭抖动
+
+	RetryStatus    []int // 需要重试的状态码，为空使用 DefaultRetryStatus
+	NoRetryOnError bool  // 为true时传输错误(连接失败、读取中断等)不重试，默认重试
+
+	// IgnoreRetryAfter 为true时忽略响应中的Retry-After
+	IgnoreRetryAfter bool
+
+	// RetryIf 自定义重试判断，返回true时重试，优先于状态码判断
+	RetryIf func(resp *Response, err error) bool
+}
+
+// DefaultRetryStatus 默认重试的状态码
+var DefaultRetryStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// NewRetryPolicy 创建重试策略，在传输错误与默认状态码时重试
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: maxAttempts}
+}
+
+// attempts 返回最大尝试次数
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// shouldRetry 判断本次结果是否需要重试
+func (p *RetryPolicy) shouldRetry(ctx context.Context, resp *Response, err error) bool {
+	if p == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.RetryIf != nil {
+		return p.RetryIf(resp, err)
+	}
+
+	// 没有响应的错误视为传输错误
+	if resp == nil {
+		return err != nil && !p.NoRetryOnError
+	}
+	if err != nil {
+		return false
+	}
+
+	status := p.RetryStatus
+	if len(status) == 0 {
+		status = DefaultRetryStatus
+	}
+	for _, code := range status {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 计算第attempt次失败后的等待时间
+func (p *RetryPolicy) backoff(attempt int, resp *Response) time.Duration {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = 10 * time.Second
+	}
+	if !p.IgnoreRetryAfter && resp != nil {
+		if wait, ok := parseRetryAfter(resp.Headers["Retry-After"]); ok {
+			// 服务端给出的等待时间同样受MaxDelay限制，避免长时间阻塞调用方
+			return min(wait, maxDelay)
+		}
+	}
+
+	base := p.BaseDelay
+	if base <= 0 {
+		base = 200 * time.Millisecond
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	jitter := p.Jitter
+	if jitter == 0 || jitter > 1 {
+		jitter = 0.2
+	} else if jitter < 0 {
+		jitter = 0
+	}
+
+	delay := float64(base) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	// 在[1-jitter, 1+jitter]区间内随机抖动
+	delay *= 1 + jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// parseRetryAfter 解析Retry-After，支持秒数与HTTP日期两种格式
+func parseRetryAfter(values []string) (time.Duration, bool) {
+	if len(values) == 0 || values[0] == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(values[0]); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(values[0]); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,128 @@
+package ihttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryStatusAndBodyRewind(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"k":"v"}` {
+			t.Errorf("第%d次请求体不一致: %s", calls+1, body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	var out struct {
+		OK bool `json:"ok"`
+	}
+	resp, err := Post(srv.URL, &Opt{
+		Json:    map[string]string{"k": "v"},
+		RespOut: &out,
+		Retry:   &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	})
+	if err != nil || !resp.IsSuccess() || !out.OK {
+		t.Fatalf("重试后应成功: %v %+v", err, resp)
+	}
+	if calls != 3 {
+		t.Fatalf("期望请求3次, 实际%d次", calls)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	resp, err := Get(srv.URL, &Opt{NotLog: true, Retry: NewRetryPolicy(2)})
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("应返回最后一次响应: %v %+v", err, resp)
+	}
+	if calls != 2 {
+		t.Fatalf("期望请求2次, 实际%d次", calls)
+	}
+}
+
+func TestRetryTransportError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// 零值策略默认重试传输错误
+	resp, err := Get(srv.URL, &Opt{NotLog: true,
+		Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+	if err != nil || resp.Text != "ok" {
+		t.Fatalf("传输错误后应重试成功: %v", err)
+	}
+
+	// 关闭空闲连接，避免Transport对复用连接上的GET自动重发
+	srv.CloseClientConnections()
+	atomic.StoreInt32(&calls, 0)
+	_, err = Get(srv.URL, &Opt{NotLog: true,
+		Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, NoRetryOnError: true}})
+	if err == nil || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("NoRetryOnError时传输错误不应重试: %v %d", err, calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter([]string{"3"}); !ok || d != 3*time.Second {
+		t.Fatalf("秒数解析错误: %v", d)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter([]string{date}); !ok || d < 59*time.Minute {
+		t.Fatalf("日期解析错误: %v", d)
+	}
+	if _, ok := parseRetryAfter([]string{"abc"}); ok {
+		t.Fatal("非法值应解析失败")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Jitter: 0.1}
+	if d := p.backoff(1, nil); d < 90*time.Millisecond || d > 110*time.Millisecond {
+		t.Fatalf("首次退避异常: %v", d)
+	}
+	if d := p.backoff(5, nil); d > 330*time.Millisecond {
+		t.Fatalf("退避未受MaxDelay限制: %v", d)
+	}
+	// 负数关闭抖动
+	noJitter := &RetryPolicy{BaseDelay: 100 * time.Millisecond, Jitter: -1}
+	for i := 0; i < 5; i++ {
+		if d := noJitter.backoff(2, nil); d != 200*time.Millisecond {
+			t.Fatalf("关闭抖动后退避应固定: %v", d)
+		}
+	}
+
+	// Retry-After 超过MaxDelay时截断
+	resp := &Response{Headers: map[string][]string{"Retry-After": {"86400"}}}
+	if d := p.backoff(1, resp); d != 300*time.Millisecond {
+		t.Fatalf("Retry-After未受MaxDelay限制: %v", d)
+	}
+	resp.Headers["Retry-After"] = []string{"0"}
+	if d := p.backoff(1, resp); d != 0 {
+		t.Fatalf("Retry-After应优先于退避: %v", d)
+	}
+}
@@ -0,0 +1,463 @@
+package ihttp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer 请求签名器，在中间件链之后、发送之前对最终请求签名，每次重试都会重新签名
+// body为完整请求体，无请求体时为nil；设置签名器时multipart上传会先读入内存
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// SignerFunc 函数形式的签名器
+type SignerFunc func(req *http.Request, body []byte) error
+
+// Sign 实现 Signer
+func (f SignerFunc) Sign(req *http.Request, body []byte) error {
+	return f(req, body)
+}
+
+// signRequest 使用签名器对请求签名
+func signRequest(signer Signer, req *Request) error {
+	if err := signer.Sign(req.Raw, req.payload); err != nil {
+		return fmt.Errorf("请求签名失败: %w", err)
+	}
+	return nil
+}
+
+// ---------------- AWS Signature Version 4 ----------------
+
+// AWSSigV4 AWS签名V4，签名写入 Authorization 请求头
+type AWSSigV4 struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // 临时凭证的会话令牌，写入 X-Amz-Security-Token
+	Region       string
+	Service      string // 服务名，如 s3、execute-api
+
+	// UnsignedPayload 不对请求体签名，适用于S3大文件上传
+	UnsignedPayload bool
+
+	Now func() time.Time // 当前时间，测试时可固定，nil使用 time.Now
+}
+
+// awsSignedHeader 仅对host、content-type、content-md5与x-amz-*签名
+// Connection、Accept-Encoding等可能被Transport或代理改写的请求头不参与签名
+func awsSignedHeader(name string) bool {
+	switch name {
+	case "host", "content-type", "content-md5":
+		return true
+	}
+	return strings.HasPrefix(name, "x-amz-")
+}
+
+// Sign 实现 Signer
+func (s *AWSSigV4) Sign(req *http.Request, body []byte) error {
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return errors.New("AWS凭证为空")
+	}
+	if s.Region == "" || s.Service == "" {
+		return errors.New("AWS Region/Service为空")
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	payloadHash := "UNSIGNED-PAYLOAD"
+	if !s.UnsignedPayload {
+		payloadHash = sha256Hex(body)
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.Service == "s3" || s.UnsignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// 规范请求头
+	headers := map[string]string{"host": requestHost(req)}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		// Header中的Host不会被发送，以requestHost为准
+		if !awsSignedHeader(name) || name == "host" {
+			continue
+		}
+		values := make([]string, len(v))
+		for i, value := range v {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// 除S3外路径需要编码两次
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	path = rfc3986Escape(path, true)
+	if s.Service != "s3" {
+		path = rfc3986Escape(path, true)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSum(sha256.New, []byte("AWS4"+s.SecretKey), []byte(date))
+	key = hmacSum(sha256.New, key, []byte(s.Region))
+	key = hmacSum(sha256.New, key, []byte(s.Service))
+	key = hmacSum(sha256.New, key, []byte("aws4_request"))
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalQuery 按键、值排序并以RFC 3986编码查询参数
+func canonicalQuery(query url.Values) string {
+	pairs := make([][2]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{rfc3986Escape(k, false), rfc3986Escape(v, false)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(parts, "&")
+}
+
+// ---------------- OAuth 1.0a ----------------
+
+// OAuth1 签名方法
+const (
+	OAuthHMACSHA1   = "HMAC-SHA1"
+	OAuthHMACSHA256 = "HMAC-SHA256"
+	OAuthPlaintext  = "PLAINTEXT"
+)
+
+// OAuth1 OAuth 1.0a签名(RFC 5849)，签名写入 Authorization 请求头
+// 查询参数与 application/x-www-form-urlencoded 请求体参数参与签名
+type OAuth1 struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	Token          string
+	TokenSecret    string
+
+	SignatureMethod string // 默认 HMAC-SHA1
+	Realm           string
+	Callback        string // 获取请求令牌时的 oauth_callback
+	Verifier        string // 换取访问令牌时的 oauth_verifier
+
+	Now   func() time.Time // 当前时间，nil使用 time.Now
+	Nonce func() string    // 随机串生成，nil使用32位随机十六进制
+}
+
+// Sign 实现 Signer
+func (o *OAuth1) Sign(req *http.Request, body []byte) error {
+	if o.ConsumerKey == "" {
+		return errors.New("OAuth ConsumerKey为空")
+	}
+	method := o.SignatureMethod
+	if method == "" {
+		method = OAuthHMACSHA1
+	}
+	now := time.Now
+	if o.Now != nil {
+		now = o.Now
+	}
+	nonce := newNonce
+	if o.Nonce != nil {
+		nonce = o.Nonce
+	}
+
+	oauth := map[string]string{
+		"oauth_consumer_key":     o.ConsumerKey,
+		"oauth_nonce":            nonce(),
+		"oauth_signature_method": method,
+		"oauth_timestamp":        strconv.FormatInt(now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if o.Token != "" {
+		oauth["oauth_token"] = o.Token
+	}
+	if o.Callback != "" {
+		oauth["oauth_callback"] = o.Callback
+	}
+	if o.Verifier != "" {
+		oauth["oauth_verifier"] = o.Verifier
+	}
+
+	key := rfc3986Escape(o.ConsumerSecret, false) + "&" + rfc3986Escape(o.TokenSecret, false)
+	var signature string
+	switch method {
+	case OAuthPlaintext:
+		signature = key
+	case OAuthHMACSHA1, OAuthHMACSHA256:
+		params := req.URL.Query()
+		if isFormBody(req) {
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return fmt.Errorf("表单请求体解析失败: %v", err)
+			}
+			for k, v := range form {
+				params[k] = append(params[k], v...)
+			}
+		}
+		for k, v := range oauth {
+			params.Set(k, v)
+		}
+		base := strings.ToUpper(req.Method) + "&" +
+			rfc3986Escape(oauthBaseURL(req), false) + "&" +
+			rfc3986Escape(canonicalQuery(params), false)
+
+		h := sha1.New
+		if method == OAuthHMACSHA256 {
+			h = sha256.New
+		}
+		signature = base64.StdEncoding.EncodeToString(hmacSum(h, []byte(key), []byte(base)))
+	default:
+		return fmt.Errorf("不支持的OAuth签名方法: %s", method)
+	}
+	oauth["oauth_signature"] = signature
+
+	names := make([]string, 0, len(oauth))
+	for name := range oauth {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names)+1)
+	if o.Realm != "" {
+		parts = append(parts, `realm="`+rfc3986Escape(o.Realm, false)+`"`)
+	}
+	for _, name := range names {
+		parts = append(parts, name+`="`+rfc3986Escape(oauth[name], false)+`"`)
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.Join(parts, ", "))
+	return nil
+}
+
+// oauthBaseURL 返回不含查询参数、默认端口的小写基础地址
+func oauthBaseURL(req *http.Request) string {
+	scheme := strings.ToLower(req.URL.Scheme)
+	host := strings.ToLower(requestHost(req))
+	if (scheme == "http" && strings.HasSuffix(host, ":80")) || (scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path
+}
+
+func isFormBody(req *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(req.Header.Get("Content-Type")), "application/x-www-form-urlencoded")
+}
+
+// ---------------- 通用HMAC ----------------
+
+// SignPayload HMAC签名的输入
+type SignPayload struct {
+	Timestamp string
+	Nonce     string
+	Method    string // 大写请求方法
+	Path      string // 请求路径，含查询参数，如 /api/v5/order?instId=BTC-USDT
+	Query     string // 原始查询参数，不含?
+	Body      []byte
+}
+
+// HMACSigner 交易所风格的HMAC签名，默认对 timestamp+method+path+body 做HMAC-SHA256并写入请求头
+//
+// 使用示例(OKX):
+//
+//	signer := &ihttp.HMACSigner{
+//		Key: apiKey, Secret: secret, Base64: true,
+//		KeyHeader: "OK-ACCESS-KEY", SignHeader: "OK-ACCESS-SIGN", TimestampHeader: "OK-ACCESS-TIMESTAMP",
+//		Timestamp: func(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05.000Z") },
+//	}
+type HMACSigner struct {
+	Key    string // API Key，KeyHeader非空时写入该请求头
+	Secret string // 签名密钥
+
+	Hash   func() hash.Hash // 摘要算法，默认 sha256.New
+	Base64 bool             // 签名使用Base64编码，默认小写十六进制
+
+	KeyHeader       string // 默认 X-API-KEY
+	SignHeader      string // 默认 X-SIGNATURE
+	TimestampHeader string // 默认 X-TIMESTAMP
+	NonceHeader     string // 非空时生成随机串写入该请求头并参与签名
+
+	// Timestamp 时间戳格式化，默认Unix毫秒
+	Timestamp func(t time.Time) string
+	// Message 构造待签名字符串，默认 Timestamp+Nonce+Method+Path+Body
+	Message func(p *SignPayload) string
+	// Apply 自定义签名写入位置，如作为查询参数，设置后不再写入默认请求头
+	Apply func(req *http.Request, p *SignPayload, signature string) error
+
+	Now   func() time.Time // 当前时间，nil使用 time.Now
+	Nonce func() string    // 随机串生成，nil使用32位随机十六进制
+}
+
+// Sign 实现 Signer
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	if s.Secret == "" {
+		return errors.New("HMAC密钥为空")
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now()
+
+	p := &SignPayload{
+		Method: strings.ToUpper(req.Method),
+		Path:   req.URL.EscapedPath(),
+		Query:  req.URL.RawQuery,
+		Body:   body,
+	}
+	if p.Path == "" {
+		p.Path = "/"
+	}
+	if p.Query != "" {
+		p.Path += "?" + p.Query
+	}
+	if s.Timestamp != nil {
+		p.Timestamp = s.Timestamp(t)
+	} else {
+		p.Timestamp = strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	if s.NonceHeader != "" {
+		if s.Nonce != nil {
+			p.Nonce = s.Nonce()
+		} else {
+			p.Nonce = newNonce()
+		}
+	}
+
+	var message string
+	if s.Message != nil {
+		message = s.Message(p)
+	} else {
+		message = p.Timestamp + p.Nonce + p.Method + p.Path + string(p.Body)
+	}
+	h := s.Hash
+	if h == nil {
+		h = sha256.New
+	}
+	sum := hmacSum(h, []byte(s.Secret), []byte(message))
+	signature := hex.EncodeToString(sum)
+	if s.Base64 {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	if s.Apply != nil {
+		return s.Apply(req, p, signature)
+	}
+	if s.Key != "" {
+		req.Header.Set(orDefault(s.KeyHeader, "X-API-KEY"), s.Key)
+	}
+	req.Header.Set(orDefault(s.TimestampHeader, "X-TIMESTAMP"), p.Timestamp)
+	if s.NonceHeader != "" {
+		req.Header.Set(s.NonceHeader, p.Nonce)
+	}
+	req.Header.Set(orDefault(s.SignHeader, "X-SIGNATURE"), signature)
+	return nil
+}
+
+// ---------------- 工具函数 ----------------
+
+func hmacSum(h func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// rfc3986Escape 按RFC 3986编码，仅保留非保留字符，keepSlash为true时不编码/
+func rfc3986Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// requestHost 返回请求实际发送的Host
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
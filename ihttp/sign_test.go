@@ -0,0 +1,151 @@
+package ihttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAWSSigV4(t *testing.T) {
+	fixed := func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+
+	// AWS签名测试套件 get-vanilla
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s := &AWSSigV4{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region: "us-east-1", Service: "service", Now: fixed}
+	if err := s.Sign(req, nil); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("签名错误:\n%s\n%s", got, want)
+	}
+
+	// AWS文档中的IAM示例，查询参数与Content-Type参与签名
+	req, _ = http.NewRequest("GET", "https://iam.amazonaws.com/?Version=2010-05-08&Action=ListUsers", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	// 仅host、content-type、content-md5与x-amz-*参与签名
+	req.Header.Set("User-Agent", "ihttp")
+	req.Header.Set("Connection", "keep-alive")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("X-Request-ID", "1")
+	s = &AWSSigV4{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region: "us-east-1", Service: "iam", Now: fixed}
+	s.Sign(req, nil)
+	if got := req.Header.Get("Authorization"); !strings.HasSuffix(got,
+		"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7") {
+		t.Fatalf("IAM示例签名错误: %s", got)
+	}
+
+	// S3不对路径二次编码，并写入请求体摘要
+	req, _ = http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/a%20b.txt", nil)
+	s = &AWSSigV4{AccessKey: "AK", SecretKey: "SK", SessionToken: "tok", Region: "us-east-1", Service: "s3", Now: fixed}
+	s.Sign(req, []byte("hello"))
+	if req.Header.Get("X-Amz-Content-Sha256") != sha256Hex([]byte("hello")) || req.Header.Get("X-Amz-Security-Token") != "tok" ||
+		!strings.Contains(req.Header.Get("Authorization"), "x-amz-content-sha256;x-amz-date;x-amz-security-token") {
+		t.Fatalf("S3签名请求头错误: %v", req.Header)
+	}
+}
+
+func TestOAuth1(t *testing.T) {
+	// Twitter文档中的签名示例
+	req, _ := http.NewRequest("POST", "https://api.twitter.com/1.1/statuses/update.json?include_entities=true", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	o := &OAuth1{
+		ConsumerKey:    "xvz1evFS4wEEPTGEFPHBog",
+		ConsumerSecret: "kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw",
+		Token:          "370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb",
+		TokenSecret:    "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE",
+		Now:            func() time.Time { return time.Unix(1318622958, 0) },
+		Nonce:          func() string { return "kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg" },
+	}
+	body := "status=Hello%20Ladies%20%2B%20Gentlemen%2C%20a%20signed%20OAuth%20request%21"
+	if err := o.Sign(req, []byte(body)); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, `OAuth oauth_consumer_key="xvz1evFS4wEEPTGEFPHBog", oauth_nonce=`) ||
+		!strings.Contains(auth, `oauth_signature="hCtSmYh%2BiHYCEqBWrE7C7hYmtUk%3D"`) {
+		t.Fatalf("签名错误: %s", auth)
+	}
+
+	o.SignatureMethod = OAuthPlaintext
+	o.Realm = "Photos"
+	o.Sign(req, nil)
+	auth = req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, `OAuth realm="Photos", `) ||
+		!strings.Contains(auth, `oauth_signature="kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw%26LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE"`) {
+		t.Fatalf("PLAINTEXT签名错误: %s", auth)
+	}
+}
+
+func TestHMACSignerRequest(t *testing.T) {
+	var mu sync.Mutex
+	var got []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		n := len(got)
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	ts := int64(1700000000000)
+	signer := &HMACSigner{
+		Key: "k1", Secret: "s1", NonceHeader: "X-NONCE",
+		Now:   func() time.Time { ts += 1000; return time.UnixMilli(ts) },
+		Nonce: func() string { return "n1" },
+	}
+	cli := NewClient(srv.URL)
+	cli.NotLog = true
+	cli.Signer = signer
+	cli.Middlewares = []Middleware{RequestIDMiddleware("")}
+	_, err := cli.Post("/api/order?symbol=BTC", &Opt{
+		Json:  map[string]any{"qty": 1},
+		Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("请求失败: %v %d", err, len(got))
+	}
+
+	// 每次重试使用新的时间戳重新签名
+	for i, h := range got {
+		timestamp := h.Get("X-TIMESTAMP")
+		mac := hmac.New(sha256.New, []byte("s1"))
+		mac.Write([]byte(timestamp + "n1" + "POST" + "/api/order?symbol=BTC" + `{"qty":1}`))
+		if h.Get("X-API-KEY") != "k1" || h.Get("X-NONCE") != "n1" || h.Get("X-SIGNATURE") != hex.EncodeToString(mac.Sum(nil)) {
+			t.Fatalf("第%d次签名错误: %v", i+1, h)
+		}
+	}
+	if got[0].Get("X-TIMESTAMP") == got[1].Get("X-TIMESTAMP") {
+		t.Fatal("重试应重新签名")
+	}
+
+	// multipart请求体读入内存后参与签名，自定义签名位置
+	var signed []byte
+	_, err = Post(srv.URL+"/upload", &Opt{
+		NotLog: true,
+		Data:   map[string]any{"a": "1"},
+		Files:  map[string]File{"f": {Reader: strings.NewReader("content"), FileName: "f.txt"}},
+		Signer: &HMACSigner{Secret: "s1", Apply: func(req *http.Request, p *SignPayload, signature string) error {
+			signed = p.Body
+			q := req.URL.Query()
+			q.Set("signature", signature)
+			req.URL.RawQuery = q.Encode()
+			return nil
+		}},
+	})
+	if err != nil || !strings.Contains(string(signed), "content") {
+		t.Fatalf("multipart签名失败: %v %q", err, signed)
+	}
+}
@@ -0,0 +1,110 @@
+package ihttp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrStopIteration 在EachLine/EachEvent回调中返回，用于提前结束读取且不视为错误
+var ErrStopIteration = errors.New("停止读取")
+
+// Event SSE(text/event-stream)事件
+type Event struct {
+	ID    string
+	Event string // 事件类型，未指定时为空
+	Data  string // 多行data以\n拼接
+	Retry int    // 服务端建议的重连毫秒数
+}
+
+// bodyReader 返回响应体读取器，优先使用Stream模式下的Reader
+func (r *Response) bodyReader() io.Reader {
+	if r.Reader != nil {
+		return r.Reader
+	}
+	return bytes.NewReader(r.Body)
+}
+
+// EachLine 逐行读取响应体(适用于NDJSON等按行输出的接口)，行尾换行符会被去掉
+// 读取结束或回调返回错误后自动关闭响应流
+func (r *Response) EachLine(fn func(line string) error) error {
+	if r == nil {
+		return errors.New("空响应")
+	}
+	defer r.Close()
+
+	reader := bufio.NewReader(r.bodyReader())
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimRight(line, "\r\n")
+			if cbErr := fn(line); cbErr != nil {
+				if errors.Is(cbErr, ErrStopIteration) {
+					return nil
+				}
+				return cbErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// EachEvent 按SSE格式解析响应体，每个完整事件调用一次fn
+// 读取结束或回调返回错误后自动关闭响应流
+func (r *Response) EachEvent(fn func(ev *Event) error) error {
+	ev := &Event{}
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 && ev.Event == "" && ev.ID == "" {
+			return nil
+		}
+		ev.Data = strings.Join(data, "\n")
+		err := fn(ev)
+		ev, data = &Event{}, nil
+		return err
+	}
+
+	err := r.EachLine(func(line string) error {
+		// 空行表示事件结束
+		if line == "" {
+			return dispatch()
+		}
+		// 冒号开头为注释
+		if strings.HasPrefix(line, ":") {
+			return nil
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			ev.ID = value
+		case "retry":
+			if n, err := strconv.Atoi(value); err == nil {
+				ev.Retry = n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 流结束时分发最后一个未以空行结尾的事件
+	if err := dispatch(); err != nil && !errors.Is(err, ErrStopIteration) {
+		return err
+	}
+	return nil
+}
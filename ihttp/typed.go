@@ -0,0 +1,144 @@
+package ihttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// errorBodyLimit HTTPError中保留的响应体最大长度
+const errorBodyLimit = 1024
+
+// HTTPError 非2xx响应错误，可通过 errors.As 获取
+//
+// 使用示例:
+//
+//	var httpErr *ihttp.HTTPError
+//	if errors.As(err, &httpErr) && httpErr.StatusCode == 404 { ... }
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	URL        string // 按 Opt.Redactor 脱敏后的请求地址
+	Headers    http.Header
+	Body       string // 脱敏后的响应体，超过1024字节时按字符边界截断
+	Payload    any    // Opt.ErrOut 解码后的错误响应体，未设置或解码失败为nil
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("请求失败 %s %s, 状态码: %d", e.Method, e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("请求失败 %s %s, 状态码: %d, 响应: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// newHTTPError 根据响应构建错误，opt.ErrOut 不为空时尝试解码错误响应体
+func newHTTPError(opt *Opt, resp *Response) *HTTPError {
+	r := resolveRedactor(opt)
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Method:     opt.Method,
+		URL:        r.URL(opt.URL),
+		Headers:    http.Header(resp.Headers),
+		Body:       truncateText(r.textIfChanged(resp.Text), errorBodyLimit),
+	}
+	if opt.ErrOut != nil && len(resp.Body) > 0 {
+		if json.Unmarshal(resp.Body, opt.ErrOut) == nil {
+			e.Payload = opt.ErrOut
+		}
+	}
+	return e
+}
+
+// truncateText 超过limit字节时在不超过limit的字符边界处截断，避免截断多字节字符
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "...(省略)"
+}
+
+// DoJSON 执行请求并将2xx响应体解码为T，非2xx返回 *HTTPError
+func DoJSON[T any](opt *Opt) (T, *Response, error) {
+	return DoJSONContext[T](context.Background(), opt)
+}
+
+// DoJSONContext 带ctx的DoJSON
+func DoJSONContext[T any](ctx context.Context, opt *Opt) (T, *Response, error) {
+	return doJSON[T](ctx, opt)
+}
+
+// ClientDoJSON 使用会话客户端执行请求并解码响应，Opt.URL可为相对路径
+func ClientDoJSON[T any](ctx context.Context, c *Client, opt *Opt) (T, *Response, error) {
+	return doJSON[T](ctx, c.prepare(opt))
+}
+
+// GetJSON 发送GET请求并将响应体解码为T
+func GetJSON[T any](url string, opt *Opt) (T, *Response, error) {
+	return DoJSON[T](withMethod(opt, "GET", url, nil))
+}
+
+// DeleteJSON 发送DELETE请求并将响应体解码为T
+func DeleteJSON[T any](url string, opt *Opt) (T, *Response, error) {
+	return DoJSON[T](withMethod(opt, "DELETE", url, nil))
+}
+
+// PostJSONAs 以JSON发送body并将响应体解码为T
+func PostJSONAs[T any](url string, body any, opt *Opt) (T, *Response, error) {
+	return DoJSON[T](withMethod(opt, "POST", url, body))
+}
+
+// PutJSONAs 以JSON发送body并将响应体解码为T
+func PutJSONAs[T any](url string, body any, opt *Opt) (T, *Response, error) {
+	return DoJSON[T](withMethod(opt, "PUT", url, body))
+}
+
+// PatchJSONAs 以JSON发送body并将响应体解码为T
+func PatchJSONAs[T any](url string, body any, opt *Opt) (T, *Response, error) {
+	return DoJSON[T](withMethod(opt, "PATCH", url, body))
+}
+
+// withMethod 复制配置并设置方法、地址与JSON请求体，opt为空时与Get等一致使用默认配置
+func withMethod(opt *Opt, method, url string, body any) *Opt {
+	if opt == nil {
+		opt = NewOpt()
+	}
+	o := *opt
+	o.Method = method
+	o.URL = url
+	if body != nil {
+		o.Json = body
+	}
+	return &o
+}
+
+func doJSON[T any](ctx context.Context, opt *Opt) (T, *Response, error) {
+	var out T
+	if opt == nil {
+		opt = &Opt{}
+	}
+	// 由本函数解码，避免与RespOut重复
+	o := *opt
+	o.RespOut = nil
+	o.Stream = false
+
+	resp, err := DoContext(ctx, &o)
+	if err != nil {
+		return out, resp, err
+	}
+	if !resp.IsSuccess() {
+		return out, resp, newHTTPError(&o, resp)
+	}
+	if len(resp.Body) == 0 {
+		return out, resp, nil
+	}
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		return out, resp, fmt.Errorf("反序列化失败: %w", err)
+	}
+	return out, resp, nil
+}
@@ -0,0 +1,133 @@
+package ihttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type testUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type testAPIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newTypedServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/1":
+			json.NewEncoder(w).Encode(testUser{ID: 1, Name: "tom"})
+		case "/users":
+			var u testUser
+			json.NewDecoder(r.Body).Decode(&u)
+			u.ID = 2
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(u)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/big":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(strings.Repeat("x", 4096)))
+		default:
+			w.Header().Set("X-Trace", "t1")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"用户不存在"}`))
+		}
+	}))
+}
+
+func TestGetJSON(t *testing.T) {
+	srv := newTypedServer()
+	defer srv.Close()
+
+	user, resp, err := GetJSON[testUser](srv.URL+"/users/1", &Opt{NotLog: true})
+	if err != nil || resp.StatusCode != 200 || user.Name != "tom" {
+		t.Fatalf("解码失败: %+v %v", user, err)
+	}
+
+	created, _, err := PostJSONAs[testUser](srv.URL+"/users", testUser{Name: "amy"}, &Opt{NotLog: true})
+	if err != nil || created.ID != 2 || created.Name != "amy" {
+		t.Fatalf("POST解码失败: %+v %v", created, err)
+	}
+
+	_, resp, err = GetJSON[testUser](srv.URL+"/empty", &Opt{NotLog: true})
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("空响应不应报错: %v", err)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	srv := newTypedServer()
+	defer srv.Close()
+
+	var apiErr testAPIError
+	_, resp, err := GetJSON[testUser](srv.URL+"/users/9", &Opt{NotLog: true, ErrOut: &apiErr})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("期望HTTPError, 实际: %v", err)
+	}
+	if httpErr.StatusCode != 404 || httpErr.Method != "GET" || httpErr.Headers.Get("X-Trace") != "t1" {
+		t.Fatalf("错误信息不完整: %+v", httpErr)
+	}
+	if httpErr.Payload != &apiErr || apiErr.Code != "not_found" {
+		t.Fatalf("错误响应体未解码: %+v", httpErr.Payload)
+	}
+	if resp == nil || resp.StatusCode != 404 {
+		t.Fatalf("应同时返回响应")
+	}
+
+	_, _, err = DoJSONContext[testUser](context.Background(), &Opt{URL: srv.URL + "/big", NotLog: true})
+	if !errors.As(err, &httpErr) || len(httpErr.Body) > errorBodyLimit+len("...(省略)") {
+		t.Fatalf("响应体未截断: %v", err)
+	}
+
+	// URL脱敏
+	_, _, err = GetJSON[testUser](srv.URL+"/users/9?access_token=abc&page=1", &Opt{NotLog: true})
+	if !errors.As(err, &httpErr) || strings.Contains(err.Error(), "abc") ||
+		httpErr.URL != srv.URL+"/users/9?access_token=***&page=1" {
+		t.Fatalf("错误中的URL未脱敏: %v", err)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	s := strings.Repeat("a", 1023) + "中文"
+	got := truncateText(s, 1024)
+	if !utf8.ValidString(got) || got != strings.Repeat("a", 1023)+"...(省略)" {
+		t.Fatalf("应在字符边界截断: %q", got[1020:])
+	}
+	if truncateText("中文", 1024) != "中文" {
+		t.Fatal("未超长时不应截断")
+	}
+}
+
+// TestWithMethodDefaults 未传配置时应与Get等函数一致带默认超时
+func TestWithMethodDefaults(t *testing.T) {
+	o := withMethod(nil, "POST", "http://example.com", map[string]any{"a": 1})
+	if o.TimeOut != NewOpt().TimeOut || o.Method != "POST" || o.Json == nil {
+		t.Fatalf("默认配置错误: %+v", o)
+	}
+	opt := &Opt{TimeOut: 5}
+	if o := withMethod(opt, "PUT", "http://example.com", nil); o.TimeOut != 5 || opt.Method != "" {
+		t.Fatalf("应复制传入配置: %+v", o)
+	}
+}
+
+func TestClientDoJSON(t *testing.T) {
+	srv := newTypedServer()
+	defer srv.Close()
+
+	cli := &Client{BaseURL: srv.URL, NotLog: true}
+	user, _, err := ClientDoJSON[testUser](context.Background(), cli, &Opt{URL: "/users/1"})
+	if err != nil || user.ID != 1 {
+		t.Fatalf("会话请求失败: %+v %v", user, err)
+	}
+}
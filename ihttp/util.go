@@ -1,11 +1,15 @@
 package ihttp
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
 // convertToStringMap 将map[string]any转换为map[string]string
@@ -47,8 +51,13 @@ func updateCookiesFromResponse(cookies *map[string]string,
 	return ckList
 }
 
-// parseCookieString 解析cookie字符串
+// ParseCookieString 解析Set-Cookie字符串，保留Domain/Path/Expires等属性
 func ParseCookieString(cookieStr string) *http.Cookie {
+	if cookie, err := http.ParseSetCookie(cookieStr); err == nil {
+		return cookie
+	}
+
+	// 非标准格式时退化为仅解析name=value
 	parts := strings.Split(cookieStr, ";")
 	if len(parts) == 0 {
 		return nil
@@ -85,6 +94,9 @@ func getProxyFromEnv(targetURL string) string {
 	if err != nil {
 		return ""
 	}
+	if matchNoProxy(parsedURL.Hostname()) {
+		return ""
+	}
 
 	var proxyEnv string
 	if parsedURL.Scheme == "https" {
@@ -109,3 +121,108 @@ func getProxyFromEnv(targetURL string) string {
 
 	return proxyEnv
 }
+
+// matchNoProxy 检查主机是否命中NO_PROXY
+func matchNoProxy(host string) bool {
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	if noProxy == "" || host == "" {
+		return false
+	}
+
+	host = strings.ToLower(host)
+	for _, item := range strings.Split(noProxy, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if item == "*" {
+			return true
+		}
+		// 去掉端口
+		if h, _, ok := strings.Cut(item, ":"); ok && !strings.Contains(item, "]") {
+			item = h
+		}
+		item = strings.TrimPrefix(item, "*")
+		if host == strings.TrimPrefix(item, ".") ||
+			(strings.HasPrefix(item, ".") && strings.HasSuffix(host, item)) ||
+			strings.HasSuffix(host, "."+item) {
+			return true
+		}
+	}
+	return false
+}
+
+// withJar 派生使用指定Cookie容器的客户端，不修改原客户端
+func withJar(client *http.Client, jar *CookieJar) *http.Client {
+	if jar == nil {
+		return client
+	}
+	c := *client
+	c.Jar = jar
+	return &c
+}
+
+// ctxReader 在每次读取前检查ctx，使请求体构建与响应读取可被取消
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// lockedBuffer 并发安全的内容缓存，请求体可能在响应返回后仍在发送
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
+
+// teeBody 读取时复制内容到buf，读到EOF或关闭时调用done，eof表示内容是否完整
+type teeBody struct {
+	io.ReadCloser
+	buf  *lockedBuffer
+	done func(eof bool)
+	once sync.Once
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.buf.Write(p[:n])
+	}
+	if err == io.EOF {
+		t.finish(true)
+	}
+	return n, err
+}
+
+func (t *teeBody) Close() error {
+	err := t.ReadCloser.Close()
+	t.finish(false)
+	return err
+}
+
+func (t *teeBody) finish(eof bool) {
+	if t.done != nil {
+		t.once.Do(func() { t.done(eof) })
+	}
+}
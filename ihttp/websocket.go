@@ -0,0 +1,544 @@
+package ihttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Covsj/gokit/ilog"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket消息类型
+const (
+	WSText   = websocket.TextMessage
+	WSBinary = websocket.BinaryMessage
+)
+
+var (
+	// ErrWSClosed WebSocket已关闭
+	ErrWSClosed = errors.New("WebSocket已关闭")
+	// ErrWSNotConnected 正在重连，当前没有可用连接
+	ErrWSNotConnected = errors.New("WebSocket未连接")
+)
+
+// wsInboxSize Receive使用的消息队列长度，队列满时丢弃最早的消息
+const wsInboxSize = 256
+
+// WSOptions WebSocket连接选项
+type WSOptions struct {
+	PingInterval time.Duration // 发送ping的间隔，默认30s，<0关闭保活
+	PongTimeout  time.Duration // ping后等待pong(或任意消息)的时间，默认10s，超时视为断线
+	WriteTimeout time.Duration // 单次写入超时，默认10s
+	Subprotocols []string      // 协商的子协议
+
+	// Reconnect 断线重连退避策略，nil时不重连；MaxAttempts为连续重连次数上限，<=0不限
+	Reconnect *RetryPolicy
+
+	// OnConnect 每次连接成功(含重连)后调用，可用于登录、重新订阅，返回错误时断开并按重连策略处理
+	// 回调执行期间尚未开始读取消息，不能在其中调用Receive
+	OnConnect func(ws *WebSocket) error
+	// OnDisconnect 连接断开时调用
+	OnDisconnect func(err error)
+}
+
+// WSMessage 收到的消息
+type WSMessage struct {
+	Type int // WSText 或 WSBinary
+	Data []byte
+}
+
+// Text 返回文本内容
+func (m *WSMessage) Text() string {
+	return string(m.Data)
+}
+
+// JSON 将消息内容按JSON解码到v
+func (m *WSMessage) JSON(v any) error {
+	if err := json.Unmarshal(m.Data, v); err != nil {
+		return fmt.Errorf("JSON解析失败: %w", err)
+	}
+	return nil
+}
+
+// WebSocket 支持自动重连的WebSocket客户端
+// 握手复用Opt中的Headers、Cookies、Jar、Proxy/ProxyPool与TimeOut(握手超时)
+//
+// 使用示例:
+//
+//	ws, err := cli.DialWebSocket(ctx, "/ws", &ihttp.WSOptions{
+//		Reconnect: ihttp.NewRetryPolicy(0),
+//		OnConnect: func(ws *ihttp.WebSocket) error { return ws.SendJSON(sub) },
+//	})
+//	defer ws.Close()
+//	ws.Subscribe(func(msg *ihttp.WSMessage) { ... })
+type WebSocket struct {
+	opt     *Opt
+	options WSOptions
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	subMu  sync.RWMutex
+	subs   map[int]func(msg *WSMessage)
+	nextID int
+	inbox  chan *WSMessage
+
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+	callbackG atomic.Int64 // 正在执行回调的协程ID，0表示没有回调在执行
+}
+
+// DialWebSocket 建立WebSocket连接，opt.URL为ws://或wss://地址，ctx仅控制首次连接
+func DialWebSocket(ctx context.Context, opt *Opt, options *WSOptions) (*WebSocket, error) {
+	if opt == nil {
+		return nil, errors.New("空配置项")
+	}
+	if opt.URL == "" {
+		return nil, errors.New("空请求链接")
+	}
+	ws := &WebSocket{
+		opt:     opt,
+		subs:    map[int]func(msg *WSMessage){},
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if options != nil {
+		ws.options = *options
+	}
+	if ws.options.PingInterval == 0 {
+		ws.options.PingInterval = 30 * time.Second
+	}
+	if ws.options.PongTimeout <= 0 {
+		ws.options.PongTimeout = 10 * time.Second
+	}
+	if ws.options.WriteTimeout <= 0 {
+		ws.options.WriteTimeout = 10 * time.Second
+	}
+
+	conn, err := ws.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go ws.run(conn)
+	return ws, nil
+}
+
+// DialWebSocket 使用会话配置建立WebSocket连接，path可为相对路径
+func (c *Client) DialWebSocket(ctx context.Context, path string, options *WSOptions) (*WebSocket, error) {
+	return DialWebSocket(ctx, c.prepare(&Opt{URL: path}), options)
+}
+
+// dial 完成一次握手
+func (ws *WebSocket) dial(ctx context.Context) (*websocket.Conn, error) {
+	opt := ws.opt
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: 45 * time.Second,
+		Subprotocols:     ws.options.Subprotocols,
+	}
+	if opt.TimeOut > 0 {
+		dialer.HandshakeTimeout = time.Duration(opt.TimeOut) * time.Second
+	}
+	if opt.Jar != nil {
+		dialer.Jar = opt.Jar
+	}
+	if opt.HttpCLi != nil {
+		if t, ok := opt.HttpCLi.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+			dialer.TLSClientConfig = t.TLSClientConfig.Clone()
+		}
+	}
+
+	proxy, _, err := resolveProxy(opt)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != "" {
+		u, err := parseProxyURL(proxy)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Proxy = http.ProxyURL(u)
+	}
+
+	header := http.Header{}
+	for k, v := range opt.Headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions":
+			// 由握手过程设置
+		default:
+			header.Set(k, v)
+		}
+	}
+	if opt.Cookies != nil && len(*opt.Cookies) > 0 && opt.Jar == nil {
+		req := &http.Request{Header: header}
+		for name, value := range *opt.Cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+	}
+
+	start := time.Now()
+	conn, resp, err := dialer.DialContext(ctx, opt.URL, header)
+	if opt.ProxyPool != nil && opt.Proxy == "" && proxy != "" {
+		var response *Response
+		if resp != nil {
+			response = &Response{StatusCode: resp.StatusCode}
+		}
+		markProxy(opt.ProxyPool, proxy, response, err)
+	}
+	if resp != nil && opt.Cookies != nil {
+		updateCookiesFromResponse(opt.Cookies, resp)
+	}
+	if !opt.NotLog {
+		r := resolveRedactor(opt)
+		args := []any{"URL", r.URL(opt.URL), "耗时", time.Since(start).String()}
+		if proxy != "" {
+			args = append(args, "代理", redactProxy(proxy))
+		}
+		if err != nil {
+			args = append(args, "错误信息", r.Error(err))
+		}
+		ilog.Debug("WebSocket连接", args...)
+	}
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("WebSocket握手失败(状态码: %d): %w", resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("WebSocket握手失败: %w", err)
+	}
+	return conn, nil
+}
+
+// connect 握手并执行OnConnect
+func (ws *WebSocket) connect(ctx context.Context) (*websocket.Conn, error) {
+	conn, err := ws.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetPongHandler(func(string) error {
+		return ws.extendDeadline(conn)
+	})
+	ws.extendDeadline(conn)
+
+	ws.mu.Lock()
+	ws.conn = conn
+	ws.mu.Unlock()
+
+	if ws.options.OnConnect != nil {
+		var err error
+		ws.callback(func() { err = ws.options.OnConnect(ws) })
+		if err != nil {
+			ws.mu.Lock()
+			ws.conn = nil
+			ws.mu.Unlock()
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// extendDeadline 收到消息或pong后延长读超时
+func (ws *WebSocket) extendDeadline(conn *websocket.Conn) error {
+	if ws.options.PingInterval < 0 {
+		return nil
+	}
+	return conn.SetReadDeadline(time.Now().Add(ws.options.PingInterval + ws.options.PongTimeout))
+}
+
+// run 读取消息并在断线后重连，直到Close或重连失败
+func (ws *WebSocket) run(conn *websocket.Conn) {
+	defer close(ws.done)
+	for {
+		err := ws.serve(conn)
+
+		ws.mu.Lock()
+		ws.conn = nil
+		ws.mu.Unlock()
+		conn.Close()
+
+		if ws.isClosing() {
+			ws.finish(ErrWSClosed)
+			return
+		}
+		if ws.options.OnDisconnect != nil {
+			ws.callback(func() { ws.options.OnDisconnect(err) })
+		}
+		if ws.options.Reconnect == nil {
+			ws.finish(err)
+			return
+		}
+		if conn, err = ws.reconnect(); err != nil {
+			ws.finish(err)
+			return
+		}
+	}
+}
+
+// serve 读取消息直到连接出错，同时定时发送ping
+func (ws *WebSocket) serve(conn *websocket.Conn) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	if ws.options.PingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(ws.options.PingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					deadline := time.Now().Add(ws.options.WriteTimeout)
+					if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		ws.extendDeadline(conn)
+		ws.dispatch(&WSMessage{Type: msgType, Data: data})
+	}
+}
+
+// reconnect 按退避策略重连
+func (ws *WebSocket) reconnect() (*websocket.Conn, error) {
+	policy := ws.options.Reconnect
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(policy.backoff(attempt, nil))
+		select {
+		case <-ws.closing:
+			timer.Stop()
+			return nil, ErrWSClosed
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-ws.closing:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		conn, err := ws.connect(ctx)
+		cancel()
+		if !ws.opt.NotLog {
+			r := resolveRedactor(ws.opt)
+			ilog.Debug("WebSocket重连", "URL", r.URL(ws.opt.URL), "尝试次数", attempt, "错误信息", r.Error(err))
+		}
+		if err == nil {
+			return conn, nil
+		}
+		if ws.isClosing() {
+			return nil, ErrWSClosed
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return nil, fmt.Errorf("WebSocket重连失败(%d次): %w", attempt, err)
+		}
+	}
+}
+
+// dispatch 将消息分发给订阅者与Receive队列
+func (ws *WebSocket) dispatch(msg *WSMessage) {
+	ws.subMu.RLock()
+	subs := make([]func(msg *WSMessage), 0, len(ws.subs))
+	for _, fn := range ws.subs {
+		subs = append(subs, fn)
+	}
+	inbox := ws.inbox
+	ws.subMu.RUnlock()
+
+	for _, fn := range subs {
+		ws.callback(func() { fn(msg) })
+	}
+	if inbox == nil {
+		return
+	}
+	for {
+		select {
+		case inbox <- msg:
+			return
+		default:
+			// 队列已满，丢弃最早的消息
+			select {
+			case <-inbox:
+			default:
+			}
+		}
+	}
+}
+
+// Subscribe 订阅所有收到的消息，回调在读取协程中同步执行，不应长时间阻塞
+// 返回的函数用于取消订阅；重连后订阅仍然有效
+func (ws *WebSocket) Subscribe(fn func(msg *WSMessage)) (unsubscribe func()) {
+	ws.subMu.Lock()
+	defer ws.subMu.Unlock()
+	id := ws.nextID
+	ws.nextID++
+	ws.subs[id] = fn
+	return func() {
+		ws.subMu.Lock()
+		defer ws.subMu.Unlock()
+		delete(ws.subs, id)
+	}
+}
+
+// Receive 等待下一条消息，首次调用后收到的消息会进入队列，不会因处理慢而丢失(队列上限256条)
+func (ws *WebSocket) Receive(ctx context.Context) (*WSMessage, error) {
+	ws.subMu.Lock()
+	if ws.inbox == nil {
+		ws.inbox = make(chan *WSMessage, wsInboxSize)
+	}
+	inbox := ws.inbox
+	ws.subMu.Unlock()
+
+	select {
+	case msg := <-inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-ws.done:
+		// 连接结束前已收到的消息仍可读取
+		select {
+		case msg := <-inbox:
+			return msg, nil
+		default:
+			return nil, ws.Err()
+		}
+	}
+}
+
+// ReceiveJSON 等待下一条消息并按JSON解码到v
+func (ws *WebSocket) ReceiveJSON(ctx context.Context, v any) error {
+	msg, err := ws.Receive(ctx)
+	if err != nil {
+		return err
+	}
+	return msg.JSON(v)
+}
+
+// Send 发送消息，重连期间返回 ErrWSNotConnected
+func (ws *WebSocket) Send(msgType int, data []byte) error {
+	if ws.isClosing() {
+		return ErrWSClosed
+	}
+	ws.mu.Lock()
+	conn := ws.conn
+	ws.mu.Unlock()
+	if conn == nil {
+		return ErrWSNotConnected
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(ws.options.WriteTimeout))
+	if err := conn.WriteMessage(msgType, data); err != nil {
+		return fmt.Errorf("WebSocket发送失败: %w", err)
+	}
+	return nil
+}
+
+// SendText 发送文本消息
+func (ws *WebSocket) SendText(text string) error {
+	return ws.Send(WSText, []byte(text))
+}
+
+// SendJSON 将v序列化为JSON后以文本消息发送
+func (ws *WebSocket) SendJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("JSON序列化失败: %v", err)
+	}
+	return ws.Send(WSText, data)
+}
+
+// Close 关闭连接并停止重连，等待读取协程退出
+// 在Subscribe、OnConnect或OnDisconnect回调中调用时不等待，回调返回后连接随即结束，可通过Done等待
+func (ws *WebSocket) Close() error {
+	ws.closeOnce.Do(func() {
+		close(ws.closing)
+		ws.mu.Lock()
+		conn := ws.conn
+		ws.mu.Unlock()
+		if conn != nil {
+			ws.writeMu.Lock()
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(time.Second))
+			ws.writeMu.Unlock()
+			conn.Close()
+		}
+	})
+	// 回调在读取协程中执行，在回调所在协程中等待其退出会死锁；其他协程调用时始终等待
+	if g := ws.callbackG.Load(); g != 0 && g == goid() {
+		return nil
+	}
+	<-ws.done
+	return nil
+}
+
+// callback 执行用户回调并记录所在协程，使回调中调用Close时不等待读取协程
+// 回调依次执行(首次OnConnect在Dial的调用方协程，其余在读取协程)，同一时刻最多一个
+func (ws *WebSocket) callback(fn func()) {
+	ws.callbackG.Store(goid())
+	defer ws.callbackG.Store(0)
+	fn()
+}
+
+// goid 返回当前协程ID，仅用于识别回调中调用Close
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	// 格式为 "goroutine 123 [running]:"
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// Done 连接最终结束(关闭或重连失败)时关闭
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Err 返回连接结束的原因，未结束时为nil
+func (ws *WebSocket) Err() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.err
+}
+
+func (ws *WebSocket) isClosing() bool {
+	select {
+	case <-ws.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ws *WebSocket) finish(err error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.err = err
+}
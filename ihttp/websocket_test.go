@@ -0,0 +1,288 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsEchoServer 回显服务端，dropFirst为true时在首个连接收到第一条消息后断开
+type wsEchoServer struct {
+	dropFirst bool
+	conns     atomic.Int32
+	pings     atomic.Int32
+
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func (s *wsEchoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.headers = append(s.headers, r.Header.Clone())
+	s.mu.Unlock()
+
+	header := http.Header{}
+	header.Add("Set-Cookie", "ws_session=abc; Path=/")
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, header)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	n := s.conns.Add(1)
+	conn.SetPingHandler(func(data string) error {
+		s.pings.Add(1)
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if s.dropFirst && n == 1 {
+			return
+		}
+		if err := conn.WriteMessage(msgType, data); err != nil {
+			return
+		}
+	}
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestWebSocketSession(t *testing.T) {
+	s := &wsEchoServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cli := NewClient(wsURL(srv))
+	cli.NotLog = true
+	cli.SetHeader("Authorization", "Bearer t1")
+	cli.SetHeader("Connection", "keep-alive") // 握手头由Dialer设置，应被忽略
+	cli.Jar.SetCookies(mustURL(srv.URL), []*http.Cookie{{Name: "uid", Value: "42"}})
+
+	ctx := context.Background()
+	ws, err := cli.DialWebSocket(ctx, "/ws", nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer ws.Close()
+
+	got := s.headers[0]
+	if got.Get("Authorization") != "Bearer t1" || !strings.Contains(got.Get("Cookie"), "uid=42") {
+		t.Fatalf("会话请求头/Cookie未携带: %v", got)
+	}
+	if cli.Jar.Len() != 2 {
+		t.Fatalf("握手响应Cookie未写入Jar: %d", cli.Jar.Len())
+	}
+
+	type ping struct {
+		Op  string `json:"op"`
+		Seq int    `json:"seq"`
+	}
+	if err := ws.SendJSON(ping{Op: "ping", Seq: 1}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	var out ping
+	if err := ws.ReceiveJSON(ctx, &out); err != nil || out.Seq != 1 || out.Op != "ping" {
+		t.Fatalf("接收失败: %+v %v", out, err)
+	}
+
+	msgs := make(chan string, 1)
+	unsubscribe := ws.Subscribe(func(msg *WSMessage) { msgs <- msg.Text() })
+	ws.SendText("hello")
+	select {
+	case text := <-msgs:
+		if text != "hello" {
+			t.Fatalf("订阅消息错误: %s", text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("订阅未收到消息")
+	}
+	unsubscribe()
+
+	ws.Close()
+	if err := ws.SendText("x"); !errors.Is(err, ErrWSClosed) {
+		t.Fatalf("关闭后发送应返回ErrWSClosed: %v", err)
+	}
+	if !errors.Is(ws.Err(), ErrWSClosed) {
+		t.Fatalf("关闭原因错误: %v", ws.Err())
+	}
+}
+
+func TestWebSocketCloseInCallback(t *testing.T) {
+	s := &wsEchoServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ws, err := DialWebSocket(context.Background(), &Opt{URL: wsURL(srv), NotLog: true}, nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	closed := make(chan error, 1)
+	ws.Subscribe(func(msg *WSMessage) { closed <- ws.Close() })
+	ws.SendText("bye")
+
+	// 在订阅回调中关闭不应死锁
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("关闭失败: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("回调中调用Close死锁")
+	}
+	select {
+	case <-ws.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("回调返回后连接未结束")
+	}
+	if !errors.Is(ws.Err(), ErrWSClosed) {
+		t.Fatalf("关闭原因错误: %v", ws.Err())
+	}
+	// 回调之外再次调用时等待结束后返回
+	if err := ws.Close(); err != nil {
+		t.Fatalf("重复关闭失败: %v", err)
+	}
+}
+
+func TestWebSocketCloseWaitsForCallback(t *testing.T) {
+	s := &wsEchoServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ws, err := DialWebSocket(context.Background(), &Opt{URL: wsURL(srv), NotLog: true}, nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	entered, release := make(chan struct{}), make(chan struct{})
+	ws.Subscribe(func(msg *WSMessage) {
+		close(entered)
+		<-release
+	})
+	ws.SendText("hold")
+	<-entered
+
+	// 其他协程在回调执行期间调用Close，应等待读取协程退出后返回
+	closed := make(chan struct{})
+	go func() {
+		ws.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("回调之外调用Close未等待读取协程退出")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("回调返回后Close未返回")
+	}
+	select {
+	case <-ws.Done():
+	default:
+		t.Fatal("Close返回时读取协程应已退出")
+	}
+}
+
+func TestWebSocketCookieMap(t *testing.T) {
+	s := &wsEchoServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cookies := map[string]string{"token": "t1"}
+	ws, err := DialWebSocket(context.Background(), &Opt{URL: wsURL(srv), NotLog: true, Cookies: &cookies}, nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer ws.Close()
+	if s.headers[0].Get("Cookie") != "token=t1" || cookies["ws_session"] != "abc" {
+		t.Fatalf("Cookie处理错误: %v %v", s.headers[0], cookies)
+	}
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	s := &wsEchoServer{dropFirst: true}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	var connects, disconnects atomic.Int32
+	ws, err := DialWebSocket(context.Background(), &Opt{URL: wsURL(srv), NotLog: true}, &WSOptions{
+		Reconnect: &RetryPolicy{BaseDelay: 10 * time.Millisecond},
+		OnConnect: func(ws *WebSocket) error {
+			connects.Add(1)
+			return ws.SendText("subscribe")
+		},
+		OnDisconnect: func(err error) { disconnects.Add(1) },
+	})
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer ws.Close()
+
+	// 首个连接收到subscribe后被服务端断开，重连后重新订阅并收到回显
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	msg, err := ws.Receive(ctx)
+	if err != nil || msg.Text() != "subscribe" {
+		t.Fatalf("重连后未收到消息: %v", err)
+	}
+	if connects.Load() != 2 || disconnects.Load() != 1 || s.conns.Load() != 2 {
+		t.Fatalf("重连次数错误: connect=%d disconnect=%d conns=%d", connects.Load(), disconnects.Load(), s.conns.Load())
+	}
+
+	// 不重连时断线即结束
+	s2 := &wsEchoServer{dropFirst: true}
+	srv2 := httptest.NewServer(s2)
+	defer srv2.Close()
+	ws2, err := DialWebSocket(context.Background(), &Opt{URL: wsURL(srv2), NotLog: true}, nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	ws2.SendText("x")
+	select {
+	case <-ws2.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("未重连时应结束")
+	}
+	if ws2.Err() == nil {
+		t.Fatal("应返回断线原因")
+	}
+}
+
+func TestWebSocketPing(t *testing.T) {
+	s := &wsEchoServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ws, err := DialWebSocket(context.Background(), &Opt{URL: wsURL(srv), NotLog: true}, &WSOptions{
+		PingInterval: 20 * time.Millisecond,
+		PongTimeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer ws.Close()
+	time.Sleep(150 * time.Millisecond)
+	if s.pings.Load() < 3 {
+		t.Fatalf("ping次数过少: %d", s.pings.Load())
+	}
+
+	// 非ws/wss地址握手失败
+	_, err = DialWebSocket(context.Background(), &Opt{URL: strings.Replace(wsURL(srv), "ws", "http", 1), NotLog: true}, nil)
+	if err == nil {
+		t.Fatal("非ws地址应失败")
+	}
+}
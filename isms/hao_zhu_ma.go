@@ -11,29 +11,39 @@ import (
 type HaoZhuMa struct {
 	Token string
 	User  string
+
+	cli *ihttp.Client
 }
 
 const (
 	domain = "https://api.haozhuma.com"
 )
 
+// client 返回会话客户端
+func (h *HaoZhuMa) client() *ihttp.Client {
+	if h.cli == nil {
+		h.cli = &ihttp.Client{BaseURL: domain}
+	}
+	return h.cli
+}
+
 func (h *HaoZhuMa) Login(user, password string) {
 	type Res struct {
 		Msg   string `json:"msg"`
 		Code  int    `json:"code"`
 		Token string `json:"token"`
 	}
-	reqUrl := fmt.Sprintf("%s/sms/?api=login&user=%s&pass=%s", domain, user, password)
+	reqUrl := fmt.Sprintf("/sms/?api=login&user=%s&pass=%s", user, password)
 	res := Res{}
 
-	_, err := ihttp.Do(&ihttp.Opt{URL: reqUrl, RespOut: &res})
+	_, err := h.client().Get(reqUrl, &ihttp.Opt{RespOut: &res})
 	if err != nil {
-		log.Error("豪猪码登陆失败", "user", user, "password", password,
-			"error", err.Error())
+		log.Error("豪猪码登陆失败", "user", user,
+			"error", ihttp.Redact(err.Error()))
 		return
 	}
 
-	log.Info("豪猪码登陆成功", "user", user, "password", password, "res", res)
+	log.Info("豪猪码登陆成功", "user", user, "code", res.Code, "msg", res.Msg)
 	h.Token = res.Token
 	h.User = user
 }
@@ -53,11 +63,11 @@ func (h *HaoZhuMa) GetPhone(sid string) string {
 		PhoneGsd    string      `json:"phone_gsd"`
 	}
 
-	reqUrl := fmt.Sprintf("%s/sms/?api=getPhone&token=%s"+
-		"&sid=%s&ascription=2&isp=&isp=&Province=&sp=2&paragraph=&isp=1", domain, h.Token, sid)
+	reqUrl := fmt.Sprintf("/sms/?api=getPhone&token=%s"+
+		"&sid=%s&ascription=2&isp=&isp=&Province=&sp=2&paragraph=&isp=1", h.Token, sid)
 	for i := 0; i < 5; i++ {
 		res := Res{}
-		_, err := ihttp.Do(&ihttp.Opt{URL: reqUrl, RespOut: &res})
+		_, err := h.client().Get(reqUrl, &ihttp.Opt{RespOut: &res})
 		if err != nil {
 			log.Error("获取号码失败", "sid", sid,
 				"error", err.Error())
@@ -79,10 +89,10 @@ func (h *HaoZhuMa) GetMessage(sid, phone string) (string, string) {
 		Sms  string      `json:"sms,omitempty"`
 		Yzm  string      `json:"yzm,omitempty"`
 	}
-	reqUrl := fmt.Sprintf("%s/sms/?api=getMessage&token=%s&sid=%s&phone=%s", domain, h.Token, sid, phone)
+	reqUrl := fmt.Sprintf("/sms/?api=getMessage&token=%s&sid=%s&phone=%s", h.Token, sid, phone)
 	for i := 0; i < 20; i++ {
 		res := Res{}
-		_, err := ihttp.Do(&ihttp.Opt{URL: reqUrl, RespOut: &res})
+		_, err := h.client().Get(reqUrl, &ihttp.Opt{RespOut: &res})
 		if err != nil {
 			log.Error("获取验证码失败", "sid", sid,
 				"error", err.Error())